@@ -1,9 +1,9 @@
 package main
 
 import (
-	g "github.com/timskillman/go-sdl/goengine"
+	"log"
 
-	"github.com/chewxy/math32"
+	g "github.com/timskillman/go-sdl/goengine"
 )
 
 const width, height = 800, 600
@@ -14,7 +14,9 @@ type vec3 = g.Vec3
 func main() {
 
 	scene := g.Scene{}
-	scene.Setup("SDL 3D Shapes", width, height)
+	if err := scene.Setup("SDL 3D Shapes", width, height); err != nil {
+		log.Fatal(err)
+	}
 
 	scene.AddShape("cube1", g.ShapeCuboid, 3, 3, 3, vec3{-7, -10, -20}, vec3{0, 0, 0}, 6, 0xff00ffff, imageDir+"redsky.png")
 	scene.AddShape("plane1", g.ShapePlane, 5, 5, 0, vec3{7, -10, -20}, vec3{0, 0, 0}, 1, 0xff00ffff, imageDir+"alps.jpg")
@@ -26,44 +28,83 @@ func main() {
 	scene.AddShape("tcone1", g.ShapeTCone, 2, 5, 3, vec3{-20, 0, -20}, vec3{0, 0.5, 0.5}, 20, 0xff00ffff, imageDir+"barktile.jpg")
 	scene.AddShape("spring1", g.ShapeSpring, 1.5, 0.2, 15, vec3{20, 0, -20}, vec3{0, 0.5, 0.5}, 180, 0xff00ffff, imageDir+"spanel.png")
 
-	zang := float32(0.0)
-
-	userInput := g.UserInput{}
-	for !userInput.Quit {
-		userInput.GetUserInput()
-
-		scene.Draw()
-
-		var shape = scene.Shape("cube1")
-		shape.Rotation = vec3{shape.Rotation.X + 3, shape.Rotation.Y, shape.Rotation.Z + 1}
-
-		scene.Shape("plane1").Rotation.Z += 1
-		scene.Shape("plane1").Position.Z = math32.Cos(zang)*5 - 20
-		zang += 0.1
-
-		scene.Shape("sphere1").Rotation.X += 1
-		scene.Shape("sphere1").Rotation.Y += 0.5
-
-		scene.Shape("torus1").Rotation.Y += 2
-		scene.Shape("torus1").Rotation.X += 0.7
-
-		scene.Shape("tube1").Rotation.Y += 3
-		scene.Shape("tube1").Rotation.X += 0.3
+	scene.Camera.Controller = g.NewOrbitController(vec3{0, 0, -20}, 20)
 
-		scene.Shape("cone1").Rotation.Y += 3
-		scene.Shape("cone1").Rotation.X += 0.3
-
-		scene.Shape("tcone1").Rotation.Y += 3
-		scene.Shape("tcone1").Rotation.X += 2
+	//Draw with shaders and vertex buffers, falling back to immediate mode
+	if renderer, err := g.NewRenderer(); err != nil {
+		log.Println(err)
+	} else {
+		scene.Renderer = renderer
+	}
 
-		scene.Shape("spring1").Rotation.Y += 3
-		scene.Shape("spring1").Rotation.X += 2
+	//Rotation speeds were tuned as degrees per frame at 60fps
+	spins := map[string]vec3{
+		"cube1":     {3, 0, 1},
+		"plane1":    {0, 0, 1},
+		"sphere1":   {1, 0.5, 0},
+		"torus1":    {0.7, 2, 0},
+		"tube1":     {0.3, 3, 0},
+		"cone1":     {0.3, 3, 0},
+		"tcone1":    {2, 3, 0},
+		"spring1":   {2, 3, 0},
+		"cylinder1": {0.5, 0.3, 0.1},
+	}
+	for name := range spins {
+		shape, ok := scene.Shape(name)
+		if !ok {
+			log.Fatalf("shape %q not found", name)
+		}
+		shape.AddTag("spin")
+	}
 
-		shape = scene.Shape("cylinder1")
-		shape.Rotation = vec3{shape.Rotation.X + 0.5, shape.Rotation.Y + 0.3, shape.Rotation.Z + 0.1}
+	//Bob the plane back and forth and pulse the torus colour
+	bob := g.NewFloatTrack("plane1", "position.z", []float32{0, 3.14}, []float32{-15, -25}, g.InterpLinear)
+	bob.Easing = g.EaseInOutSine
+	pulse := g.NewColourTrack("torus1", "colour", []float32{0, 1, 2}, []uint32{0xff00ffff, 0xffffffff, 0xff00ffff}, g.InterpCubic)
+	clip := g.NewAnimationClip("demo", bob, pulse)
 
-		scene.Window.GLSwap()
+	animator := g.NewAnimator()
+	if err := animator.BindScene(&scene, clip); err != nil {
+		log.Fatal(err)
+	}
+	animator.Play(clip, g.LoopPingPong)
+
+	app := g.NewApp(&scene)
+	app.OnInput = func(ui *g.UserInput) {
+		if ui.KeyPressed(g.KeyF11) {
+			scene.ToggleFullscreen()
+		}
+		if ui.KeyPressed(g.KeySpace) {
+			app.TogglePause()
+		}
+		if ui.KeyPressed(g.KeyF9) {
+			toggleRecording(&scene)
+		}
 	}
+	app.Update = func(dt float32) {
+		animator.Update(dt)
+		for _, shape := range scene.ShapesWithTag("spin") {
+			shape.Rotation.SetAdd(spins[shape.Name].MulScalar(dt * 60))
+		}
+	}
+	app.Run()
+}
 
-	scene.Quit()
+// toggleRecording records a GIF of every other frame, stepping the animation at a steady
+// 60fps so the clip is smooth even though capturing slows the demo down
+func toggleRecording(scene *g.Scene) {
+	if scene.Recorder != nil && scene.Recorder.Recording() {
+		if err := scene.Recorder.Stop(); err != nil {
+			log.Println(err)
+			return
+		}
+		log.Printf("recorded %d frames to %s", scene.Recorder.Frames(), scene.Recorder.Path)
+		return
+	}
+	scene.Recorder = g.NewRecorder(g.RecordGIF, "shapes.gif")
+	scene.Recorder.Every = 2
+	scene.Recorder.FixedTimestep = true
+	if err := scene.Recorder.Start(); err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,186 @@
+package goengine
+
+import (
+	"sort"
+
+	"github.com/chewxy/math32"
+)
+
+type Interpolation int
+
+const (
+	InterpStep Interpolation = iota
+	InterpLinear
+	InterpCubic // Catmull-Rom through the neighbouring keys (rotations use slerp on a smoothstep curve)
+)
+
+type TrackKind int
+
+const (
+	TrackVec3 TrackKind = iota
+	TrackQuat
+	TrackFloat
+	TrackColour
+)
+
+type LoopMode int
+
+const (
+	LoopOnce     LoopMode = iota // play to the end and hold the last key
+	LoopRepeat                   // wrap back to the start
+	LoopPingPong                 // alternate forwards and backwards
+)
+
+// Track is a set of keyframes for one property of one target.
+// All values are stored as Vec4 - Vec3 tracks use XYZ, floats use X,
+// quaternions use XYZW and colours are RGBA in the range 0..1.
+type Track struct {
+	Target        string //Shape name (or any name bound with Animator.BindFunc)
+	Property      string //e.g. position, rotation, quaternion, scale, colour or position.x
+	Kind          TrackKind
+	Times         []float32
+	Values        []Vec4
+	Interpolation Interpolation
+	Easing        EasingFunc //Optional easing applied between each pair of keys
+}
+
+func NewVec3Track(target, property string, times []float32, values []Vec3, interp Interpolation) *Track {
+	t := &Track{Target: target, Property: property, Kind: TrackVec3, Times: times, Interpolation: interp}
+	for _, v := range values {
+		t.Values = append(t.Values, V4FromV3(v, 0))
+	}
+	return t
+}
+
+func NewQuatTrack(target, property string, times []float32, values []Quat, interp Interpolation) *Track {
+	t := &Track{Target: target, Property: property, Kind: TrackQuat, Times: times, Interpolation: interp}
+	for _, q := range values {
+		t.Values = append(t.Values, Vec4{q.X, q.Y, q.Z, q.W})
+	}
+	return t
+}
+
+func NewFloatTrack(target, property string, times []float32, values []float32, interp Interpolation) *Track {
+	t := &Track{Target: target, Property: property, Kind: TrackFloat, Times: times, Interpolation: interp}
+	for _, f := range values {
+		t.Values = append(t.Values, Vec4{f, 0, 0, 0})
+	}
+	return t
+}
+
+// NewColourTrack takes colours in the same 0xAABBGGRR format as Shape.Colour
+func NewColourTrack(target, property string, times []float32, values []uint32, interp Interpolation) *Track {
+	t := &Track{Target: target, Property: property, Kind: TrackColour, Times: times, Interpolation: interp}
+	for _, col := range values {
+		r, g, b, a := ColToRGBA(col)
+		t.Values = append(t.Values, Vec4{r, g, b, a})
+	}
+	return t
+}
+
+func (t *Track) Duration() float32 {
+	if len(t.Times) == 0 {
+		return 0
+	}
+	return t.Times[len(t.Times)-1]
+}
+
+// findKey returns the key before time and how far (0..1) time is towards the next key
+func (t *Track) findKey(time float32) (int, float32) {
+	n := len(t.Times)
+	if n == 0 || time <= t.Times[0] {
+		return 0, 0
+	}
+	if time >= t.Times[n-1] {
+		return n - 1, 0
+	}
+	i := sort.Search(n, func(i int) bool { return t.Times[i] > time }) - 1
+	span := t.Times[i+1] - t.Times[i]
+	if span <= 0 {
+		return i + 1, 0
+	}
+	return i, (time - t.Times[i]) / span
+}
+
+// Sample returns the value of the track at time
+func (t *Track) Sample(time float32) Vec4 {
+	if len(t.Values) == 0 {
+		return Vec4{}
+	}
+	i, frac := t.findKey(time)
+	if frac == 0 || t.Interpolation == InterpStep || i+1 >= len(t.Values) {
+		return t.Values[i]
+	}
+	if t.Easing != nil {
+		frac = t.Easing(frac)
+	}
+
+	if t.Kind == TrackQuat {
+		if t.Interpolation == InterpCubic {
+			frac = frac * frac * (3 - 2*frac)
+		}
+		q := NewQuat(t.Values[i].X, t.Values[i].Y, t.Values[i].Z, t.Values[i].W)
+		q.Slerp(NewQuat(t.Values[i+1].X, t.Values[i+1].Y, t.Values[i+1].Z, t.Values[i+1].W), frac)
+		return Vec4{q.X, q.Y, q.Z, q.W}
+	}
+
+	if t.Interpolation == InterpCubic {
+		p0 := t.Values[max(i-1, 0)]
+		p3 := t.Values[min(i+2, len(t.Values)-1)]
+		return catmullRom(p0, t.Values[i], t.Values[i+1], p3, frac)
+	}
+	return t.Values[i].Lerp(t.Values[i+1], frac)
+}
+
+func catmullRom(p0, p1, p2, p3 Vec4, t float32) Vec4 {
+	t2 := t * t
+	t3 := t2 * t
+	a := p1.MulScalar(2)
+	b := p2.Sub(p0).MulScalar(t)
+	c := p0.MulScalar(2).Sub(p1.MulScalar(5)).Add(p2.MulScalar(4)).Sub(p3).MulScalar(t2)
+	d := p1.MulScalar(3).Sub(p0).Sub(p2.MulScalar(3)).Add(p3).MulScalar(t3)
+	return a.Add(b).Add(c).Add(d).MulScalar(0.5)
+}
+
+// AnimationClip is a named set of tracks that play together
+type AnimationClip struct {
+	Name     string
+	Duration float32
+	Tracks   []*Track
+}
+
+// NewAnimationClip creates a clip lasting as long as its longest track
+func NewAnimationClip(name string, tracks ...*Track) *AnimationClip {
+	clip := &AnimationClip{Name: name}
+	for _, track := range tracks {
+		clip.AddTrack(track)
+	}
+	return clip
+}
+
+func (c *AnimationClip) AddTrack(track *Track) {
+	c.Tracks = append(c.Tracks, track)
+	c.Duration = math32.Max(c.Duration, track.Duration())
+}
+
+// loopTime maps a play time onto the clip's duration for the loop mode
+func loopTime(time, duration float32, loop LoopMode) float32 {
+	if duration <= 0 {
+		return 0
+	}
+	switch loop {
+	case LoopRepeat:
+		time = math32.Mod(time, duration)
+		if time < 0 {
+			time += duration
+		}
+	case LoopPingPong:
+		time = math32.Mod(math32.Abs(time), duration*2)
+		if time > duration {
+			time = duration*2 - time
+		}
+	default:
+		time = Clamp(time, 0, duration)
+	}
+	return time
+}
@@ -0,0 +1,266 @@
+package goengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnimationAction is one clip being played by an Animator
+type AnimationAction struct {
+	Clip     *AnimationClip
+	Time     float32
+	Speed    float32
+	Weight   float32
+	Loop     LoopMode
+	Playing  bool
+	Finished bool //Set when a LoopOnce action reaches its end
+
+	fadeTo    float32
+	fadeSpeed float32 //weight change per second, 0 when not fading
+}
+
+// FadeTo changes the weight of the action to weight over duration seconds
+func (a *AnimationAction) FadeTo(weight, duration float32) {
+	if duration <= 0 {
+		a.Weight, a.fadeSpeed = weight, 0
+		return
+	}
+	a.fadeTo = weight
+	a.fadeSpeed = (weight - a.Weight) / duration
+}
+
+func (a *AnimationAction) Stop() {
+	a.Playing = false
+	a.Time = 0
+}
+
+func (a *AnimationAction) advance(dt float32) {
+	if !a.Playing {
+		return
+	}
+	a.Time += dt * a.Speed
+	if a.Loop == LoopOnce && (a.Time >= a.Clip.Duration || a.Time < 0) {
+		a.Time = Clamp(a.Time, 0, a.Clip.Duration)
+		a.Finished = true
+	}
+
+	if a.fadeSpeed != 0 {
+		a.Weight += a.fadeSpeed * dt
+		if (a.fadeSpeed > 0 && a.Weight >= a.fadeTo) || (a.fadeSpeed < 0 && a.Weight <= a.fadeTo) {
+			a.Weight, a.fadeSpeed = a.fadeTo, 0
+			if a.Weight <= 0 {
+				a.Playing = false
+			}
+		}
+	}
+}
+
+// AnimationBinding reads and writes an animated property as a Vec4 (see Track)
+type AnimationBinding struct {
+	Get func() Vec4
+	Set func(Vec4)
+
+	kind   TrackKind
+	rest   Vec4 //value before animation, used where total weight < 1
+	sum    Vec4
+	weight float32
+}
+
+func (b *AnimationBinding) accumulate(v Vec4, weight float32) {
+	if b.kind == TrackQuat && b.weight > 0 && b.sum.Dot(v) < 0 {
+		v = v.Negate() //keep quaternions in the same hemisphere
+	}
+	b.sum.SetAdd(v.MulScalar(weight))
+	b.weight += weight
+}
+
+func (b *AnimationBinding) apply() {
+	if b.weight <= 0 {
+		return
+	}
+	if b.kind == TrackQuat {
+		q := NewQuat(b.sum.X, b.sum.Y, b.sum.Z, b.sum.W)
+		q.Normalize()
+		if b.weight < 1 {
+			rest := NewQuat(b.rest.X, b.rest.Y, b.rest.Z, b.rest.W)
+			rest.Slerp(q, b.weight)
+			q = rest
+		}
+		b.Set(Vec4{q.X, q.Y, q.Z, q.W})
+	} else if b.weight < 1 {
+		b.Set(b.sum.Add(b.rest.MulScalar(1 - b.weight)))
+	} else {
+		b.Set(b.sum.DivScalar(b.weight))
+	}
+	b.sum, b.weight = Vec4{}, 0
+}
+
+// Animator plays and blends animation clips onto bound properties
+type Animator struct {
+	Actions  []*AnimationAction
+	bindings map[string]*AnimationBinding
+}
+
+func NewAnimator() *Animator {
+	return &Animator{bindings: make(map[string]*AnimationBinding)}
+}
+
+func bindingKey(target, property string) string {
+	return target + "/" + property
+}
+
+// BindFunc binds a track target and property to getter and setter functions
+func (an *Animator) BindFunc(target, property string, kind TrackKind, get func() Vec4, set func(Vec4)) {
+	an.bindings[bindingKey(target, property)] = &AnimationBinding{Get: get, Set: set, kind: kind, rest: get()}
+}
+
+func (an *Animator) bindTrack(shape *Shape, track *Track) error {
+	get, set, err := shape.AnimProperty(track.Property)
+	if err != nil {
+		return err
+	}
+	an.BindFunc(track.Target, track.Property, track.Kind, get, set)
+	return nil
+}
+
+// BindShape binds all of the clip's tracks that target shape to the shape's properties by name
+func (an *Animator) BindShape(shape *Shape, clip *AnimationClip) error {
+	for _, track := range clip.Tracks {
+		if track.Target == shape.Name {
+			if err := an.bindTrack(shape, track); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BindScene binds every track in the clip to the scene shape of the same name
+func (an *Animator) BindScene(scene *Scene, clip *AnimationClip) error {
+	for _, track := range clip.Tracks {
+		shape, ok := scene.Shape(track.Target)
+		if !ok {
+			return fmt.Errorf("animation %q: shape %q not found", clip.Name, track.Target)
+		}
+		if err := an.bindTrack(shape, track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Play starts a clip at full weight
+func (an *Animator) Play(clip *AnimationClip, loop LoopMode) *AnimationAction {
+	action := an.Action(clip)
+	action.Loop, action.Time, action.Weight = loop, 0, 1
+	action.Playing, action.Finished, action.fadeSpeed = true, false, 0
+	return action
+}
+
+// Action returns the action for a clip, creating a stopped one if needed
+func (an *Animator) Action(clip *AnimationClip) *AnimationAction {
+	for _, action := range an.Actions {
+		if action.Clip == clip {
+			return action
+		}
+	}
+	action := &AnimationAction{Clip: clip, Speed: 1}
+	an.Actions = append(an.Actions, action)
+	return action
+}
+
+// CrossFade fades out every playing action and fades in clip over duration seconds
+func (an *Animator) CrossFade(clip *AnimationClip, loop LoopMode, duration float32) *AnimationAction {
+	for _, action := range an.Actions {
+		if action.Clip != clip && action.Playing {
+			action.FadeTo(0, duration)
+		}
+	}
+	to := an.Action(clip)
+	if !to.Playing {
+		to.Time, to.Weight = 0, 0
+	}
+	to.Loop, to.Playing, to.Finished = loop, true, false
+	to.FadeTo(1, duration)
+	return to
+}
+
+func (an *Animator) StopAll() {
+	for _, action := range an.Actions {
+		action.Stop()
+	}
+}
+
+// Update advances all playing actions by dt seconds and writes the blended values to the bound properties
+func (an *Animator) Update(dt float32) {
+	for _, action := range an.Actions {
+		action.advance(dt)
+		if !action.Playing || action.Weight <= 0 {
+			continue
+		}
+		time := loopTime(action.Time, action.Clip.Duration, action.Loop)
+		for _, track := range action.Clip.Tracks {
+			if b, ok := an.bindings[bindingKey(track.Target, track.Property)]; ok {
+				b.accumulate(track.Sample(time), action.Weight)
+			}
+		}
+	}
+	for _, b := range an.bindings {
+		b.apply()
+	}
+}
+
+// AnimProperty returns accessors for an animatable shape property:
+// position, rotation (Euler degrees), quaternion, scale, colour and alpha,
+// or a single component such as position.x or rotation.y
+func (s *Shape) AnimProperty(name string) (func() Vec4, func(Vec4), error) {
+	prop, comp, _ := strings.Cut(strings.ToLower(name), ".")
+
+	var vec *Vec3
+	switch prop {
+	case "position":
+		vec = &s.Position
+	case "rotation":
+		vec = &s.Rotation
+	case "scale":
+		vec = &s.Scale
+	case "quaternion":
+		get := func() Vec4 {
+			m := Identity4()
+			m.SetRotationFromEuler(s.Rotation.MulScalar(DegToRadFactor))
+			q := Quat{}
+			q.SetFromRotationMatrix(m)
+			return Vec4{q.X, q.Y, q.Z, q.W}
+		}
+		set := func(v Vec4) {
+			s.Rotation.SetEulerAnglesFromQuat(NewQuat(v.X, v.Y, v.Z, v.W))
+			s.Rotation.SetMulScalar(RadToDegFactor)
+		}
+		return get, set, nil
+	case "colour", "color":
+		get := func() Vec4 {
+			r, g, b, a := ColToRGBA(s.Colour)
+			return Vec4{r, g, b, a}
+		}
+		set := func(v Vec4) { s.Colour = RGBAToCol(v.X, v.Y, v.Z, v.W) }
+		return get, set, nil
+	case "alpha":
+		get := func() Vec4 { return Vec4{float32(s.Colour>>24) / 255, 0, 0, 0} }
+		set := func(v Vec4) { s.Colour = s.Colour&0xffffff | uint32(Clamp(v.X, 0, 1)*255)<<24 }
+		return get, set, nil
+	default:
+		return nil, nil, fmt.Errorf("shape %q has no animatable property %q", s.Name, name)
+	}
+
+	switch comp {
+	case "":
+		return func() Vec4 { return V4FromV3(*vec, 0) }, func(v Vec4) { vec.Set(v.X, v.Y, v.Z) }, nil
+	case "x":
+		return func() Vec4 { return Vec4{vec.X, 0, 0, 0} }, func(v Vec4) { vec.X = v.X }, nil
+	case "y":
+		return func() Vec4 { return Vec4{vec.Y, 0, 0, 0} }, func(v Vec4) { vec.Y = v.X }, nil
+	case "z":
+		return func() Vec4 { return Vec4{vec.Z, 0, 0, 0} }, func(v Vec4) { vec.Z = v.X }, nil
+	}
+	return nil, nil, fmt.Errorf("shape %q property %q has no component %q", s.Name, prop, comp)
+}
@@ -0,0 +1,170 @@
+package goengine
+
+import (
+	"time"
+)
+
+// FrameStats holds frame timing measured over the last StatsInterval. Times are in seconds.
+type FrameStats struct {
+	FPS          float32
+	FrameTime    float32 //average
+	MinFrameTime float32
+	MaxFrameTime float32
+	Frames       uint64 //total frames rendered
+	Updates      uint64 //total fixed updates run
+
+	intervalFrames int
+	intervalTime   float32
+	intervalMin    float32
+	intervalMax    float32
+}
+
+const StatsInterval = 0.5
+
+func (fs *FrameStats) addFrame(frameTime float32) {
+	fs.Frames++
+	if fs.intervalFrames == 0 || frameTime < fs.intervalMin {
+		fs.intervalMin = frameTime
+	}
+	if frameTime > fs.intervalMax {
+		fs.intervalMax = frameTime
+	}
+	fs.intervalFrames++
+	fs.intervalTime += frameTime
+
+	if fs.intervalTime >= StatsInterval {
+		fs.FrameTime = fs.intervalTime / float32(fs.intervalFrames)
+		fs.FPS = float32(fs.intervalFrames) / fs.intervalTime
+		fs.MinFrameTime, fs.MaxFrameTime = fs.intervalMin, fs.intervalMax
+		fs.intervalFrames, fs.intervalTime, fs.intervalMax = 0, 0, 0
+	}
+}
+
+// App runs a scene with a fixed timestep game loop.
+// Update is called UpdateRate times per second of game time with a constant dt.
+// Render is called once per frame with alpha (0..1), the fraction of an update step
+// that has elapsed since the last Update, for interpolating between the last two states.
+// If Render is nil the scene is drawn directly. OnInput is called once per frame after
+// the events have been read, so one-shot key presses are seen exactly once.
+// If World is set its systems are run every update step before Update.
+// While the scene's Recorder is recording in fixed timestep mode each frame advances game time by one recorded frame.
+type App struct {
+	Scene        *Scene
+	World        *World
+	Input        UserInput
+	UpdateRate   float32 //0 or less is 60
+	MaxFrameTime float32 //Longer frames are clamped to avoid a spiral of catch-up updates. 0 or less is 0.25.
+	TimeScale    float32
+	Paused       bool
+	Stats        FrameStats
+	Time         float32 //Game time in seconds (scaled and stops while paused)
+
+	OnInput func(ui *UserInput)
+	Update  func(dt float32)
+	Render  func(alpha float32)
+
+	quit        bool
+	accumulator float32 //game time not yet run by Update
+}
+
+const (
+	defaultUpdateRate   = 60
+	defaultMaxFrameTime = 0.25
+)
+
+func NewApp(scene *Scene) *App {
+	return &App{
+		Scene:        scene,
+		UpdateRate:   defaultUpdateRate,
+		MaxFrameTime: defaultMaxFrameTime,
+		TimeScale:    1,
+	}
+}
+
+// Quit stops the loop at the end of the current frame
+func (a *App) Quit() {
+	a.quit = true
+}
+
+func (a *App) SetPaused(paused bool) {
+	a.Paused = paused
+}
+
+func (a *App) TogglePause() {
+	a.Paused = !a.Paused
+}
+
+// Run executes the loop until Quit is called or the user closes the window, then quits the scene
+func (a *App) Run() {
+	prev := time.Now()
+
+	for !a.quit && !a.Input.Quit {
+		a.Input.GetUserInput()
+		if a.OnInput != nil {
+			a.OnInput(&a.Input)
+		}
+
+		now := time.Now()
+		frameTime := float32(now.Sub(prev).Seconds())
+		prev = now
+		a.Stats.addFrame(frameTime)
+		frameTime = min(frameTime, a.maxFrameTime())
+		if r := a.Scene.Recorder; r != nil && r.FrameTime() > 0 {
+			frameTime = r.FrameTime()
+		}
+
+		//Cameras follow real time so they can still be moved while paused
+		a.Scene.Update(&a.Input, frameTime)
+		alpha := a.advance(frameTime)
+
+		if a.Input.Minimised {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+
+		if a.Render != nil {
+			a.Render(alpha)
+		} else {
+			a.Scene.Draw()
+		}
+		a.Scene.Swap()
+	}
+
+	a.Scene.Quit()
+}
+
+// advance adds a frame's time to the game time, scaled by TimeScale unless paused, and runs
+// the fixed updates it covers. It returns alpha, the fraction of a step left over.
+func (a *App) advance(frameTime float32) float32 {
+	step := a.step()
+	if !a.Paused {
+		a.accumulator += frameTime * a.TimeScale
+	}
+	for a.accumulator >= step {
+		if a.World != nil {
+			a.World.Update(step)
+		}
+		if a.Update != nil {
+			a.Update(step)
+		}
+		a.Time += step
+		a.Stats.Updates++
+		a.accumulator -= step
+	}
+	return a.accumulator / step
+}
+
+// step returns the fixed update time step
+func (a *App) step() float32 {
+	if a.UpdateRate <= 0 {
+		return 1.0 / defaultUpdateRate
+	}
+	return 1 / a.UpdateRate
+}
+
+func (a *App) maxFrameTime() float32 {
+	if a.MaxFrameTime <= 0 {
+		return defaultMaxFrameTime
+	}
+	return a.MaxFrameTime
+}
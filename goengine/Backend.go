@@ -0,0 +1,147 @@
+package goengine
+
+import (
+	"image"
+)
+
+// DrawMode is the primitive type for a draw call. The values match the GL enums.
+type DrawMode int
+
+const (
+	DrawPoints        DrawMode = 0
+	DrawLines         DrawMode = 1
+	DrawTriangles     DrawMode = 4
+	DrawTriangleStrip DrawMode = 5
+)
+
+// IndexType is the size of the indices in an index buffer. The values match the GL enums.
+type IndexType int
+
+const (
+	Index16 IndexType = 0x1403 //GL_UNSIGNED_SHORT
+	Index32 IndexType = 0x1405 //GL_UNSIGNED_INT
+)
+
+// VertexAttrib binds a shader attribute location to floats within each vertex of a buffer
+type VertexAttrib struct {
+	Location   uint32
+	Size       int        //number of components
+	Offset     int        //in floats from the start of the vertex
+	Type       AttribType //0 is AttribFloat
+	Normalized bool
+}
+
+func (a *VertexAttrib) glType() uint32 {
+	if a.Type == 0 {
+		return uint32(AttribFloat)
+	}
+	return uint32(a.Type)
+}
+
+// Backend is the set of graphics operations used by the scene, renderer, buffers and
+// textures. Handles are backend specific and 0 is never a valid handle.
+type Backend interface {
+	Name() string
+
+	// Init sets up default render state once a context has been created
+	Init(opts SceneOptions) error
+	Viewport(x, y, w, h int32)
+	// Clear clears colour and depth within the rectangle (the whole target if w or h is 0)
+	Clear(col uint32, x, y, w, h int32)
+
+	CreateBuffer(data []float32, dynamic bool) (uint32, error)
+	// UpdateBuffer copies data into a buffer starting offset floats in
+	UpdateBuffer(buf uint32, offset int, data []float32)
+	// CreateIndexBuffer stores indices as 16 or 32-bit values. Delete it with DeleteBuffer.
+	CreateIndexBuffer(indices []uint32, typ IndexType) (uint32, error)
+	DeleteBuffer(buf uint32)
+
+	CreateTexture(img *image.RGBA, smooth bool) (uint32, error)
+	BindTexture(unit int, tex uint32)
+	DeleteTexture(tex uint32)
+
+	// CreateProgram compiles and links a shader program, returning its attributes in location order
+	CreateProgram(vertexSrc, fragmentSrc string) (uint32, []string, error)
+	UseProgram(program uint32)
+	DeleteProgram(program uint32)
+	// UniformLocation returns -1 for uniforms the program doesn't use; setting -1 is ignored
+	UniformLocation(program uint32, name string) int32
+	SetUniformInt(loc int32, v int32)
+	SetUniformFloats(loc int32, v ...float32) //float, vec2, vec3 or vec4 by the number of values
+	SetUniformMatrix(loc int32, m *Mat4s)
+
+	// BindVertexBuffer sets up the attributes of a buffer whose vertices are stride floats apart
+	BindVertexBuffer(buf uint32, stride int, attribs []VertexAttrib)
+	UnbindVertexBuffer(attribs []VertexAttrib)
+	DrawArrays(mode DrawMode, first, count int)
+	// DrawElements draws count vertices of the bound vertex buffer, numbered by an index buffer from index first
+	DrawElements(mode DrawMode, buf uint32, typ IndexType, first, count int)
+	// SetRenderState sets blending, face culling and depth testing for the draws that follow
+	SetRenderState(state RenderState)
+}
+
+// FixedFunctionBackend is implemented by backends that can draw shapes in immediate
+// mode with the fixed-function matrix stacks. Other backends draw with a Renderer.
+type FixedFunctionBackend interface {
+	Backend
+	LoadMatrices(proj, view *Mat4s)
+	// SetLights replaces the default light with lights placed in the world by the view matrix
+	// last loaded. No lights restores the default light.
+	SetLights(lights []*Light)
+	// DrawShape draws a shape placed in the world by model, with its colour, texture and
+	// render state or those of its material
+	DrawShape(shape *Shape, model *Mat4s)
+	// DrawMesh draws a mesh's triangles placed in the world by model, e.g. a mesh deformed
+	// on the CPU each frame
+	DrawMesh(mesh *Mesh, model *Mat4s, col uint32, tex Texture)
+}
+
+// OffscreenBackend is implemented by backends that can draw into offscreen render
+// targets and read pixels back
+type OffscreenBackend interface {
+	Backend
+	// CreateRenderTarget creates a w x h colour and depth target to draw into
+	CreateRenderTarget(w, h int32) (uint32, error)
+	// BindRenderTarget directs drawing to a target, or back to the window if target is 0
+	BindRenderTarget(target uint32)
+	DeleteRenderTarget(target uint32)
+	// ReadPixels reads from the bound target with the bottom row first, as OpenGL stores them
+	ReadPixels(x, y, w, h int32) *image.RGBA
+}
+
+// ShadowBackend is implemented by backends that can draw depth into a texture for shadow maps
+type ShadowBackend interface {
+	OffscreenBackend
+	// CreateDepthTarget creates a w x h depth only target, returning it and its depth texture
+	// to bind with BindTexture. Delete it with DeleteRenderTarget.
+	CreateDepthTarget(w, h int32) (target, texture uint32, err error)
+}
+
+// InstancingBackend is implemented by backends that can draw many copies of a vertex
+// buffer in one call, with per-instance attributes from a second buffer
+type InstancingBackend interface {
+	Backend
+	// Instancing reports whether the context has instanced arrays. It is only known after Init.
+	Instancing() bool
+	// BindInstanceBuffer sets up attributes that advance once per instance, stride floats apart
+	BindInstanceBuffer(buf uint32, stride int, attribs []VertexAttrib)
+	UnbindInstanceBuffer(attribs []VertexAttrib)
+	DrawArraysInstanced(mode DrawMode, first, count, instances int)
+	DrawElementsInstanced(mode DrawMode, buf uint32, typ IndexType, first, count, instances int)
+}
+
+var currentBackend Backend
+
+// SetBackend selects the backend used for all graphics operations. Call it before
+// creating a scene, or set SceneOptions.Backend.
+func SetBackend(b Backend) {
+	currentBackend = b
+}
+
+// CurrentBackend returns the selected backend, defaulting to OpenGL 2.1
+func CurrentBackend() Backend {
+	if currentBackend == nil {
+		currentBackend = &GL21Backend{}
+	}
+	return currentBackend
+}
@@ -0,0 +1,698 @@
+package goengine
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/chewxy/math32"
+	"github.com/go-gl/gl/v2.1/gl"
+)
+
+// The fixed-function light, shared with the SoftwareBackend
+var (
+	fixedModelAmbient  = [4]float32{0.2, 0.2, 0.2, 1} //the GL default light model ambient
+	fixedLightAmbient  = [4]float32{0.5, 0.5, 0.5, 1}
+	fixedLightDiffuse  = [4]float32{1, 1, 1, 1}
+	fixedLightPosition = [4]float32{-5, 5, 10, 0} //directional, in eye space
+)
+
+// GL21Backend draws with OpenGL 2.1 and supports the fixed-function pipeline
+type GL21Backend struct {
+	targets    map[uint32]renderTarget
+	instancing bool //GL_ARB_instanced_arrays and GL_ARB_draw_instanced are available
+	state      RenderState
+	lighting   bool
+	lights     int  //fixed-function lights set by SetLights, 0 if the default light is on
+	fbo        bool //framebuffer objects are core (GL 3.0) or from GL_ARB_framebuffer_object
+}
+
+// The fixed-function pipeline has at least 8 lights
+const fixedMaxLights = 8
+
+var errNoFramebuffers = errors.New("framebuffer objects need OpenGL 3.0 or GL_ARB_framebuffer_object")
+
+// renderTarget holds the attachments of a framebuffer object
+type renderTarget struct {
+	colour       uint32 //texture
+	depth        uint32 //renderbuffer
+	depthTexture uint32 //instead of depth, for shadow maps
+}
+
+func (b *GL21Backend) Name() string {
+	return "OpenGL 2.1"
+}
+
+func (b *GL21Backend) Init(opts SceneOptions) error {
+	if err := gl.Init(); err != nil {
+		return fmt.Errorf("gl init: %w", err)
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.Enable(gl.CULL_FACE)
+	gl.Enable(gl.NORMALIZE) //keep lighting correct on scaled shapes
+	if opts.MSAASamples > 0 {
+		gl.Enable(gl.MULTISAMPLE)
+	}
+	gl.ClearDepth(1)
+	gl.DepthFunc(gl.LEQUAL)
+	b.state = RenderState{}
+	b.lighting, b.lights = opts.Lighting, 0
+
+	extensions := gl.GoStr(gl.GetString(gl.EXTENSIONS))
+	b.instancing = strings.Contains(extensions, "GL_ARB_instanced_arrays") && strings.Contains(extensions, "GL_ARB_draw_instanced")
+	major := 0
+	fmt.Sscanf(gl.GoStr(gl.GetString(gl.VERSION)), "%d.", &major)
+	//drivers with only GL_EXT_framebuffer_object leave the unsuffixed functions nil
+	b.fbo = major >= 3 || strings.Contains(extensions, "GL_ARB_framebuffer_object")
+
+	if opts.Lighting {
+		gl.Enable(gl.LIGHTING)
+		//light shapes in their glColor like the SoftwareBackend and shaders do, rather than
+		//in GL's default grey material
+		gl.ColorMaterial(gl.FRONT_AND_BACK, gl.AMBIENT_AND_DIFFUSE)
+		gl.Enable(gl.COLOR_MATERIAL)
+		gl.Lightfv(gl.LIGHT0, gl.AMBIENT, &fixedLightAmbient[0])
+		gl.Lightfv(gl.LIGHT0, gl.DIFFUSE, &fixedLightDiffuse[0])
+		gl.Lightfv(gl.LIGHT0, gl.POSITION, &fixedLightPosition[0])
+		gl.Enable(gl.LIGHT0)
+	}
+	return nil
+}
+
+func (b *GL21Backend) Viewport(x, y, w, h int32) {
+	gl.Viewport(x, y, w, h)
+}
+
+func (b *GL21Backend) Clear(col uint32, x, y, w, h int32) {
+	if w > 0 && h > 0 {
+		gl.Scissor(x, y, w, h)
+		gl.Enable(gl.SCISSOR_TEST)
+		defer gl.Disable(gl.SCISSOR_TEST)
+	}
+	r, g, bl, a := ColToRGBA(col)
+	gl.ClearColor(r, g, bl, a)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
+
+func (b *GL21Backend) CreateBuffer(data []float32, dynamic bool) (uint32, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("buffer has no data")
+	}
+	usage := uint32(gl.STATIC_DRAW)
+	if dynamic {
+		usage = gl.DYNAMIC_DRAW
+	}
+	var buf uint32
+	gl.GenBuffers(1, &buf)
+	if buf == 0 {
+		return 0, fmt.Errorf("buffer not created")
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, buf)
+	gl.BufferData(gl.ARRAY_BUFFER, len(data)*4, gl.Ptr(data), usage)
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+	return buf, nil
+}
+
+func (b *GL21Backend) UpdateBuffer(buf uint32, offset int, data []float32) {
+	if len(data) == 0 {
+		return
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, buf)
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset*4, len(data)*4, gl.Ptr(data))
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+func (b *GL21Backend) CreateIndexBuffer(indices []uint32, typ IndexType) (uint32, error) {
+	if len(indices) == 0 {
+		return 0, fmt.Errorf("index buffer has no indices")
+	}
+	var buf uint32
+	gl.GenBuffers(1, &buf)
+	if buf == 0 {
+		return 0, fmt.Errorf("index buffer not created")
+	}
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, buf)
+	if typ == Index16 {
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*2, gl.Ptr(shortIndexes(indices)), gl.STATIC_DRAW)
+	} else {
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+	}
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
+	return buf, nil
+}
+
+func (b *GL21Backend) DeleteBuffer(buf uint32) {
+	gl.DeleteBuffers(1, &buf)
+}
+
+func (b *GL21Backend) CreateTexture(img *image.RGBA, smooth bool) (uint32, error) {
+	if img.Stride != img.Rect.Size().X*4 {
+		return 0, fmt.Errorf("unsupported stride")
+	}
+	filter := int32(gl.NEAREST)
+	if smooth {
+		filter = gl.LINEAR
+	}
+	var texture uint32
+	gl.Enable(gl.TEXTURE_2D)
+	gl.GenTextures(1, &texture)
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexImage2D(
+		gl.TEXTURE_2D,
+		0,
+		gl.RGBA,
+		int32(img.Rect.Size().X),
+		int32(img.Rect.Size().Y),
+		0,
+		gl.RGBA,
+		gl.UNSIGNED_BYTE,
+		gl.Ptr(img.Pix))
+	return texture, nil
+}
+
+func (b *GL21Backend) BindTexture(unit int, tex uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+}
+
+func (b *GL21Backend) DeleteTexture(tex uint32) {
+	gl.DeleteTextures(1, &tex)
+}
+
+func (b *GL21Backend) CreateProgram(vertexSrc, fragmentSrc string) (uint32, []string, error) {
+	attributes, errStr := GetAttributes(vertexSrc)
+	if errStr != "" {
+		return 0, nil, fmt.Errorf("vertex shader: %s", errStr)
+	}
+	vertexShader, errStr := LoadShaderStr(gl.VERTEX_SHADER, vertexSrc)
+	if errStr != "" {
+		return 0, nil, fmt.Errorf("vertex shader: %s", errStr)
+	}
+	defer gl.DeleteShader(vertexShader.handle)
+	fragShader, errStr := LoadShaderStr(gl.FRAGMENT_SHADER, fragmentSrc)
+	if errStr != "" {
+		return 0, nil, fmt.Errorf("fragment shader: %s", errStr)
+	}
+	defer gl.DeleteShader(fragShader.handle)
+	program, errStr := CreateShaderProgram(vertexShader, fragShader, attributes)
+	if errStr != "" {
+		return 0, nil, fmt.Errorf("shader program: %s", errStr)
+	}
+	return program, attributes, nil
+}
+
+func (b *GL21Backend) UseProgram(program uint32) {
+	gl.UseProgram(program)
+}
+
+func (b *GL21Backend) DeleteProgram(program uint32) {
+	gl.DeleteProgram(program)
+}
+
+func (b *GL21Backend) UniformLocation(program uint32, name string) int32 {
+	return gl.GetUniformLocation(program, gl.Str(name+"\x00"))
+}
+
+func (b *GL21Backend) SetUniformInt(loc int32, v int32) {
+	gl.Uniform1i(loc, v)
+}
+
+func (b *GL21Backend) SetUniformFloats(loc int32, v ...float32) {
+	switch len(v) {
+	case 1:
+		gl.Uniform1f(loc, v[0])
+	case 2:
+		gl.Uniform2f(loc, v[0], v[1])
+	case 3:
+		gl.Uniform3f(loc, v[0], v[1], v[2])
+	case 4:
+		gl.Uniform4f(loc, v[0], v[1], v[2], v[3])
+	}
+}
+
+func (b *GL21Backend) SetUniformMatrix(loc int32, m *Mat4s) {
+	gl.UniformMatrix4fv(loc, 1, false, &m.ToGLArray()[0])
+}
+
+func (b *GL21Backend) BindVertexBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	gl.BindBuffer(gl.ARRAY_BUFFER, buf)
+	for _, att := range attribs {
+		gl.EnableVertexAttribArray(att.Location)
+		gl.VertexAttribPointer(att.Location, int32(att.Size), att.glType(), att.Normalized, int32(stride*4), gl.PtrOffset(att.Offset*4))
+	}
+}
+
+func (b *GL21Backend) UnbindVertexBuffer(attribs []VertexAttrib) {
+	for _, att := range attribs {
+		gl.DisableVertexAttribArray(att.Location)
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+func (b *GL21Backend) DrawArrays(mode DrawMode, first, count int) {
+	gl.DrawArrays(uint32(mode), int32(first), int32(count))
+}
+
+func (b *GL21Backend) Instancing() bool {
+	return b.instancing
+}
+
+func (b *GL21Backend) BindInstanceBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	b.BindVertexBuffer(buf, stride, attribs)
+	for _, att := range attribs {
+		gl.VertexAttribDivisorARB(att.Location, 1)
+	}
+}
+
+func (b *GL21Backend) UnbindInstanceBuffer(attribs []VertexAttrib) {
+	for _, att := range attribs {
+		gl.VertexAttribDivisorARB(att.Location, 0)
+	}
+	b.UnbindVertexBuffer(attribs)
+}
+
+func (b *GL21Backend) DrawArraysInstanced(mode DrawMode, first, count, instances int) {
+	gl.DrawArraysInstancedARB(uint32(mode), int32(first), int32(count), int32(instances))
+}
+
+func (b *GL21Backend) DrawElements(mode DrawMode, buf uint32, typ IndexType, first, count int) {
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, buf)
+	gl.DrawElements(uint32(mode), int32(count), uint32(typ), gl.PtrOffset(first*indexSize(typ)))
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
+}
+
+func (b *GL21Backend) DrawElementsInstanced(mode DrawMode, buf uint32, typ IndexType, first, count, instances int) {
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, buf)
+	gl.DrawElementsInstancedARB(uint32(mode), int32(count), uint32(typ), gl.PtrOffset(first*indexSize(typ)), int32(instances))
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
+}
+
+func (b *GL21Backend) LoadMatrices(proj, view *Mat4s) {
+	gl.MatrixMode(gl.PROJECTION)
+	gl.LoadMatrixf(&proj.ToGLArray()[0])
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.LoadMatrixf(&view.ToGLArray()[0])
+}
+
+// SetLights sets up to 8 fixed-function lights. Their ambient light is the default light's.
+func (b *GL21Backend) SetLights(lights []*Light) {
+	if !b.lighting || (len(lights) == 0 && b.lights == 0) {
+		return
+	}
+	if len(lights) == 0 {
+		b.resetLights()
+		return
+	}
+	ambient := [4]float32{}
+	gl.LightModelfv(gl.LIGHT_MODEL_AMBIENT, &fixedLightAmbient[0])
+	n := min(len(lights), fixedMaxLights)
+	for i := 0; i < fixedMaxLights; i++ {
+		light := uint32(gl.LIGHT0 + i)
+		if i >= n {
+			gl.Disable(light)
+			continue
+		}
+		l := lights[i]
+		rad := l.radiance()
+		diffuse := [4]float32{rad.X, rad.Y, rad.Z, 1}
+		pos, dir := l.WorldPosition(), l.WorldDirection()
+		position := [4]float32{pos.X, pos.Y, pos.Z, 1}
+		if l.Type == DirectionalLight {
+			position = [4]float32{-dir.X, -dir.Y, -dir.Z, 0}
+		}
+		a := l.falloff()
+		cutoff, exponent := float32(180), float32(0)
+		if l.Type == SpotLight {
+			//no inner cone, so fade to half brightness half way between the cones
+			outer, inner := l.cones()
+			cutoff = math32.Min(RadToDeg(math32.Acos(outer)), 90)
+			if half := (outer + inner) / 2; half > 0 && half < 1 {
+				exponent = Clamp(math32.Log(0.5)/math32.Log(half), 0, 128)
+			}
+		}
+		gl.Lightfv(light, gl.AMBIENT, &ambient[0])
+		gl.Lightfv(light, gl.DIFFUSE, &diffuse[0])
+		gl.Lightfv(light, gl.SPECULAR, &diffuse[0])
+		gl.Lightfv(light, gl.POSITION, &position[0])
+		gl.Lightfv(light, gl.SPOT_DIRECTION, &[]float32{dir.X, dir.Y, dir.Z}[0])
+		gl.Lightf(light, gl.SPOT_CUTOFF, cutoff)
+		gl.Lightf(light, gl.SPOT_EXPONENT, exponent)
+		gl.Lightf(light, gl.CONSTANT_ATTENUATION, a.X)
+		gl.Lightf(light, gl.LINEAR_ATTENUATION, a.Y)
+		gl.Lightf(light, gl.QUADRATIC_ATTENUATION, a.Z)
+		gl.Enable(light)
+	}
+	b.lights = n
+}
+
+// resetLights restores the default light set up by Init
+func (b *GL21Backend) resetLights() {
+	gl.LightModelfv(gl.LIGHT_MODEL_AMBIENT, &fixedModelAmbient[0])
+	for i := 1; i < fixedMaxLights; i++ {
+		gl.Disable(uint32(gl.LIGHT0 + i))
+	}
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.PushMatrix()
+	gl.LoadIdentity() //the default light is in eye space
+	gl.Lightfv(gl.LIGHT0, gl.AMBIENT, &fixedLightAmbient[0])
+	gl.Lightfv(gl.LIGHT0, gl.DIFFUSE, &fixedLightDiffuse[0])
+	gl.Lightfv(gl.LIGHT0, gl.SPECULAR, &fixedLightDiffuse[0])
+	gl.Lightfv(gl.LIGHT0, gl.POSITION, &fixedLightPosition[0])
+	gl.PopMatrix()
+	gl.Lightf(gl.LIGHT0, gl.SPOT_CUTOFF, 180)
+	gl.Lightf(gl.LIGHT0, gl.CONSTANT_ATTENUATION, 1)
+	gl.Lightf(gl.LIGHT0, gl.LINEAR_ATTENUATION, 0)
+	gl.Lightf(gl.LIGHT0, gl.QUADRATIC_ATTENUATION, 0)
+	gl.Enable(gl.LIGHT0)
+	b.lights = 0
+}
+
+func (b *GL21Backend) SetRenderState(state RenderState) {
+	if state == b.state {
+		return
+	}
+	b.state = state
+	switch state.Blend {
+	case BlendOpaque:
+		gl.Disable(gl.BLEND)
+	case BlendAlpha:
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	case BlendAdditive:
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
+	}
+	switch state.Cull {
+	case CullBack:
+		gl.Enable(gl.CULL_FACE)
+		gl.CullFace(gl.BACK)
+	case CullNone:
+		gl.Disable(gl.CULL_FACE)
+	case CullFront:
+		gl.Enable(gl.CULL_FACE)
+		gl.CullFace(gl.FRONT)
+	}
+	if state.Depth == DepthOff {
+		gl.Disable(gl.DEPTH_TEST)
+	} else {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+	gl.DepthMask(state.Depth == DepthReadWrite)
+}
+
+func (b *GL21Backend) DrawShape(shape *Shape, model *Mat4s) {
+	col, tex := shape.surface()
+	b.beginDraw(model, col, tex, shape.renderState())
+	defer b.endDraw()
+
+	verts, prim := shape.geometry()
+	switch prim {
+	case primIndexed:
+		drawTriangles(verts, shape.Indexes)
+	case primQuads:
+		drawQuads(verts)
+	case primSharedQuads:
+		drawSharedQuads(verts, int(shape.Edges))
+	}
+}
+
+func (b *GL21Backend) DrawMesh(mesh *Mesh, model *Mat4s, col uint32, tex Texture) {
+	b.beginDraw(model, col, tex, RenderState{})
+	defer b.endDraw()
+
+	gl.Begin(gl.TRIANGLES)
+	mesh.Triangles(func(i0, i1, i2 int) {
+		for _, i := range [3]int{i0, i1, i2} {
+			v := mesh.Vertex(i)
+			gl.Normal3f(v.Normal.X, v.Normal.Y, v.Normal.Z)
+			gl.TexCoord2f(v.UV.X, v.UV.Y)
+			gl.Vertex3f(v.Position.X, v.Position.Y, v.Position.Z)
+		}
+	})
+	gl.End()
+}
+
+// beginDraw multiplies the model matrix onto the view and sets the colour, texture and
+// render state of an immediate mode draw
+func (b *GL21Backend) beginDraw(model *Mat4s, col uint32, tex Texture, state RenderState) {
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.PushMatrix()
+	gl.MultMatrixf(&model.ToGLArray()[0])
+	r, g, bl, a := ColToRGBA(col)
+	gl.Color4f(r, g, bl, a)
+	gl.BindTexture(gl.TEXTURE_2D, tex.id)
+	b.SetRenderState(state)
+}
+
+func (b *GL21Backend) endDraw() {
+	b.SetRenderState(RenderState{})
+	gl.MatrixMode(gl.MODELVIEW)
+	gl.PopMatrix()
+}
+
+// drawTriangles draws indexed triangles of shape vertices
+func drawTriangles(verts []float32, indexes []int) {
+	gl.Begin(gl.TRIANGLES)
+	for i := 0; i+2 < len(indexes); i += 3 {
+		drawVert(verts, indexes[i]*VERTSIZE)
+		drawVert(verts, indexes[i+1]*VERTSIZE)
+		drawVert(verts, indexes[i+2]*VERTSIZE)
+	}
+	gl.End()
+}
+
+// drawQuads draws shape vertices as separate quads
+func drawQuads(verts []float32) {
+	vstep := VERTSIZE
+	nextQuad := 4 * vstep
+	quadCount := len(verts) / nextQuad
+	i := 0
+
+	gl.Begin(gl.QUADS)
+	for q := 0; q < quadCount; q++ {
+		drawVert(verts, i)
+		drawVert(verts, i+vstep)
+		drawVert(verts, i+vstep*2)
+		drawVert(verts, i+vstep*3)
+		i += nextQuad
+	}
+	gl.End()
+}
+
+// drawSharedQuads draws shape vertices as rings of edges+1 vertices joined by quads
+func drawSharedQuads(verts []float32, edges int) {
+	vstep := VERTSIZE
+	nextLevel := int(edges+1) * vstep //There's an extra edge for the quads to seamlessly join
+	pathLength := len(verts) / nextLevel
+
+	gl.Begin(gl.QUADS)
+	for p := 0; p < pathLength-1; p++ {
+		i := p * nextLevel
+		for e := 0; e < edges; e++ {
+			drawVert(verts, i+vstep)
+			drawVert(verts, i)
+			drawVert(verts, i+nextLevel)
+			drawVert(verts, i+vstep+nextLevel)
+			i += vstep
+		}
+	}
+	gl.End()
+}
+
+func drawVert(verts []float32, i int) {
+	gl.Normal3f(verts[i+3], verts[i+4], verts[i+5])
+	gl.TexCoord2f(verts[i+6], verts[i+7])
+	gl.Vertex3f(verts[i], verts[i+1], verts[i+2])
+}
+
+// The helpers below are the GL 2.1 calls behind CreateProgram and the uniform setters,
+// kept for code that works with OpenGL directly
+
+// Shader is a compiled GL shader object
+type Shader struct {
+	handle uint32
+}
+
+func LoadShaderStr(shaderType uint32, shaderSource string) (*Shader, string) {
+
+	var shader = gl.CreateShader(shaderType)
+	glSrcs, freeFn := gl.Strs(shaderSource + "\x00")
+	defer freeFn()
+	gl.ShaderSource(shader, 1, glSrcs, nil)
+	gl.CompileShader(shader)
+
+	compileOK := int32(0)
+	gl.GetShaderiv(shader, gl.COMPILE_STATUS, &compileOK)
+
+	if compileOK == 0 {
+		loglength := int32(0)
+		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &loglength)
+		logErr := gl.Str(strings.Repeat(" ", int(loglength))) //Create a string long enough to hold error message
+		gl.GetShaderInfoLog(shader, loglength, nil, logErr)
+		return nil, "Compile error: " + string(*logErr)
+	}
+
+	return &Shader{handle: shader}, ""
+}
+
+func CreateShaderProgram(vertexShader, fragShader *Shader, attributes []string) (uint32, string) {
+	program := gl.CreateProgram()
+	if program == 0 {
+		return 0, "Program not created"
+	}
+
+	gl.AttachShader(program, vertexShader.handle)
+	gl.AttachShader(program, fragShader.handle)
+
+	for i, att := range attributes {
+		gl.BindAttribLocation(program, uint32(i), gl.Str(att+"\x00"))
+	}
+
+	gl.LinkProgram(program)
+
+	linkOK := int32(0)
+	gl.GetProgramiv(program, gl.LINK_STATUS, &linkOK)
+	if linkOK == 0 {
+		loglength := int32(0)
+		gl.GetProgramiv(program, gl.INFO_LOG_LENGTH, &loglength)
+		logErr := gl.Str(strings.Repeat(" ", int(loglength)))
+		gl.GetShaderInfoLog(program, loglength, nil, logErr)
+		return 0, "Shader link failure: " + string(*logErr)
+	}
+
+	gl.DetachShader(program, vertexShader.handle)
+	gl.DetachShader(program, fragShader.handle)
+
+	return program, ""
+}
+
+func GLVersion() string {
+	var logErr = gl.GetString(gl.VERSION)
+	return string(*logErr)
+}
+
+func GetSetInt(program uint32, name *uint8, v int32) int32 {
+	loc := gl.GetUniformLocation(program, name)
+	if loc >= 0 {
+		gl.Uniform1i(loc, v)
+	}
+	return loc
+}
+
+func GetSetFloat(program uint32, name *uint8, v float32) int32 {
+	loc := gl.GetUniformLocation(program, name)
+	if loc >= 0 {
+		gl.Uniform1f(loc, v)
+	}
+	return loc
+}
+
+func GetSetVec3(program uint32, name *uint8, vec []float32) int32 {
+	loc := gl.GetUniformLocation(program, name)
+	if loc >= 0 {
+		gl.Uniform3fv(loc, 1, &vec[0])
+	}
+	return loc
+}
+
+func (b *GL21Backend) CreateRenderTarget(w, h int32) (uint32, error) {
+	if !b.fbo {
+		return 0, errNoFramebuffers
+	}
+	var rt renderTarget
+	gl.GenTextures(1, &rt.colour)
+	gl.BindTexture(gl.TEXTURE_2D, rt.colour)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA8, w, h, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.GenRenderbuffers(1, &rt.depth)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, rt.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, w, h)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, rt.colour, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, rt.depth)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if b.targets == nil {
+		b.targets = make(map[uint32]renderTarget)
+	}
+	b.targets[fbo] = rt
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		b.DeleteRenderTarget(fbo)
+		return 0, fmt.Errorf("framebuffer incomplete: 0x%x", status)
+	}
+	return fbo, nil
+}
+
+func (b *GL21Backend) BindRenderTarget(target uint32) {
+	if b.fbo {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, target)
+	}
+}
+
+func (b *GL21Backend) DeleteRenderTarget(target uint32) {
+	rt, ok := b.targets[target]
+	if !ok {
+		return
+	}
+	delete(b.targets, target)
+	gl.DeleteFramebuffers(1, &target)
+	gl.DeleteRenderbuffers(1, &rt.depth)
+	gl.DeleteTextures(1, &rt.colour)
+	gl.DeleteTextures(1, &rt.depthTexture)
+}
+
+func (b *GL21Backend) CreateDepthTarget(w, h int32) (uint32, uint32, error) {
+	if !b.fbo {
+		return 0, 0, errNoFramebuffers
+	}
+	var rt renderTarget
+	gl.GenTextures(1, &rt.depthTexture)
+	gl.BindTexture(gl.TEXTURE_2D, rt.depthTexture)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_COMPARE_MODE, gl.NONE) //sampled as the depth in r
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT24, w, h, 0, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, rt.depthTexture, 0)
+	gl.DrawBuffer(gl.NONE) //no colour attachment
+	gl.ReadBuffer(gl.NONE)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if b.targets == nil {
+		b.targets = make(map[uint32]renderTarget)
+	}
+	b.targets[fbo] = rt
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		b.DeleteRenderTarget(fbo)
+		return 0, 0, fmt.Errorf("depth framebuffer incomplete: 0x%x", status)
+	}
+	return fbo, rt.depthTexture, nil
+}
+
+func (b *GL21Backend) ReadPixels(x, y, w, h int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(x, y, w, h, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	return img
+}
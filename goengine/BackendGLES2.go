@@ -0,0 +1,463 @@
+package goengine
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	s "strings"
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.1/gles2"
+)
+
+// GLES2Backend draws with OpenGL ES 2.0, e.g. on a Raspberry Pi. There is no immediate mode,
+// quads or fixed-function lighting, so shapes are drawn as triangles by a Renderer.
+// On desktop Linux it can be tested with Mesa's software driver by setting LIBGL_ALWAYS_SOFTWARE=1.
+type GLES2Backend struct {
+	targets map[uint32]renderTarget
+
+	uintIndices   bool //32-bit indices are core in ES 3.0 and an extension in ES 2.0
+	depthTextures bool //core in ES 3.0, GL_OES_depth_texture in ES 2.0
+
+	//instanced arrays are core in ES 3.0 and an extension in ES 2.0, nil if unavailable
+	attribDivisor        func(index, divisor uint32)
+	drawInstanced        func(mode uint32, first, count, instances int32)
+	drawElementsInstance func(mode uint32, count int32, typ uint32, indices unsafe.Pointer, instances int32)
+
+	state RenderState
+}
+
+// GLESSceneOptions returns options for an OpenGL ES 2.0 context using the GLES2Backend
+func GLESSceneOptions(w, h int32) SceneOptions {
+	opts := DefaultSceneOptions(w, h)
+	opts.GLMajor, opts.GLMinor = 2, 0
+	opts.GLProfile = GLProfileES
+	opts.Lighting = false
+	opts.Backend = &GLES2Backend{}
+	return opts
+}
+
+func (b *GLES2Backend) Name() string {
+	return "OpenGL ES 2.0"
+}
+
+func (b *GLES2Backend) Init(opts SceneOptions) error {
+	if err := gles2.Init(); err != nil {
+		return fmt.Errorf("gles2 init: %w", err)
+	}
+	gles2.Enable(gles2.DEPTH_TEST)
+	gles2.Enable(gles2.CULL_FACE)
+	gles2.ClearDepthf(1)
+	gles2.DepthFunc(gles2.LEQUAL)
+	b.state = RenderState{}
+
+	extensions := gles2.GoStr(gles2.GetString(gles2.EXTENSIONS))
+	es3 := s.HasPrefix(gles2.GoStr(gles2.GetString(gles2.VERSION)), "OpenGL ES 3")
+	b.uintIndices = es3 || s.Contains(extensions, "GL_OES_element_index_uint")
+	b.depthTextures = es3 || s.Contains(extensions, "GL_OES_depth_texture")
+	switch {
+	case es3:
+		b.attribDivisor, b.drawInstanced, b.drawElementsInstance = gles2.VertexAttribDivisor, gles2.DrawArraysInstanced, gles2.DrawElementsInstanced
+	case s.Contains(extensions, "GL_EXT_instanced_arrays"):
+		b.attribDivisor, b.drawInstanced, b.drawElementsInstance = gles2.VertexAttribDivisorEXT, gles2.DrawArraysInstancedEXT, gles2.DrawElementsInstancedEXT
+	case s.Contains(extensions, "GL_ANGLE_instanced_arrays"):
+		b.attribDivisor, b.drawInstanced, b.drawElementsInstance = gles2.VertexAttribDivisorANGLE, gles2.DrawArraysInstancedANGLE, gles2.DrawElementsInstancedANGLE
+	default:
+		b.attribDivisor, b.drawInstanced, b.drawElementsInstance = nil, nil, nil
+	}
+	return nil
+}
+
+func (b *GLES2Backend) Viewport(x, y, w, h int32) {
+	gles2.Viewport(x, y, w, h)
+}
+
+func (b *GLES2Backend) Clear(col uint32, x, y, w, h int32) {
+	if w > 0 && h > 0 {
+		gles2.Scissor(x, y, w, h)
+		gles2.Enable(gles2.SCISSOR_TEST)
+		defer gles2.Disable(gles2.SCISSOR_TEST)
+	}
+	r, g, bl, a := ColToRGBA(col)
+	gles2.ClearColor(r, g, bl, a)
+	gles2.Clear(gles2.COLOR_BUFFER_BIT | gles2.DEPTH_BUFFER_BIT)
+}
+
+func (b *GLES2Backend) CreateBuffer(data []float32, dynamic bool) (uint32, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("buffer has no data")
+	}
+	usage := uint32(gles2.STATIC_DRAW)
+	if dynamic {
+		usage = gles2.DYNAMIC_DRAW
+	}
+	var buf uint32
+	gles2.GenBuffers(1, &buf)
+	if buf == 0 {
+		return 0, fmt.Errorf("buffer not created")
+	}
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, buf)
+	gles2.BufferData(gles2.ARRAY_BUFFER, len(data)*4, gles2.Ptr(data), usage)
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, 0)
+	return buf, nil
+}
+
+func (b *GLES2Backend) UpdateBuffer(buf uint32, offset int, data []float32) {
+	if len(data) == 0 {
+		return
+	}
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, buf)
+	gles2.BufferSubData(gles2.ARRAY_BUFFER, offset*4, len(data)*4, gles2.Ptr(data))
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, 0)
+}
+
+func (b *GLES2Backend) CreateIndexBuffer(indices []uint32, typ IndexType) (uint32, error) {
+	if len(indices) == 0 {
+		return 0, fmt.Errorf("index buffer has no indices")
+	}
+	if typ == Index32 && !b.uintIndices {
+		return 0, fmt.Errorf("32-bit indices need GL_OES_element_index_uint")
+	}
+	var buf uint32
+	gles2.GenBuffers(1, &buf)
+	if buf == 0 {
+		return 0, fmt.Errorf("index buffer not created")
+	}
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, buf)
+	if typ == Index16 {
+		gles2.BufferData(gles2.ELEMENT_ARRAY_BUFFER, len(indices)*2, gles2.Ptr(shortIndexes(indices)), gles2.STATIC_DRAW)
+	} else {
+		gles2.BufferData(gles2.ELEMENT_ARRAY_BUFFER, len(indices)*4, gles2.Ptr(indices), gles2.STATIC_DRAW)
+	}
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, 0)
+	return buf, nil
+}
+
+func (b *GLES2Backend) DeleteBuffer(buf uint32) {
+	gles2.DeleteBuffers(1, &buf)
+}
+
+// CreateTexture uploads an image. Non power of two sizes are fine as ES 2.0 allows them
+// with clamped edges and no mipmaps.
+func (b *GLES2Backend) CreateTexture(img *image.RGBA, smooth bool) (uint32, error) {
+	if img.Stride != img.Rect.Size().X*4 {
+		return 0, fmt.Errorf("unsupported stride")
+	}
+	filter := int32(gles2.NEAREST)
+	if smooth {
+		filter = gles2.LINEAR
+	}
+	var texture uint32
+	gles2.GenTextures(1, &texture)
+	gles2.BindTexture(gles2.TEXTURE_2D, texture)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MIN_FILTER, filter)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MAG_FILTER, filter)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_S, gles2.CLAMP_TO_EDGE)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_T, gles2.CLAMP_TO_EDGE)
+	gles2.TexImage2D(
+		gles2.TEXTURE_2D,
+		0,
+		gles2.RGBA,
+		int32(img.Rect.Size().X),
+		int32(img.Rect.Size().Y),
+		0,
+		gles2.RGBA,
+		gles2.UNSIGNED_BYTE,
+		gles2.Ptr(img.Pix))
+	return texture, nil
+}
+
+func (b *GLES2Backend) BindTexture(unit int, tex uint32) {
+	gles2.ActiveTexture(gles2.TEXTURE0 + uint32(unit))
+	gles2.BindTexture(gles2.TEXTURE_2D, tex)
+}
+
+func (b *GLES2Backend) DeleteTexture(tex uint32) {
+	gles2.DeleteTextures(1, &tex)
+}
+
+var precisionRe = regexp.MustCompile(`(?m)^\s*precision\s`)
+
+// ESShaderSource prepares a desktop GLSL 1.10 shader for GLSL ES 1.00 by adding a default
+// float precision if the shader doesn't declare one. Fragment shaders use highp where available.
+func ESShaderSource(src string, fragment bool) string {
+	if precisionRe.MatchString(src) {
+		return src
+	}
+	if fragment {
+		return "#ifdef GL_FRAGMENT_PRECISION_HIGH\nprecision highp float;\n#else\nprecision mediump float;\n#endif\n" + src
+	}
+	return "precision highp float;\n" + src
+}
+
+func (b *GLES2Backend) CreateProgram(vertexSrc, fragmentSrc string) (uint32, []string, error) {
+	attributes, errStr := GetAttributes(vertexSrc)
+	if errStr != "" {
+		return 0, nil, fmt.Errorf("vertex shader: %s", errStr)
+	}
+	vertexShader, err := compileES(gles2.VERTEX_SHADER, ESShaderSource(vertexSrc, false))
+	if err != nil {
+		return 0, nil, fmt.Errorf("vertex shader: %w", err)
+	}
+	defer gles2.DeleteShader(vertexShader)
+	fragShader, err := compileES(gles2.FRAGMENT_SHADER, ESShaderSource(fragmentSrc, true))
+	if err != nil {
+		return 0, nil, fmt.Errorf("fragment shader: %w", err)
+	}
+	defer gles2.DeleteShader(fragShader)
+
+	program := gles2.CreateProgram()
+	if program == 0 {
+		return 0, nil, fmt.Errorf("program not created")
+	}
+	gles2.AttachShader(program, vertexShader)
+	gles2.AttachShader(program, fragShader)
+	for i, att := range attributes {
+		gles2.BindAttribLocation(program, uint32(i), gles2.Str(att+"\x00"))
+	}
+	gles2.LinkProgram(program)
+
+	linkOK := int32(0)
+	gles2.GetProgramiv(program, gles2.LINK_STATUS, &linkOK)
+	if linkOK == 0 {
+		loglength := int32(0)
+		gles2.GetProgramiv(program, gles2.INFO_LOG_LENGTH, &loglength)
+		logErr := s.Repeat("\x00", int(loglength+1))
+		gles2.GetProgramInfoLog(program, loglength, nil, gles2.Str(logErr))
+		gles2.DeleteProgram(program)
+		return 0, nil, fmt.Errorf("shader link failure: %s", s.TrimRight(logErr, "\x00"))
+	}
+	gles2.DetachShader(program, vertexShader)
+	gles2.DetachShader(program, fragShader)
+	return program, attributes, nil
+}
+
+func compileES(shaderType uint32, source string) (uint32, error) {
+	shader := gles2.CreateShader(shaderType)
+	srcs, free := gles2.Strs(source + "\x00")
+	defer free()
+	gles2.ShaderSource(shader, 1, srcs, nil)
+	gles2.CompileShader(shader)
+
+	compileOK := int32(0)
+	gles2.GetShaderiv(shader, gles2.COMPILE_STATUS, &compileOK)
+	if compileOK == 0 {
+		loglength := int32(0)
+		gles2.GetShaderiv(shader, gles2.INFO_LOG_LENGTH, &loglength)
+		logErr := s.Repeat("\x00", int(loglength+1))
+		gles2.GetShaderInfoLog(shader, loglength, nil, gles2.Str(logErr))
+		gles2.DeleteShader(shader)
+		return 0, fmt.Errorf("compile error: %s", s.TrimRight(logErr, "\x00"))
+	}
+	return shader, nil
+}
+
+func (b *GLES2Backend) UseProgram(program uint32) {
+	gles2.UseProgram(program)
+}
+
+func (b *GLES2Backend) DeleteProgram(program uint32) {
+	gles2.DeleteProgram(program)
+}
+
+func (b *GLES2Backend) UniformLocation(program uint32, name string) int32 {
+	return gles2.GetUniformLocation(program, gles2.Str(name+"\x00"))
+}
+
+func (b *GLES2Backend) SetUniformInt(loc int32, v int32) {
+	gles2.Uniform1i(loc, v)
+}
+
+func (b *GLES2Backend) SetUniformFloats(loc int32, v ...float32) {
+	switch len(v) {
+	case 1:
+		gles2.Uniform1f(loc, v[0])
+	case 2:
+		gles2.Uniform2f(loc, v[0], v[1])
+	case 3:
+		gles2.Uniform3f(loc, v[0], v[1], v[2])
+	case 4:
+		gles2.Uniform4f(loc, v[0], v[1], v[2], v[3])
+	}
+}
+
+// SetUniformMatrix uploads column major as ES 2.0 doesn't allow transposing
+func (b *GLES2Backend) SetUniformMatrix(loc int32, m *Mat4s) {
+	gles2.UniformMatrix4fv(loc, 1, false, &m.ToGLArray()[0])
+}
+
+func (b *GLES2Backend) BindVertexBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, buf)
+	for _, att := range attribs {
+		gles2.EnableVertexAttribArray(att.Location)
+		gles2.VertexAttribPointer(att.Location, int32(att.Size), att.glType(), att.Normalized, int32(stride*4), gles2.PtrOffset(att.Offset*4))
+	}
+}
+
+func (b *GLES2Backend) UnbindVertexBuffer(attribs []VertexAttrib) {
+	for _, att := range attribs {
+		gles2.DisableVertexAttribArray(att.Location)
+	}
+	gles2.BindBuffer(gles2.ARRAY_BUFFER, 0)
+}
+
+func (b *GLES2Backend) DrawArrays(mode DrawMode, first, count int) {
+	gles2.DrawArrays(uint32(mode), int32(first), int32(count))
+}
+
+// DrawElements draws from an index buffer made by CreateIndexBuffer, which only
+// accepts 32-bit indices when the context has GL_OES_element_index_uint
+func (b *GLES2Backend) DrawElements(mode DrawMode, buf uint32, typ IndexType, first, count int) {
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, buf)
+	gles2.DrawElements(uint32(mode), int32(count), uint32(typ), gles2.PtrOffset(first*indexSize(typ)))
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, 0)
+}
+
+func (b *GLES2Backend) SetRenderState(state RenderState) {
+	if state == b.state {
+		return
+	}
+	b.state = state
+	switch state.Blend {
+	case BlendOpaque:
+		gles2.Disable(gles2.BLEND)
+	case BlendAlpha:
+		gles2.Enable(gles2.BLEND)
+		gles2.BlendFunc(gles2.SRC_ALPHA, gles2.ONE_MINUS_SRC_ALPHA)
+	case BlendAdditive:
+		gles2.Enable(gles2.BLEND)
+		gles2.BlendFunc(gles2.SRC_ALPHA, gles2.ONE)
+	}
+	switch state.Cull {
+	case CullBack:
+		gles2.Enable(gles2.CULL_FACE)
+		gles2.CullFace(gles2.BACK)
+	case CullNone:
+		gles2.Disable(gles2.CULL_FACE)
+	case CullFront:
+		gles2.Enable(gles2.CULL_FACE)
+		gles2.CullFace(gles2.FRONT)
+	}
+	if state.Depth == DepthOff {
+		gles2.Disable(gles2.DEPTH_TEST)
+	} else {
+		gles2.Enable(gles2.DEPTH_TEST)
+	}
+	gles2.DepthMask(state.Depth == DepthReadWrite)
+}
+
+func (b *GLES2Backend) Instancing() bool {
+	return b.drawInstanced != nil
+}
+
+func (b *GLES2Backend) BindInstanceBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	b.BindVertexBuffer(buf, stride, attribs)
+	for _, att := range attribs {
+		b.attribDivisor(att.Location, 1)
+	}
+}
+
+func (b *GLES2Backend) UnbindInstanceBuffer(attribs []VertexAttrib) {
+	for _, att := range attribs {
+		b.attribDivisor(att.Location, 0)
+	}
+	b.UnbindVertexBuffer(attribs)
+}
+
+func (b *GLES2Backend) DrawArraysInstanced(mode DrawMode, first, count, instances int) {
+	b.drawInstanced(uint32(mode), int32(first), int32(count), int32(instances))
+}
+
+func (b *GLES2Backend) DrawElementsInstanced(mode DrawMode, buf uint32, typ IndexType, first, count, instances int) {
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, buf)
+	b.drawElementsInstance(uint32(mode), int32(count), uint32(typ), gles2.PtrOffset(first*indexSize(typ)), int32(instances))
+	gles2.BindBuffer(gles2.ELEMENT_ARRAY_BUFFER, 0)
+}
+
+// CreateRenderTarget uses a 16 bit depth buffer, the only size ES 2.0 guarantees
+func (b *GLES2Backend) CreateRenderTarget(w, h int32) (uint32, error) {
+	var rt renderTarget
+	gles2.GenTextures(1, &rt.colour)
+	gles2.BindTexture(gles2.TEXTURE_2D, rt.colour)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MIN_FILTER, gles2.LINEAR)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MAG_FILTER, gles2.LINEAR)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_S, gles2.CLAMP_TO_EDGE)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_T, gles2.CLAMP_TO_EDGE)
+	gles2.TexImage2D(gles2.TEXTURE_2D, 0, gles2.RGBA, w, h, 0, gles2.RGBA, gles2.UNSIGNED_BYTE, nil)
+	gles2.BindTexture(gles2.TEXTURE_2D, 0)
+
+	gles2.GenRenderbuffers(1, &rt.depth)
+	gles2.BindRenderbuffer(gles2.RENDERBUFFER, rt.depth)
+	gles2.RenderbufferStorage(gles2.RENDERBUFFER, gles2.DEPTH_COMPONENT16, w, h)
+	gles2.BindRenderbuffer(gles2.RENDERBUFFER, 0)
+
+	var fbo uint32
+	gles2.GenFramebuffers(1, &fbo)
+	gles2.BindFramebuffer(gles2.FRAMEBUFFER, fbo)
+	gles2.FramebufferTexture2D(gles2.FRAMEBUFFER, gles2.COLOR_ATTACHMENT0, gles2.TEXTURE_2D, rt.colour, 0)
+	gles2.FramebufferRenderbuffer(gles2.FRAMEBUFFER, gles2.DEPTH_ATTACHMENT, gles2.RENDERBUFFER, rt.depth)
+	status := gles2.CheckFramebufferStatus(gles2.FRAMEBUFFER)
+	gles2.BindFramebuffer(gles2.FRAMEBUFFER, 0)
+
+	if b.targets == nil {
+		b.targets = make(map[uint32]renderTarget)
+	}
+	b.targets[fbo] = rt
+	if status != gles2.FRAMEBUFFER_COMPLETE {
+		b.DeleteRenderTarget(fbo)
+		return 0, fmt.Errorf("framebuffer incomplete: 0x%x", status)
+	}
+	return fbo, nil
+}
+
+func (b *GLES2Backend) BindRenderTarget(target uint32) {
+	gles2.BindFramebuffer(gles2.FRAMEBUFFER, target)
+}
+
+func (b *GLES2Backend) DeleteRenderTarget(target uint32) {
+	rt := b.targets[target]
+	delete(b.targets, target)
+	gles2.DeleteFramebuffers(1, &target)
+	gles2.DeleteRenderbuffers(1, &rt.depth)
+	gles2.DeleteTextures(1, &rt.colour)
+	gles2.DeleteTextures(1, &rt.depthTexture)
+}
+
+func (b *GLES2Backend) CreateDepthTarget(w, h int32) (uint32, uint32, error) {
+	if !b.depthTextures {
+		return 0, 0, fmt.Errorf("depth textures need OpenGL ES 3.0 or GL_OES_depth_texture")
+	}
+	var rt renderTarget
+	gles2.GenTextures(1, &rt.depthTexture)
+	gles2.BindTexture(gles2.TEXTURE_2D, rt.depthTexture)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MIN_FILTER, gles2.NEAREST)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_MAG_FILTER, gles2.NEAREST)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_S, gles2.CLAMP_TO_EDGE)
+	gles2.TexParameteri(gles2.TEXTURE_2D, gles2.TEXTURE_WRAP_T, gles2.CLAMP_TO_EDGE)
+	gles2.TexImage2D(gles2.TEXTURE_2D, 0, gles2.DEPTH_COMPONENT, w, h, 0, gles2.DEPTH_COMPONENT, gles2.UNSIGNED_INT, nil)
+	gles2.BindTexture(gles2.TEXTURE_2D, 0)
+
+	var fbo uint32
+	gles2.GenFramebuffers(1, &fbo)
+	gles2.BindFramebuffer(gles2.FRAMEBUFFER, fbo)
+	gles2.FramebufferTexture2D(gles2.FRAMEBUFFER, gles2.DEPTH_ATTACHMENT, gles2.TEXTURE_2D, rt.depthTexture, 0)
+	status := gles2.CheckFramebufferStatus(gles2.FRAMEBUFFER)
+	gles2.BindFramebuffer(gles2.FRAMEBUFFER, 0)
+
+	if b.targets == nil {
+		b.targets = make(map[uint32]renderTarget)
+	}
+	b.targets[fbo] = rt
+	if status != gles2.FRAMEBUFFER_COMPLETE {
+		b.DeleteRenderTarget(fbo)
+		return 0, 0, fmt.Errorf("depth framebuffer incomplete: 0x%x", status)
+	}
+	return fbo, rt.depthTexture, nil
+}
+
+func (b *GLES2Backend) ReadPixels(x, y, w, h int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	gles2.PixelStorei(gles2.PACK_ALIGNMENT, 1)
+	gles2.ReadPixels(x, y, w, h, gles2.RGBA, gles2.UNSIGNED_BYTE, gles2.Ptr(img.Pix))
+	return img
+}
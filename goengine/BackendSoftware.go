@@ -0,0 +1,470 @@
+package goengine
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/chewxy/math32"
+)
+
+// SoftwareBackend renders shapes into an image on the CPU so scenes can be drawn without a
+// window or GPU, e.g. in tests on CI machines. It draws like the OpenGL 2.1 fixed-function
+// pipeline: depth tested, back faces culled, perspective correct textures and Gouraud shading
+// from the light set up by GL21Backend.Init or the lights passed to SetLights, using the shape
+// colour as the ambient and diffuse material like GL_COLOR_MATERIAL.
+// Shader programs aren't supported, so scenes using a Renderer need a GL backend.
+type SoftwareBackend struct {
+	Lighting   bool
+	FogColour  uint32
+	FogMinDist float32
+	FogMaxDist float32 //0 disables fog
+
+	softTarget //bound target
+	window     softTarget
+	targets    map[uint32]softTarget
+	viewport   [4]int32 //x, y, w, h from the bottom left like glViewport
+	proj       Mat4s
+	view       Mat4s
+	textures   map[uint32]softTexture
+	buffers    map[uint32][]float32
+	indexes    map[uint32][]uint32
+	next       uint32
+	state      RenderState
+	lights     []*Light
+}
+
+type softTarget struct {
+	colour *image.RGBA
+	depth  []float32
+}
+
+func newSoftTarget(w, h int) softTarget {
+	target := softTarget{colour: image.NewRGBA(image.Rect(0, 0, w, h)), depth: make([]float32, w*h)}
+	for i := range target.depth {
+		target.depth[i] = 1
+	}
+	return target
+}
+
+type softTexture struct {
+	img    *image.RGBA
+	smooth bool
+}
+
+// softVert is a vertex after lighting and projection
+type softVert struct {
+	clip Vec4
+	uv   Vec2
+	col  Vec4
+	fog  float32 //fraction of the lit colour kept, the rest is fog colour
+}
+
+// NewSoftwareBackend creates a backend that renders into a w x h image
+func NewSoftwareBackend(w, h int) *SoftwareBackend {
+	b := &SoftwareBackend{
+		Lighting: true,
+		targets:  make(map[uint32]softTarget),
+		textures: make(map[uint32]softTexture),
+		buffers:  make(map[uint32][]float32),
+		indexes:  make(map[uint32][]uint32),
+	}
+	b.SetSize(w, h)
+	return b
+}
+
+// SetupSoftware prepares the scene to render into an image on the CPU instead of a window.
+// Call Draw then read the frame from the returned backend's Image.
+func (s *Scene) SetupSoftware(w, h int32) *SoftwareBackend {
+	opts := DefaultSceneOptions(w, h)
+	b := NewSoftwareBackend(int(w), int(h))
+	SetBackend(b)
+	b.Init(opts)
+
+	s.Width, s.Height = w, h
+	s.WindowWidth, s.WindowHeight = w, h
+	s.SetClearColour(opts.ClearColour)
+	s.Camera = NewCamera(90, float32(w)/float32(h), 1, 100)
+	b.Viewport(0, 0, w, h)
+	return b
+}
+
+// SetSize reallocates the window's colour and depth buffers, binds them and resets the viewport
+func (b *SoftwareBackend) SetSize(w, h int) {
+	b.window = newSoftTarget(w, h)
+	b.softTarget = b.window
+	b.viewport = [4]int32{0, 0, int32(w), int32(h)}
+}
+
+// Image returns the window's frame. It is drawn over by the next frame, so copy it to keep it.
+func (b *SoftwareBackend) Image() *image.RGBA {
+	return b.window.colour
+}
+
+// SetFog fades shapes to col by their world z the same way as vs.txt
+func (b *SoftwareBackend) SetFog(col uint32, minDist, maxDist float32) {
+	b.FogColour, b.FogMinDist, b.FogMaxDist = col, minDist, maxDist
+}
+
+func (b *SoftwareBackend) Name() string {
+	return "Software"
+}
+
+func (b *SoftwareBackend) Init(opts SceneOptions) error {
+	b.Lighting = opts.Lighting
+	return nil
+}
+
+func (b *SoftwareBackend) Viewport(x, y, w, h int32) {
+	b.viewport = [4]int32{x, y, w, h}
+}
+
+// rect converts a rectangle from the bottom left to image coordinates
+func (b *SoftwareBackend) rect(x, y, w, h int32) image.Rectangle {
+	top := int32(b.colour.Rect.Dy()) - y - h
+	return image.Rect(int(x), int(top), int(x+w), int(top+h)).Intersect(b.colour.Rect)
+}
+
+func (b *SoftwareBackend) Clear(col uint32, x, y, w, h int32) {
+	r := b.colour.Rect
+	if w > 0 && h > 0 {
+		r = b.rect(x, y, w, h)
+	}
+	c := color.RGBA{uint8(col), uint8(col >> 8), uint8(col >> 16), uint8(col >> 24)}
+	stride := b.colour.Rect.Dx()
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			b.colour.SetRGBA(px, py, c)
+			b.depth[py*stride+px] = 1
+		}
+	}
+}
+
+func (b *SoftwareBackend) newHandle() uint32 {
+	b.next++
+	return b.next
+}
+
+func (b *SoftwareBackend) CreateBuffer(data []float32, dynamic bool) (uint32, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("buffer has no data")
+	}
+	buf := b.newHandle()
+	b.buffers[buf] = append([]float32(nil), data...)
+	return buf, nil
+}
+
+func (b *SoftwareBackend) UpdateBuffer(buf uint32, offset int, data []float32) {
+	copy(b.buffers[buf][offset:], data)
+}
+
+func (b *SoftwareBackend) CreateIndexBuffer(indices []uint32, typ IndexType) (uint32, error) {
+	if len(indices) == 0 {
+		return 0, fmt.Errorf("index buffer has no indices")
+	}
+	buf := b.newHandle()
+	b.indexes[buf] = append([]uint32(nil), indices...)
+	return buf, nil
+}
+
+func (b *SoftwareBackend) DeleteBuffer(buf uint32) {
+	delete(b.buffers, buf)
+	delete(b.indexes, buf)
+}
+
+func (b *SoftwareBackend) CreateTexture(img *image.RGBA, smooth bool) (uint32, error) {
+	tex := b.newHandle()
+	b.textures[tex] = softTexture{img: img, smooth: smooth}
+	return tex, nil
+}
+
+// BindTexture does nothing as DrawShape uses each shape's own texture
+func (b *SoftwareBackend) BindTexture(unit int, tex uint32) {}
+
+func (b *SoftwareBackend) DeleteTexture(tex uint32) {
+	delete(b.textures, tex)
+}
+
+func (b *SoftwareBackend) CreateProgram(vertexSrc, fragmentSrc string) (uint32, []string, error) {
+	return 0, nil, fmt.Errorf("the software backend can't run shaders")
+}
+
+func (b *SoftwareBackend) UseProgram(program uint32)                                               {}
+func (b *SoftwareBackend) DeleteProgram(program uint32)                                            {}
+func (b *SoftwareBackend) UniformLocation(program uint32, name string) int32                       { return -1 }
+func (b *SoftwareBackend) SetUniformInt(loc int32, v int32)                                        {}
+func (b *SoftwareBackend) SetUniformFloats(loc int32, v ...float32)                                {}
+func (b *SoftwareBackend) SetUniformMatrix(loc int32, m *Mat4s)                                    {}
+func (b *SoftwareBackend) BindVertexBuffer(buf uint32, stride int, attribs []VertexAttrib)         {}
+func (b *SoftwareBackend) UnbindVertexBuffer(attribs []VertexAttrib)                               {}
+func (b *SoftwareBackend) DrawArrays(mode DrawMode, first, count int)                              {}
+func (b *SoftwareBackend) DrawElements(mode DrawMode, buf uint32, typ IndexType, first, count int) {}
+func (b *SoftwareBackend) SetRenderState(state RenderState)                                        { b.state = state }
+
+func (b *SoftwareBackend) CreateRenderTarget(w, h int32) (uint32, error) {
+	if w <= 0 || h <= 0 {
+		return 0, fmt.Errorf("invalid render target size %dx%d", w, h)
+	}
+	target := b.newHandle()
+	b.targets[target] = newSoftTarget(int(w), int(h))
+	return target, nil
+}
+
+func (b *SoftwareBackend) BindRenderTarget(target uint32) {
+	if t, ok := b.targets[target]; ok {
+		b.softTarget = t
+	} else {
+		b.softTarget = b.window
+	}
+}
+
+func (b *SoftwareBackend) DeleteRenderTarget(target uint32) {
+	delete(b.targets, target)
+}
+
+func (b *SoftwareBackend) ReadPixels(x, y, w, h int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+	src := b.rect(x, y, w, h)
+	for row := 0; row < src.Dy(); row++ {
+		//bottom row first like glReadPixels
+		from := b.colour.PixOffset(src.Min.X, src.Max.Y-1-row)
+		copy(img.Pix[row*img.Stride:], b.colour.Pix[from:from+src.Dx()*4])
+	}
+	return img
+}
+
+func (b *SoftwareBackend) LoadMatrices(proj, view *Mat4s) {
+	b.proj.CopyFrom(proj)
+	b.view.CopyFrom(view)
+}
+
+// SetLights lights shapes in world space with any number of lights, like GL21Backend.SetLights
+func (b *SoftwareBackend) SetLights(lights []*Light) {
+	b.lights = lights
+}
+
+// shade returns the light reaching a vertex from the SetLights lights, with the default
+// light's ambient. Like the fixed-function pipeline there are no highlights.
+func (b *SoftwareBackend) shade(pos, normal, eye Vec3) Vec3 {
+	light := Vec3{fixedLightAmbient[0], fixedLightAmbient[1], fixedLightAmbient[2]}
+	for _, l := range b.lights {
+		diffuse, _ := l.illuminate(pos, normal, eye, 1)
+		light = light.Add(diffuse)
+	}
+	return light
+}
+
+// DrawShape rasterizes the shape's triangles with the current camera matrices
+func (b *SoftwareBackend) DrawShape(shape *Shape, model *Mat4s) {
+	col, tex := shape.surface()
+	b.SetRenderState(shape.renderState())
+	b.DrawMesh(shape.BuildMesh(), model, col, tex)
+	b.SetRenderState(RenderState{})
+}
+
+// DrawMesh rasterizes the mesh's triangles with the current camera matrices
+func (b *SoftwareBackend) DrawMesh(mesh *Mesh, model *Mat4s, col uint32, texture Texture) {
+	if len(mesh.Verts) == 0 {
+		return
+	}
+	modelView := b.view.Mul(model)
+	mvp := b.proj.Mul(modelView)
+	normalMatrix, err := modelView.Inverse()
+	if err != nil {
+		return //scaled to nothing
+	}
+	normalMatrix = normalMatrix.Transpose()
+
+	r, g, bl, a := ColToRGBA(col)
+	base := Vec4{r, g, bl, a}
+	lightDir := Vec3{fixedLightPosition[0], fixedLightPosition[1], fixedLightPosition[2]}.Normal()
+	eye := Vec3{}
+	worldNormals := &Mat4s{}
+	if len(b.lights) > 0 {
+		if inv, err := b.view.Inverse(); err == nil {
+			eye = inv.Pos()
+		}
+		if inv, err := model.Inverse(); err == nil {
+			worldNormals = inv.Transpose()
+		}
+	}
+	tex, textured := b.textures[texture.id]
+
+	tri := [3]softVert{}
+	mesh.Triangles(func(i0, i1, i2 int) {
+		for j, vert := range [3]int{i0, i1, i2} {
+			v := mesh.Vertex(vert)
+			pos := v.Position
+			sv := softVert{clip: V4FromV3(pos, 1).MulMat4(mvp), uv: v.UV, col: base, fog: 1}
+			if b.Lighting && len(b.lights) > 0 {
+				n := V4FromV3(v.Normal, 0).MulMat4(worldNormals)
+				light := b.shade(pos.MulMat4(model), Vec3{n.X, n.Y, n.Z}.Normal(), eye)
+				sv.col = Vec4{math32.Min(r*light.X, 1), math32.Min(g*light.Y, 1), math32.Min(bl*light.Z, 1), a}
+			} else if b.Lighting {
+				n := V4FromV3(v.Normal, 0).MulMat4(normalMatrix)
+				diffuse := math32.Max(Vec3{n.X, n.Y, n.Z}.Normal().Dot(lightDir), 0)
+				light := func(c int) float32 {
+					return fixedModelAmbient[c] + fixedLightAmbient[c] + fixedLightDiffuse[c]*diffuse
+				}
+				sv.col = Vec4{math32.Min(r*light(0), 1), math32.Min(g*light(1), 1), math32.Min(bl*light(2), 1), a}
+			}
+			if b.FogMaxDist > b.FogMinDist {
+				z := pos.MulMat4(model).Z
+				sv.fog = Clamp((z+b.FogMaxDist)/(b.FogMaxDist-b.FogMinDist), 0, 1)
+			}
+			tri[j] = sv
+		}
+		if textured {
+			b.clipTriangle(tri, &tex)
+		} else {
+			b.clipTriangle(tri, nil)
+		}
+	})
+}
+
+// clipTriangle clips against the near plane and rasterizes what is left
+func (b *SoftwareBackend) clipTriangle(tri [3]softVert, tex *softTexture) {
+	poly := make([]softVert, 0, 4)
+	for i := range tri {
+		cur, next := tri[i], tri[(i+1)%3]
+		dc, dn := cur.clip.Z+cur.clip.W, next.clip.Z+next.clip.W
+		if dc >= 0 {
+			poly = append(poly, cur)
+		}
+		if (dc >= 0) != (dn >= 0) {
+			poly = append(poly, lerpSoftVert(cur, next, dc/(dc-dn)))
+		}
+	}
+	for i := 2; i < len(poly); i++ {
+		b.rasterize(poly[0], poly[i-1], poly[i], tex)
+	}
+}
+
+func lerpSoftVert(a, c softVert, t float32) softVert {
+	return softVert{
+		clip: a.clip.Add(c.clip.Sub(a.clip).MulScalar(t)),
+		uv:   Vec2{a.uv.X + (c.uv.X-a.uv.X)*t, a.uv.Y + (c.uv.Y-a.uv.Y)*t},
+		col:  a.col.Add(c.col.Sub(a.col).MulScalar(t)),
+		fog:  a.fog + (c.fog-a.fog)*t,
+	}
+}
+
+// screenVert is a vertex in image coordinates with its attributes divided by w
+type screenVert struct {
+	x, y, z, invW float32
+	uv            Vec2
+	col           Vec4
+	fog           float32
+}
+
+func (b *SoftwareBackend) toScreen(v softVert) screenVert {
+	invW := 1 / v.clip.W
+	vx, vy, vw, vh := float32(b.viewport[0]), float32(b.viewport[1]), float32(b.viewport[2]), float32(b.viewport[3])
+	return screenVert{
+		x:    vx + (v.clip.X*invW+1)*0.5*vw,
+		y:    float32(b.colour.Rect.Dy()) - (vy + (v.clip.Y*invW+1)*0.5*vh),
+		z:    (v.clip.Z*invW + 1) * 0.5,
+		invW: invW,
+		uv:   Vec2{v.uv.X * invW, v.uv.Y * invW},
+		col:  v.col.MulScalar(invW),
+		fog:  v.fog * invW,
+	}
+}
+
+func edge(a, b screenVert, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+func (b *SoftwareBackend) rasterize(v0, v1, v2 softVert, tex *softTexture) {
+	s0, s1, s2 := b.toScreen(v0), b.toScreen(v1), b.toScreen(v2)
+	area := edge(s0, s1, s2.x, s2.y)
+	//clockwise on screen is counter-clockwise in GL, so a positive area is a back face
+	switch {
+	case area == 0,
+		area > 0 && b.state.Cull == CullBack,
+		area < 0 && b.state.Cull == CullFront:
+		return
+	}
+
+	bounds := b.rect(b.viewport[0], b.viewport[1], b.viewport[2], b.viewport[3])
+	minX := max(bounds.Min.X, int(math32.Floor(min(s0.x, s1.x, s2.x))))
+	maxX := min(bounds.Max.X-1, int(math32.Ceil(max(s0.x, s1.x, s2.x))))
+	minY := max(bounds.Min.Y, int(math32.Floor(min(s0.y, s1.y, s2.y))))
+	maxY := min(bounds.Max.Y-1, int(math32.Ceil(max(s0.y, s1.y, s2.y))))
+
+	fr, fg, fb, _ := ColToRGBA(b.FogColour)
+	stride := b.colour.Rect.Dx()
+	for py := minY; py <= maxY; py++ {
+		y := float32(py) + 0.5
+		for px := minX; px <= maxX; px++ {
+			x := float32(px) + 0.5
+			w0, w1, w2 := edge(s1, s2, x, y)/area, edge(s2, s0, x, y)/area, edge(s0, s1, x, y)/area
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			z := w0*s0.z + w1*s1.z + w2*s2.z
+			di := py*stride + px
+			if z < 0 || z > 1 || (b.state.Depth != DepthOff && z > b.depth[di]) {
+				continue
+			}
+
+			w := 1 / (w0*s0.invW + w1*s1.invW + w2*s2.invW)
+			col := s0.col.MulScalar(w0).Add(s1.col.MulScalar(w1)).Add(s2.col.MulScalar(w2)).MulScalar(w)
+			if tex != nil {
+				u := (w0*s0.uv.X + w1*s1.uv.X + w2*s2.uv.X) * w
+				v := (w0*s0.uv.Y + w1*s1.uv.Y + w2*s2.uv.Y) * w
+				col = col.Mul(tex.sample(u, v))
+			}
+			fog := (w0*s0.fog + w1*s1.fog + w2*s2.fog) * w
+			col.X = col.X*fog + fr*(1-fog)
+			col.Y = col.Y*fog + fg*(1-fog)
+			col.Z = col.Z*fog + fb*(1-fog)
+
+			if b.state.Depth == DepthReadWrite {
+				b.depth[di] = z
+			}
+			if b.state.Blend != BlendOpaque {
+				col = b.blend(px, py, col)
+			}
+			b.colour.SetRGBA(px, py, color.RGBA{toByte(col.X), toByte(col.Y), toByte(col.Z), toByte(col.W)})
+		}
+	}
+}
+
+// blend combines col with the pixel already drawn at x, y
+func (b *SoftwareBackend) blend(x, y int, col Vec4) Vec4 {
+	c := b.colour.RGBAAt(x, y)
+	dst := Vec4{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+	src := Vec4{col.X * col.W, col.Y * col.W, col.Z * col.W, col.W}
+	if b.state.Blend == BlendAdditive {
+		return dst.Add(src)
+	}
+	return src.Add(dst.MulScalar(1 - col.W))
+}
+
+// sample returns the texel at u, v clamped to the edges, where v = 0 is the first row of the image
+func (t *softTexture) sample(u, v float32) Vec4 {
+	size := t.img.Rect.Size()
+	x, y := u*float32(size.X)-0.5, v*float32(size.Y)-0.5
+	if !t.smooth {
+		return t.texel(int(math32.Floor(x+0.5)), int(math32.Floor(y+0.5)))
+	}
+	x0, y0 := math32.Floor(x), math32.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	top := t.texel(ix, iy).MulScalar(1 - fx).Add(t.texel(ix+1, iy).MulScalar(fx))
+	bottom := t.texel(ix, iy+1).MulScalar(1 - fx).Add(t.texel(ix+1, iy+1).MulScalar(fx))
+	return top.MulScalar(1 - fy).Add(bottom.MulScalar(fy))
+}
+
+func (t *softTexture) texel(x, y int) Vec4 {
+	size := t.img.Rect.Size()
+	x = min(max(x, 0), size.X-1)
+	y = min(max(y, 0), size.Y-1)
+	c := t.img.RGBAAt(t.img.Rect.Min.X+x, t.img.Rect.Min.Y+y)
+	return Vec4{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+}
+
+func toByte(v float32) uint8 {
+	return uint8(Clamp(v, 0, 1)*255 + 0.5)
+}
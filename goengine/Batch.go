@@ -0,0 +1,187 @@
+package goengine
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Batch is a group of static shapes sharing a texture, colour, material, layers and shadow
+// flags. Their vertices are transformed into world space and merged into one mesh, drawn in one call.
+type Batch struct {
+	Texture        Texture
+	Colour         uint32
+	Material       *Material
+	Layers         uint32
+	CastShadows    bool
+	ReceiveShadows bool
+	Shapes         []*Shape //sorted by name
+
+	mesh   *Mesh
+	centre Vec3 //mean position of the shapes, to sort transparent batches by distance
+}
+
+// Verts returns the number of vertices drawn by the batch
+func (b *Batch) Verts() int {
+	if b.mesh == nil {
+		return 0
+	}
+	return b.mesh.VertCount()
+}
+
+func (b *Batch) transparent() bool {
+	return b.Material != nil && b.Material.Transparent()
+}
+
+type batchKey struct {
+	texture        uint32
+	colour         uint32
+	material       *Material
+	layers         uint32
+	castShadows    bool
+	receiveShadows bool
+}
+
+// batchState is how a shape looked when it was last batched, to notice it changing
+type batchState struct {
+	key                       batchKey
+	position, rotation, scale Vec3
+}
+
+func shapeBatchState(shape *Shape) batchState {
+	col, tex := shape.surface()
+	return batchState{
+		key: batchKey{
+			texture:        tex.id,
+			colour:         col,
+			material:       shape.Material,
+			layers:         shape.Layers,
+			castShadows:    shape.CastShadows,
+			receiveShadows: shape.ReceiveShadows,
+		},
+		position: shape.Position,
+		rotation: shape.Rotation,
+		scale:    shape.Scale,
+	}
+}
+
+// Batches returns the renderer's batches of static shapes, as built by the last DrawShapes
+func (r *Renderer) Batches() []*Batch {
+	batches := make([]*Batch, 0, len(r.batches))
+	for _, b := range r.batches {
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].Shapes[0].Name < batches[j].Shapes[0].Name
+	})
+	return batches
+}
+
+// MarkDirty rebuilds the batch holding a static shape before it is next drawn.
+// Moving, recolouring or retexturing a static shape is noticed without it, but
+// changing its geometry isn't.
+func (r *Renderer) MarkDirty(shape *Shape) {
+	if state, ok := r.batched[shape]; ok {
+		r.dirty[state.key] = true
+	}
+}
+
+// updateBatches finds static shapes that have been added, removed or changed since the
+// last frame and rebuilds the batches they are in
+func (r *Renderer) updateBatches(shapes map[string]*Shape) {
+	seen := make(map[*Shape]bool, len(r.batched))
+	for _, shape := range shapes {
+		if !shape.Static {
+			continue
+		}
+		seen[shape] = true
+		state := shapeBatchState(shape)
+		old, ok := r.batched[shape]
+		if ok && old == state {
+			continue
+		}
+		if ok {
+			r.dirty[old.key] = true
+		} else {
+			r.Invalidate(shape) //free the mesh it was drawn with before it was static
+		}
+		r.dirty[state.key] = true
+		r.batched[shape] = state
+	}
+	for shape, state := range r.batched {
+		if !seen[shape] {
+			r.dirty[state.key] = true
+			delete(r.batched, shape)
+		}
+	}
+
+	if len(r.dirty) == 0 {
+		return
+	}
+	groups := make(map[batchKey][]*Shape, len(r.dirty))
+	for shape, state := range r.batched {
+		if r.dirty[state.key] {
+			groups[state.key] = append(groups[state.key], shape)
+		}
+	}
+	for key := range r.dirty {
+		if err := r.rebuildBatch(key, groups[key]); err != nil {
+			log.Println(err)
+		}
+		delete(r.dirty, key)
+	}
+}
+
+// rebuildBatch merges the world space vertices of shapes into the batch for key,
+// replacing its old mesh. A key with no shapes left removes the batch.
+func (r *Renderer) rebuildBatch(key batchKey, shapes []*Shape) error {
+	batch := r.batches[key]
+	if batch != nil && batch.mesh != nil {
+		r.Buffers.RemoveMesh(batch.mesh)
+		batch.mesh = nil
+	}
+	if len(shapes) == 0 {
+		delete(r.batches, key)
+		return nil
+	}
+	if batch == nil {
+		batch = &Batch{Colour: key.colour, Material: key.material, Layers: key.layers, CastShadows: key.castShadows, ReceiveShadows: key.receiveShadows}
+		r.batches[key] = batch
+	}
+	sort.Slice(shapes, func(i, j int) bool { return shapes[i].Name < shapes[j].Name })
+	batch.Shapes = shapes
+	_, batch.Texture = shapes[0].surface()
+	batch.centre = Vec3{}
+	for _, shape := range shapes {
+		batch.centre = batch.centre.Add(shape.Position)
+	}
+	batch.centre = batch.centre.MulScalar(1 / float32(len(shapes)))
+
+	merged := &Mesh{}
+	merged.Init()
+	for _, shape := range shapes {
+		mesh := shape.BuildMesh()
+		model := shape.ModelMatrix()
+		mesh.TransformVerts(&model)
+		merged.Append(mesh)
+	}
+	if len(merged.Verts) == 0 {
+		return nil
+	}
+	if err := r.Buffers.AddMesh(merged); err != nil {
+		return fmt.Errorf("batch of %q: %w", shapes[0].Name, err)
+	}
+	batch.mesh = merged
+	return nil
+}
+
+// drawBatch draws a batch between Begin and End. Its vertices are already in world space.
+func (r *Renderer) drawBatch(batch *Batch) {
+	if batch.mesh == nil {
+		return
+	}
+	CurrentBackend().SetUniformMatrix(r.refs[modelMatrixRef], Identity4())
+	r.applyMaterial(batch.Material, batch.Colour, batch.Texture)
+	r.setReceiveShadows(batch.ReceiveShadows)
+	batch.mesh.RenderMesh(r.Attributes)
+}
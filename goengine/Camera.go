@@ -0,0 +1,273 @@
+package goengine
+
+import (
+	"github.com/chewxy/math32"
+)
+
+type ProjectionType int
+
+const (
+	ProjectionPerspective ProjectionType = iota
+	ProjectionOrthographic
+)
+
+// Camera holds a view (position, target and up vector) and a projection.
+// Fov is the vertical field of view in degrees and OrthoHeight is the
+// height of the view volume in world units when using an orthographic projection.
+type Camera struct {
+	Projection  ProjectionType
+	Position    Vec3
+	Target      Vec3
+	Up          Vec3
+	Fov         float32
+	Aspect      float32
+	Near        float32
+	Far         float32
+	OrthoHeight float32
+	Controller  CameraController
+	LayerMask   uint32 //layers this camera can see, 0 sees every layer
+
+	projMatrix Mat4s
+	viewMatrix Mat4s
+}
+
+// NewCamera returns a perspective camera at the origin looking down the -Z axis
+func NewCamera(fov, aspect, near, far float32) *Camera {
+	return &Camera{
+		Projection:  ProjectionPerspective,
+		Position:    Vec3{0, 0, 0},
+		Target:      Vec3{0, 0, -1},
+		Up:          Vec3{0, 1, 0},
+		Fov:         fov,
+		Aspect:      aspect,
+		Near:        near,
+		Far:         far,
+		OrthoHeight: 20,
+		LayerMask:   LayerAll,
+	}
+}
+
+func (c *Camera) SetPerspective(fov, aspect, near, far float32) {
+	c.Projection = ProjectionPerspective
+	c.Fov, c.Aspect, c.Near, c.Far = fov, aspect, near, far
+}
+
+func (c *Camera) SetOrthographic(height, aspect, near, far float32) {
+	c.Projection = ProjectionOrthographic
+	c.OrthoHeight, c.Aspect, c.Near, c.Far = height, aspect, near, far
+}
+
+// Sees returns true if the camera draws objects in any of the given layers
+func (c *Camera) Sees(layers uint32) bool {
+	return c.LayerMask == 0 || c.LayerMask&layers != 0
+}
+
+// visibleLayers combines a view's layer mask with the camera's
+func (c *Camera) visibleLayers(mask uint32) uint32 {
+	if c.LayerMask == 0 {
+		return mask
+	}
+	return mask & c.LayerMask
+}
+
+func (c *Camera) SetAspect(aspect float32) {
+	c.Aspect = aspect
+}
+
+func (c *Camera) SetFov(fov float32) {
+	c.Fov = Clamp(fov, 1, 179)
+}
+
+func (c *Camera) SetClip(near, far float32) {
+	c.Near, c.Far = near, far
+}
+
+// LookAt points the camera at target from its current position
+func (c *Camera) LookAt(target Vec3) {
+	c.Target = target
+}
+
+// Forward returns the normalised direction the camera is looking in
+func (c *Camera) Forward() Vec3 {
+	f := c.Target.Sub(c.Position)
+	if f.LengthSq() == 0 {
+		return Vec3{0, 0, -1}
+	}
+	return f.Normal()
+}
+
+// Right returns the normalised right hand direction of the camera
+func (c *Camera) Right() Vec3 {
+	return c.Forward().Cross(c.Up).Normal()
+}
+
+func (c *Camera) ProjectionMatrix() *Mat4s {
+	switch c.Projection {
+	case ProjectionOrthographic:
+		c.projMatrix.SetOrthographic(c.OrthoHeight*c.Aspect, c.OrthoHeight, c.Near, c.Far)
+	default:
+		c.projMatrix.SetPerspective(c.Fov, c.Aspect, c.Near, c.Far)
+	}
+	return &c.projMatrix
+}
+
+// ViewMatrix returns the inverse of the camera's world transform
+func (c *Camera) ViewMatrix() *Mat4s {
+	world := Mat4s{}
+	world.SetIdentity()
+	world.LookAt(c.Position, c.Target, c.Up)
+	world.SetPos(c.Position)
+	c.viewMatrix.SetInverse(&world)
+	return &c.viewMatrix
+}
+
+// Update runs the camera controller (if any) with the latest user input
+func (c *Camera) Update(ui *UserInput, dt float32) {
+	if c.Controller != nil {
+		c.Controller.Update(c, ui, dt)
+	}
+}
+
+type CameraController interface {
+	Update(cam *Camera, ui *UserInput, dt float32)
+}
+
+// OrbitController rotates the camera around Target with the left mouse button,
+// pans with the right mouse button and zooms with the mouse wheel.
+// Yaw and Pitch are in degrees.
+type OrbitController struct {
+	Target      Vec3
+	Distance    float32
+	Yaw         float32
+	Pitch       float32
+	MinDistance float32
+	MaxDistance float32
+	RotateSpeed float32
+	PanSpeed    float32
+	ZoomSpeed   float32
+}
+
+func NewOrbitController(target Vec3, distance float32) *OrbitController {
+	return &OrbitController{
+		Target:      target,
+		Distance:    distance,
+		MinDistance: 0.1,
+		MaxDistance: 1000,
+		RotateSpeed: 0.3,
+		PanSpeed:    0.01,
+		ZoomSpeed:   0.1,
+	}
+}
+
+func (o *OrbitController) Update(cam *Camera, ui *UserInput, dt float32) {
+	if ui.Mouse.LeftButton {
+		o.Yaw -= float32(ui.Mouse.DX) * o.RotateSpeed
+		o.Pitch = Clamp(o.Pitch-float32(ui.Mouse.DY)*o.RotateSpeed, -89, 89)
+	}
+	if ui.Mouse.RightButton {
+		pan := cam.Right().MulScalar(-float32(ui.Mouse.DX)).Add(cam.Up.MulScalar(float32(ui.Mouse.DY)))
+		o.Target.SetAdd(pan.MulScalar(o.PanSpeed * o.Distance * 0.1))
+	}
+	if ui.Mouse.Wheel != 0 {
+		o.Distance *= 1 - float32(ui.Mouse.Wheel)*o.ZoomSpeed
+	}
+	o.Distance = Clamp(o.Distance, o.MinDistance, o.MaxDistance)
+
+	yaw, pitch := DegToRad(o.Yaw), DegToRad(o.Pitch)
+	offset := Vec3{
+		math32.Cos(pitch) * math32.Sin(yaw),
+		math32.Sin(pitch),
+		math32.Cos(pitch) * math32.Cos(yaw),
+	}
+	cam.Position = o.Target.Add(offset.MulScalar(o.Distance))
+	cam.Target = o.Target
+}
+
+// FlyController moves the camera freely with W/A/S/D (Q/E for down/up) and
+// looks around while the left mouse button is held.
+type FlyController struct {
+	Yaw       float32
+	Pitch     float32
+	Speed     float32
+	LookSpeed float32
+}
+
+func NewFlyController(speed float32) *FlyController {
+	return &FlyController{Speed: speed, LookSpeed: 0.2}
+}
+
+func (f *FlyController) Update(cam *Camera, ui *UserInput, dt float32) {
+	if ui.Mouse.LeftButton {
+		f.Yaw -= float32(ui.Mouse.DX) * f.LookSpeed
+		f.Pitch = Clamp(f.Pitch-float32(ui.Mouse.DY)*f.LookSpeed, -89, 89)
+	}
+	forward := lookDirection(f.Yaw, f.Pitch)
+	right := forward.Cross(cam.Up).Normal()
+
+	move := moveInput(ui)
+	step := f.Speed * dt
+	cam.Position.SetAdd(forward.MulScalar(move.Z * step))
+	cam.Position.SetAdd(right.MulScalar(move.X * step))
+	cam.Position.SetAdd(cam.Up.MulScalar(move.Y * step))
+	cam.Target = cam.Position.Add(forward)
+}
+
+// FirstPersonController walks the camera over the XZ plane at EyeHeight
+// and always looks around with the mouse.
+type FirstPersonController struct {
+	Yaw       float32
+	Pitch     float32
+	EyeHeight float32
+	Speed     float32
+	LookSpeed float32
+}
+
+func NewFirstPersonController(eyeHeight, speed float32) *FirstPersonController {
+	return &FirstPersonController{EyeHeight: eyeHeight, Speed: speed, LookSpeed: 0.2}
+}
+
+func (f *FirstPersonController) Update(cam *Camera, ui *UserInput, dt float32) {
+	f.Yaw -= float32(ui.Mouse.DX) * f.LookSpeed
+	f.Pitch = Clamp(f.Pitch-float32(ui.Mouse.DY)*f.LookSpeed, -89, 89)
+
+	walk := lookDirection(f.Yaw, 0)
+	right := walk.Cross(Vec3{0, 1, 0}).Normal()
+
+	move := moveInput(ui)
+	step := f.Speed * dt
+	cam.Position.SetAdd(walk.MulScalar(move.Z * step))
+	cam.Position.SetAdd(right.MulScalar(move.X * step))
+	cam.Position.Y = f.EyeHeight
+	cam.Up = Vec3{0, 1, 0}
+	cam.Target = cam.Position.Add(lookDirection(f.Yaw, f.Pitch))
+}
+
+// lookDirection converts yaw and pitch (degrees) into a direction vector, yaw 0 looks down -Z
+func lookDirection(yaw, pitch float32) Vec3 {
+	y, p := DegToRad(yaw), DegToRad(pitch)
+	return Vec3{-math32.Cos(p) * math32.Sin(y), math32.Sin(p), -math32.Cos(p) * math32.Cos(y)}
+}
+
+// moveInput returns the movement keys as X (right), Y (up) and Z (forward) in the range -1..1
+func moveInput(ui *UserInput) Vec3 {
+	move := Vec3{}
+	if ui.KeyDown(KeyW) || ui.KeyDown(KeyUp) {
+		move.Z++
+	}
+	if ui.KeyDown(KeyS) || ui.KeyDown(KeyDown) {
+		move.Z--
+	}
+	if ui.KeyDown(KeyD) || ui.KeyDown(KeyRight) {
+		move.X++
+	}
+	if ui.KeyDown(KeyA) || ui.KeyDown(KeyLeft) {
+		move.X--
+	}
+	if ui.KeyDown(KeyE) {
+		move.Y++
+	}
+	if ui.KeyDown(KeyQ) {
+		move.Y--
+	}
+	return move
+}
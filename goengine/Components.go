@@ -0,0 +1,189 @@
+package goengine
+
+import "log"
+
+// Transform places an entity relative to its parent entity (or the world if Parent is 0).
+// Rotation is Euler angles in degrees, as for Shape.
+type Transform struct {
+	Position Vec3
+	Rotation Vec3
+	Scale    Vec3
+	Parent   Entity
+}
+
+func NewTransform(position, rotation Vec3) Transform {
+	return Transform{Position: position, Rotation: rotation, Scale: Vec3{1, 1, 1}}
+}
+
+// Matrix returns the local transform (translate * rotate X,Y,Z * scale)
+func (t *Transform) Matrix() Mat4s {
+	m := Mat4s{}
+	m.SetRotationFromEuler(Vec3{DegToRad(t.Rotation.X), DegToRad(t.Rotation.Y), DegToRad(t.Rotation.Z)})
+	m.SetScaleCols(t.Scale)
+	m.SetPos(t.Position)
+	return m
+}
+
+// WorldMatrix returns the transform of an entity combined with those of its parents
+func (w *World) WorldMatrix(e Entity) Mat4s {
+	m := *Identity4()
+	for depth := 0; e != 0 && depth < 64; depth++ { //depth guards against parent loops
+		t, ok := GetComponent[Transform](w, e)
+		if !ok {
+			break
+		}
+		local := t.Matrix()
+		child := m
+		m.MulMatrices(&local, &child)
+		e = t.Parent
+	}
+	return m
+}
+
+// Renderable draws a shape at the entity's transform
+type Renderable struct {
+	Shape   *Shape
+	Visible bool
+}
+
+// CameraComponent moves a camera with the entity's transform. Cameras with a
+// controller are left to the controller. The active camera becomes the scene camera.
+type CameraComponent struct {
+	Camera *Camera
+	Active bool
+}
+
+// LightComponent moves a light with the entity's transform. The light is added to the
+// world's scene if it hasn't been added with Scene.AddLight already, and removed again
+// when the component or its entity is.
+type LightComponent struct {
+	Light   *Light
+	Enabled bool
+}
+
+// AnimationComponent advances an animator every update
+type AnimationComponent struct {
+	Animator *Animator
+}
+
+// SpawnShape creates an entity that draws shape, with a transform taken from the shape.
+// The shape is removed from the scene's shapes as the entity draws it from then on.
+func (w *World) SpawnShape(shape *Shape) Entity {
+	if w.Scene != nil {
+		for name, s := range w.Scene.Shapes {
+			if s == shape {
+				delete(w.Scene.Shapes, name)
+			}
+		}
+	}
+	e := w.Create(shape.Name)
+	t := NewTransform(shape.Position, shape.Rotation)
+	if shape.Scale != (Vec3{}) {
+		t.Scale = shape.Scale
+	}
+	AddComponent(w, e, t)
+	AddComponent(w, e, Renderable{Shape: shape, Visible: true})
+	return e
+}
+
+func animationSystem(w *World, dt float32) {
+	Each(w, func(e Entity, a *AnimationComponent) {
+		if a.Animator != nil {
+			a.Animator.Update(dt)
+		}
+	})
+}
+
+// transformSystem copies each renderable's local transform into its shape.
+// Parent transforms are applied when the shape is drawn.
+func transformSystem(w *World, dt float32) {
+	Each2(w, func(e Entity, r *Renderable, t *Transform) {
+		if r.Shape == nil {
+			return
+		}
+		r.Shape.Position = t.Position
+		r.Shape.Rotation = t.Rotation
+		r.Shape.Scale = t.Scale
+	})
+}
+
+// lightSystem moves scene lights with their entities. Lights are tracked by pointer so a
+// scene light with the same name is left alone.
+func lightSystem(w *World, dt float32) {
+	live := make(map[*Light]bool)
+	Each(w, func(e Entity, l *LightComponent) {
+		if l.Light == nil {
+			return
+		}
+		live[l.Light] = true
+		if w.Scene != nil && !w.Scene.hasLight(l.Light) {
+			w.Scene.Lights = append(w.Scene.Lights, l.Light)
+			w.lights[l.Light] = true
+		}
+		m := w.WorldMatrix(e)
+		l.Light.Place(&m)
+		l.Light.Enabled = l.Enabled
+	})
+	for l := range w.lights {
+		if !live[l] {
+			if w.Scene != nil {
+				w.Scene.removeLight(l)
+			}
+			delete(w.lights, l)
+		}
+	}
+}
+
+func cameraSystem(w *World, dt float32) {
+	Each(w, func(e Entity, c *CameraComponent) {
+		if c.Camera == nil {
+			return
+		}
+		if c.Camera.Controller == nil && HasComponent[Transform](w, e) {
+			m := w.WorldMatrix(e)
+			forward := c.Camera.Forward()
+			c.Camera.Position = m.Pos()
+			c.Camera.Target = c.Camera.Position.Add(forward)
+		}
+		if c.Active && w.Scene != nil && w.Scene.Camera != c.Camera {
+			w.Scene.SetCamera(c.Camera)
+		}
+	})
+}
+
+// draw is registered with the scene to draw the world's renderables for each camera,
+// with the scene's Renderer or the fixed-function pipeline like the scene's shapes
+func (w *World) draw(s *Scene, cam *Camera, layerMask uint32) {
+	if cam == nil {
+		return
+	}
+	if r := s.renderer(); r != nil {
+		r.Begin(cam)
+		w.eachRenderable(layerMask, func(shape *Shape, model *Mat4s) {
+			if err := r.drawShapeAt(shape, model); err != nil {
+				log.Println(err)
+			}
+		})
+		r.End()
+		return
+	}
+	if fb, ok := CurrentBackend().(FixedFunctionBackend); ok {
+		fb.LoadMatrices(cam.ProjectionMatrix(), cam.ViewMatrix())
+		w.eachRenderable(layerMask, fb.DrawShape)
+	}
+}
+
+// eachRenderable calls fn with each visible renderable in one of the layers in mask and its
+// entity's world matrix
+func (w *World) eachRenderable(mask uint32, fn func(shape *Shape, model *Mat4s)) {
+	Each(w, func(e Entity, r *Renderable) {
+		if !r.Visible || r.Shape == nil || !r.Shape.InLayers(mask) {
+			return
+		}
+		model := r.Shape.ModelMatrix()
+		if HasComponent[Transform](w, e) {
+			model = w.WorldMatrix(e)
+		}
+		fn(r.Shape, &model)
+	})
+}
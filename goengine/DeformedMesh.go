@@ -0,0 +1,135 @@
+package goengine
+
+import "fmt"
+
+// deformedMesh draws a mesh whose vertices move every frame, shared by SkinnedMesh and
+// MorphMesh. With a program from enableGPU the source vertices are uploaded once and moved
+// by the vertex shader. Otherwise the vertices are moved on the CPU and drawn by the
+// fixed-function pipeline, or by a default Renderer on backends without one.
+type deformedMesh struct {
+	gpu      bool
+	program  *Renderer //built from the deforming vertex shader
+	source   uint32    //vertex buffer of the undeformed vertices read by program
+	cpu      *Renderer //draws the CPU deformed vertices on backends without a fixed-function pipeline
+	cpuVerts uint32    //dynamic vertex buffer of the CPU deformed vertices
+	indexes  uint32    //index buffer of an indexed mesh, shared by both draws
+	iboType  IndexType
+}
+
+// enableGPU builds the program from vertexSrc, if it isn't built already, and uploads the
+// undeformed vertices
+func (d *deformedMesh) enableGPU(vertexSrc string, verts []float32) error {
+	if d.program == nil {
+		program, err := NewShaderRenderer(vertexSrc, defaultFragmentShader)
+		if err != nil {
+			return err
+		}
+		d.program = program
+	}
+	b := CurrentBackend()
+	for _, buf := range []*uint32{&d.source, &d.indexes} {
+		if *buf != 0 {
+			b.DeleteBuffer(*buf)
+			*buf = 0
+		}
+	}
+	source, err := b.CreateBuffer(verts, false)
+	if err != nil {
+		return err
+	}
+	d.source = source
+	d.gpu = true
+	return nil
+}
+
+// DisableGPU goes back to deforming on the CPU. The program is kept for EnableGPU.
+func (d *deformedMesh) DisableGPU() {
+	d.gpu = false
+}
+
+// attribute returns the location of a program attribute, or -1 if it has none
+func (d *deformedMesh) attribute(name string) int {
+	for i, a := range d.program.Attributes {
+		if a == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// begin makes r's program current with the camera, model matrix and surface
+func (d *deformedMesh) begin(r *Renderer, cam *Camera, model *Mat4s, col uint32, tex Texture) {
+	r.Begin(cam)
+	CurrentBackend().SetUniformMatrix(r.refs[modelMatrixRef], model)
+	r.applyMaterial(nil, col, tex)
+}
+
+// drawCPU draws mesh after its vertices have been moved on the CPU
+func (d *deformedMesh) drawCPU(mesh *Mesh, cam *Camera, model *Mat4s, col uint32, tex Texture) error {
+	b := CurrentBackend()
+	if fb, ok := b.(FixedFunctionBackend); ok {
+		fb.LoadMatrices(cam.ProjectionMatrix(), cam.ViewMatrix())
+		fb.DrawMesh(mesh, model, col, tex)
+		return nil
+	}
+	if d.cpu == nil {
+		r, err := NewRenderer()
+		if err != nil {
+			return fmt.Errorf("can't draw a deformed mesh: %w", err)
+		}
+		d.cpu = r
+	}
+	if d.cpuVerts == 0 {
+		buf, err := b.CreateBuffer(mesh.Verts, true)
+		if err != nil {
+			return err
+		}
+		d.cpuVerts = buf
+	} else {
+		b.UpdateBuffer(d.cpuVerts, 0, mesh.Verts)
+	}
+
+	d.begin(d.cpu, cam, model, col, tex)
+	b.BindVertexBuffer(d.cpuVerts, mesh.stride(), mesh.layout().Bind(d.cpu.Attributes))
+	err := d.draw(mesh)
+	d.cpu.End()
+	return err
+}
+
+// draw draws the bound vertices in the mesh's primitive, in the order of its indices if it
+// has them. The indices are uploaded on the first draw.
+func (d *deformedMesh) draw(mesh *Mesh) error {
+	b := CurrentBackend()
+	if !mesh.Indexed() {
+		b.DrawArrays(DrawMode(mesh.Mode), 0, mesh.VertCount())
+		return nil
+	}
+	if d.indexes == 0 {
+		typ := mesh.IndexType()
+		buf, err := b.CreateIndexBuffer(mesh.Indexes, typ)
+		if err != nil {
+			return err
+		}
+		d.indexes, d.iboType = buf, typ
+	}
+	b.DrawElements(DrawMode(mesh.Mode), d.indexes, d.iboType, 0, len(mesh.Indexes))
+	return nil
+}
+
+// Delete frees the programs and vertex buffers
+func (d *deformedMesh) Delete() {
+	b := CurrentBackend()
+	for _, buf := range []*uint32{&d.source, &d.cpuVerts, &d.indexes} {
+		if *buf != 0 {
+			b.DeleteBuffer(*buf)
+			*buf = 0
+		}
+	}
+	for _, r := range []**Renderer{&d.program, &d.cpu} {
+		if *r != nil {
+			(*r).Delete()
+			*r = nil
+		}
+	}
+	d.gpu = false
+}
@@ -0,0 +1,288 @@
+package goengine
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Entity identifies an object in a World. The zero Entity is never used.
+type Entity uint32
+
+// System is run once per World.Update
+type System interface {
+	Update(w *World, dt float32)
+}
+
+// SystemFunc adapts a function to a System
+type SystemFunc func(w *World, dt float32)
+
+func (f SystemFunc) Update(w *World, dt float32) {
+	f(w, dt)
+}
+
+// Systems run in ascending order. User systems added with order 0 run before the built-in ones.
+const (
+	OrderAnimation = 100
+	OrderTransform = 200
+	OrderCamera    = 300
+	OrderLight     = 400
+)
+
+type systemEntry struct {
+	name    string
+	order   int
+	system  System
+	enabled bool
+}
+
+// componentStore is the untyped view of a Storage used by the World
+type componentStore interface {
+	has(e Entity) bool
+	remove(e Entity)
+}
+
+// Storage holds every component of one type, packed for fast iteration
+type Storage[T any] struct {
+	entities   []Entity
+	components []*T
+	index      map[Entity]int
+}
+
+func newStorage[T any]() *Storage[T] {
+	return &Storage[T]{index: make(map[Entity]int)}
+}
+
+func (st *Storage[T]) add(e Entity, c T) *T {
+	if i, ok := st.index[e]; ok {
+		*st.components[i] = c
+		return st.components[i]
+	}
+	st.index[e] = len(st.entities)
+	st.entities = append(st.entities, e)
+	st.components = append(st.components, &c)
+	return &c
+}
+
+// Get returns the component of an entity
+func (st *Storage[T]) Get(e Entity) (*T, bool) {
+	if i, ok := st.index[e]; ok {
+		return st.components[i], true
+	}
+	return nil, false
+}
+
+func (st *Storage[T]) has(e Entity) bool {
+	_, ok := st.index[e]
+	return ok
+}
+
+func (st *Storage[T]) remove(e Entity) {
+	i, ok := st.index[e]
+	if !ok {
+		return
+	}
+	last := len(st.entities) - 1
+	st.entities[i], st.components[i] = st.entities[last], st.components[last]
+	st.index[st.entities[i]] = i
+	st.entities, st.components = st.entities[:last], st.components[:last]
+	delete(st.index, e)
+}
+
+// Len returns the number of entities with this component
+func (st *Storage[T]) Len() int {
+	return len(st.entities)
+}
+
+// World is a set of entities, their components and the systems that update them
+type World struct {
+	Scene *Scene
+
+	next      Entity
+	names     map[Entity]string
+	stores    map[reflect.Type]componentStore
+	systems   []systemEntry
+	updating  bool
+	destroyed []Entity
+	lights    map[*Light]bool //lights the light system added to the scene
+}
+
+// NewWorld creates a world with the built-in animation, transform, camera and light systems.
+// If scene is not nil the world's renderables and lights are drawn with it.
+func NewWorld(scene *Scene) *World {
+	w := &World{
+		Scene:  scene,
+		names:  make(map[Entity]string),
+		stores: make(map[reflect.Type]componentStore),
+		lights: make(map[*Light]bool),
+	}
+	w.AddSystem("animation", OrderAnimation, SystemFunc(animationSystem))
+	w.AddSystem("transform", OrderTransform, SystemFunc(transformSystem))
+	w.AddSystem("camera", OrderCamera, SystemFunc(cameraSystem))
+	w.AddSystem("light", OrderLight, SystemFunc(lightSystem))
+	if scene != nil {
+		scene.OnDraw(w.draw)
+	}
+	return w
+}
+
+// Create makes a new entity. The name is for lookups and debugging and need not be unique.
+func (w *World) Create(name string) Entity {
+	w.next++
+	w.names[w.next] = name
+	return w.next
+}
+
+// Destroy removes an entity and all its components. Entities destroyed while
+// systems are running are removed once the current system has finished.
+func (w *World) Destroy(e Entity) {
+	if w.updating {
+		w.destroyed = append(w.destroyed, e)
+		return
+	}
+	for _, store := range w.stores {
+		store.remove(e)
+	}
+	delete(w.names, e)
+}
+
+func (w *World) Alive(e Entity) bool {
+	_, ok := w.names[e]
+	return ok
+}
+
+func (w *World) Name(e Entity) string {
+	return w.names[e]
+}
+
+// Lookup returns the first entity with the given name
+func (w *World) Lookup(name string) (Entity, bool) {
+	found := Entity(0)
+	for e, n := range w.names {
+		if n == name && (found == 0 || e < found) {
+			found = e
+		}
+	}
+	return found, found != 0
+}
+
+// Len returns the number of live entities
+func (w *World) Len() int {
+	return len(w.names)
+}
+
+// AddSystem schedules a system to run every Update. Systems with the same order run in the order added.
+func (w *World) AddSystem(name string, order int, system System) {
+	w.systems = append(w.systems, systemEntry{name: name, order: order, system: system, enabled: true})
+	sort.SliceStable(w.systems, func(i, j int) bool { return w.systems[i].order < w.systems[j].order })
+}
+
+func (w *World) RemoveSystem(name string) {
+	for i := range w.systems {
+		if w.systems[i].name == name {
+			w.systems = append(w.systems[:i], w.systems[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetSystemEnabled pauses or resumes a system, returning false if there is no system with that name
+func (w *World) SetSystemEnabled(name string, enabled bool) bool {
+	for i := range w.systems {
+		if w.systems[i].name == name {
+			w.systems[i].enabled = enabled
+			return true
+		}
+	}
+	return false
+}
+
+// Update runs each enabled system in order
+func (w *World) Update(dt float32) {
+	for _, entry := range w.systems {
+		if !entry.enabled {
+			continue
+		}
+		w.updating = true
+		entry.system.Update(w, dt)
+		w.updating = false
+
+		destroyed := w.destroyed
+		w.destroyed = nil
+		for _, e := range destroyed {
+			w.Destroy(e)
+		}
+	}
+}
+
+// Components returns the storage for components of type T, creating it if needed
+func Components[T any](w *World) *Storage[T] {
+	t := reflect.TypeFor[T]()
+	if store, ok := w.stores[t]; ok {
+		return store.(*Storage[T])
+	}
+	store := newStorage[T]()
+	w.stores[t] = store
+	return store
+}
+
+// AddComponent attaches a component to an entity, replacing any existing one of the same type
+func AddComponent[T any](w *World, e Entity, c T) *T {
+	return Components[T](w).add(e, c)
+}
+
+// GetComponent returns an entity's component of type T
+func GetComponent[T any](w *World, e Entity) (*T, bool) {
+	return Components[T](w).Get(e)
+}
+
+func HasComponent[T any](w *World, e Entity) bool {
+	return Components[T](w).has(e)
+}
+
+func RemoveComponent[T any](w *World, e Entity) {
+	Components[T](w).remove(e)
+}
+
+// Each calls fn for every entity with a component of type T.
+// Components can be added and removed by fn, but not of type T.
+func Each[T any](w *World, fn func(e Entity, c *T)) {
+	st := Components[T](w)
+	for i := 0; i < len(st.entities); i++ {
+		fn(st.entities[i], st.components[i])
+	}
+}
+
+// Each2 calls fn for every entity that has both an A and a B component
+func Each2[A, B any](w *World, fn func(e Entity, a *A, b *B)) {
+	sa, sb := Components[A](w), Components[B](w)
+	for i := 0; i < len(sa.entities); i++ {
+		e := sa.entities[i]
+		if b, ok := sb.Get(e); ok {
+			fn(e, sa.components[i], b)
+		}
+	}
+}
+
+// Each3 calls fn for every entity that has an A, B and C component
+func Each3[A, B, C any](w *World, fn func(e Entity, a *A, b *B, c *C)) {
+	sa, sb, sc := Components[A](w), Components[B](w), Components[C](w)
+	for i := 0; i < len(sa.entities); i++ {
+		e := sa.entities[i]
+		b, okb := sb.Get(e)
+		c, okc := sc.Get(e)
+		if okb && okc {
+			fn(e, sa.components[i], b, c)
+		}
+	}
+}
+
+// Query returns the entities that have a component of type T and satisfy match (which may be nil)
+func Query[T any](w *World, match func(e Entity, c *T) bool) []Entity {
+	found := []Entity{}
+	Each(w, func(e Entity, c *T) {
+		if match == nil || match(e, c) {
+			found = append(found, e)
+		}
+	})
+	return found
+}
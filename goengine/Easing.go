@@ -0,0 +1,130 @@
+package goengine
+
+import (
+	"github.com/chewxy/math32"
+)
+
+// EasingFunc remaps t in the range 0..1, returning 0 at t=0 and 1 at t=1
+type EasingFunc func(t float32) float32
+
+func EaseLinear(t float32) float32 {
+	return t
+}
+
+func EaseInQuad(t float32) float32 {
+	return t * t
+}
+
+func EaseOutQuad(t float32) float32 {
+	return t * (2 - t)
+}
+
+func EaseInOutQuad(t float32) float32 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+func EaseInCubic(t float32) float32 {
+	return t * t * t
+}
+
+func EaseOutCubic(t float32) float32 {
+	t--
+	return t*t*t + 1
+}
+
+func EaseInOutCubic(t float32) float32 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	t = 2*t - 2
+	return 0.5*t*t*t + 1
+}
+
+func EaseInSine(t float32) float32 {
+	return 1 - math32.Cos(t*math32.Pi/2)
+}
+
+func EaseOutSine(t float32) float32 {
+	return math32.Sin(t * math32.Pi / 2)
+}
+
+func EaseInOutSine(t float32) float32 {
+	return 0.5 * (1 - math32.Cos(math32.Pi*t))
+}
+
+func EaseInExpo(t float32) float32 {
+	if t == 0 {
+		return 0
+	}
+	return math32.Pow(2, 10*(t-1))
+}
+
+func EaseOutExpo(t float32) float32 {
+	if t == 1 {
+		return 1
+	}
+	return 1 - math32.Pow(2, -10*t)
+}
+
+// EaseInBack pulls back slightly before moving forward
+func EaseInBack(t float32) float32 {
+	const s = 1.70158
+	return t * t * ((s+1)*t - s)
+}
+
+// EaseOutBack overshoots the end slightly before settling
+func EaseOutBack(t float32) float32 {
+	const s = 1.70158
+	t--
+	return t*t*((s+1)*t+s) + 1
+}
+
+func EaseOutElastic(t float32) float32 {
+	if t == 0 || t == 1 {
+		return t
+	}
+	return math32.Pow(2, -10*t)*math32.Sin((t-0.075)*(2*math32.Pi)/0.3) + 1
+}
+
+func EaseOutBounce(t float32) float32 {
+	switch {
+	case t < 1/2.75:
+		return 7.5625 * t * t
+	case t < 2/2.75:
+		t -= 1.5 / 2.75
+		return 7.5625*t*t + 0.75
+	case t < 2.5/2.75:
+		t -= 2.25 / 2.75
+		return 7.5625*t*t + 0.9375
+	}
+	t -= 2.625 / 2.75
+	return 7.5625*t*t + 0.984375
+}
+
+func EaseInBounce(t float32) float32 {
+	return 1 - EaseOutBounce(1-t)
+}
+
+// Easings maps names to easing functions, e.g. for reading animations from files
+var Easings = map[string]EasingFunc{
+	"linear":     EaseLinear,
+	"inQuad":     EaseInQuad,
+	"outQuad":    EaseOutQuad,
+	"inOutQuad":  EaseInOutQuad,
+	"inCubic":    EaseInCubic,
+	"outCubic":   EaseOutCubic,
+	"inOutCubic": EaseInOutCubic,
+	"inSine":     EaseInSine,
+	"outSine":    EaseOutSine,
+	"inOutSine":  EaseInOutSine,
+	"inExpo":     EaseInExpo,
+	"outExpo":    EaseOutExpo,
+	"inBack":     EaseInBack,
+	"outBack":    EaseOutBack,
+	"outElastic": EaseOutElastic,
+	"inBounce":   EaseInBounce,
+	"outBounce":  EaseOutBounce,
+}
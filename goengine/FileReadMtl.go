@@ -0,0 +1,125 @@
+package goengine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/udhos/gwob"
+)
+
+// ReadMTL loads the materials of an MTL library by name. Texture maps are found relative to
+// the library's directory; missing ones are logged and left empty.
+func ReadMTL(file string) (map[string]*Material, error) {
+	options := &gwob.ObjParserOptions{
+		Logger: func(msg string) { fmt.Fprintln(os.Stderr, msg) },
+	}
+	lib, err := gwob.ReadMaterialLibFromFile(file, options)
+	if err != nil {
+		return nil, fmt.Errorf("mtl %q: %w", file, err)
+	}
+	dir := filepath.Dir(file)
+	materials := make(map[string]*Material, len(lib.Lib))
+	for name, mtl := range lib.Lib {
+		materials[name] = mtlMaterial(mtl, dir)
+	}
+	return materials, nil
+}
+
+// mtlMaterial converts a parsed MTL material
+func mtlMaterial(mtl *gwob.Material, dir string) *Material {
+	opacity := mtl.D
+	if opacity == 0 {
+		opacity = 1 //no d statement, as a fully transparent material would be invisible
+	}
+	m := &Material{
+		Name:         mtl.Name,
+		Diffuse:      RGBAToCol(mtl.Kd[0], mtl.Kd[1], mtl.Kd[2], opacity),
+		Ambient:      RGBAToCol(mtl.Ka[0], mtl.Ka[1], mtl.Ka[2], 1),
+		Specular:     RGBAToCol(mtl.Ks[0], mtl.Ks[1], mtl.Ks[2], 1),
+		Shininess:    mtl.Ns,
+		Illumination: mtl.Illum,
+		Reflective:   mtl.Illum >= 3, //3 and up are the ray traced reflection models
+		UVScale:      Vec2{1, 1},
+	}
+	if opacity < 1 {
+		m.Blend = BlendAlpha
+	}
+	//gwob keeps the Ke statement as text
+	if ke, ok := parseFloats(mtl.MapKe, 3); ok {
+		m.Emissive = RGBAToCol(ke[0], ke[1], ke[2], 1)
+	}
+
+	maps := [TextureSlots]string{DiffuseMap: mtl.MapKd, NormalMap: mtl.Bump, SpecularMap: mtl.MapKs}
+	for slot, statement := range maps {
+		if statement == "" {
+			continue
+		}
+		file, scale, offset := parseTextureMap(statement)
+		if slot == int(DiffuseMap) {
+			m.UVScale, m.UVOffset = scale, offset
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if _, err := os.Stat(file); err != nil {
+			log.Printf("mtl: material=%s texture %v", mtl.Name, err)
+			continue
+		}
+		m.Textures[slot].LoadTexture(file)
+	}
+	return m
+}
+
+// parseTextureMap splits a map statement such as "-s 2 2 -o 0.5 0 bricks.png" into
+// the file and its scale and offset options. Other options are skipped.
+func parseTextureMap(statement string) (file string, scale, offset Vec2) {
+	scale = Vec2{1, 1}
+	fields := strings.Fields(statement)
+	i := 0
+	for i < len(fields)-1 && strings.HasPrefix(fields[i], "-") {
+		option := fields[i]
+		i++
+		//options take up to 3 numbers, or one word such as -clamp on
+		n := 0
+		for n < 3 && i+n < len(fields)-1 {
+			if _, err := strconv.ParseFloat(fields[i+n], 32); err != nil {
+				break
+			}
+			n++
+		}
+		if n == 0 && i < len(fields)-1 {
+			n = 1
+		}
+		if v, ok := parseFloats(strings.Join(fields[i:i+n], " "), 2); ok {
+			switch option {
+			case "-s":
+				scale = Vec2{v[0], v[1]}
+			case "-o":
+				offset = Vec2{v[0], v[1]}
+			}
+		}
+		i += n
+	}
+	return strings.Join(fields[i:], " "), scale, offset
+}
+
+// parseFloats parses at least n space separated numbers
+func parseFloats(text string, n int) ([]float32, bool) {
+	fields := strings.Fields(text)
+	if len(fields) < n {
+		return nil, false
+	}
+	values := make([]float32, n)
+	for i := range values {
+		f, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = float32(f)
+	}
+	return values, true
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/udhos/gwob"
 )
 
+// ReadOBJ loads each group of an OBJ file as an indexed ShapeTriangles shape with the material
+// it uses. Groups using the same material share it. The mtllib is found relative to the OBJ file.
 func ReadOBJ(file string, scene Scene) []Shape {
 
 	// Set options
@@ -23,55 +26,63 @@ func ReadOBJ(file string, scene Scene) []Shape {
 		return nil
 	}
 
-	fileMtl := o.Mtllib
-	sc := 0
-	s := make([]Shape, len(o.Groups))
-
 	// Load material lib
-	lib, errMtl := gwob.ReadMaterialLibFromFile(fileMtl, options)
-	if errMtl != nil {
-		log.Printf("mtl: parse error input=%s: %v", fileMtl, errMtl)
-	} else {
-
-		normOffset := o.StrideOffsetNormal / 4
-		texOffset := o.StrideOffsetTexture / 4
-		vertSize := 9
-		s[sc] = Shape{}
-
-		// Scan OBJ groups
-		s[sc].Verts = make([]float32, len(o.Indices)*vertSize) //x,y,z,nx,ny,nz,tx,ty,kd,ka
-		vi := 0
-		for _, g := range o.Groups {
+	var materials map[string]*Material
+	if o.Mtllib != "" {
+		mtllib := o.Mtllib
+		if !filepath.IsAbs(mtllib) {
+			mtllib = filepath.Join(filepath.Dir(file), mtllib)
+		}
+		var errMtl error
+		materials, errMtl = ReadMTL(mtllib)
+		if errMtl != nil {
+			log.Printf("mtl: parse error input=%s: %v", mtllib, errMtl)
+		}
+	}
+	return objShapes(o, materials)
+}
 
-			col := float32(0)
-			//alpha := float32(0)
+// objShapes makes a shape of each group, with just the vertices the group uses
+func objShapes(o *gwob.Obj, materials map[string]*Material) []Shape {
+	stride := o.StrideSize / 4
+	posOffset := o.StrideOffsetPosition / 4
+	normOffset := o.StrideOffsetNormal / 4
+	texOffset := o.StrideOffsetTexture / 4
 
-			mtl, found := lib.Lib[g.Usemtl]
-			if found {
-				log.Printf("obj=%s lib=%s group=%s material=%s MapKd=%s Kd=%v", file, fileMtl, g.Name, g.Usemtl, mtl.MapKd, mtl.Kd)
-				//specular := (mtl.Ks[0] * 255) + (mtl.Ks[1]*255)*255 + (mtl.Ks[2]*255)*65536
-				//alpha = (mtl.Ka[0] * 255) + (mtl.Ka[1]*255)*255 + (mtl.Ka[2]*255)*65536
-				col = (mtl.Kd[0] * 255) + (mtl.Kd[1]*255)*255 + (mtl.Kd[2]*255)*65536 //need to combine this with alpha
-			} else {
-				log.Printf("obj=%s lib=%s group=%s material=%s NOT FOUND", file, fileMtl, g.Name, g.Usemtl)
-			}
+	shapes := make([]Shape, 0, len(o.Groups))
+	for _, g := range o.Groups {
+		if g.IndexCount == 0 {
+			continue
+		}
+		shape := NewShape(g.Name, ShapeTriangles, 0, 0, 0, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+		if mtl, found := materials[g.Usemtl]; found {
+			shape.Material = mtl
+			shape.Colour, shape.Texture = mtl.Diffuse, mtl.Textures[DiffuseMap]
+		} else if g.Usemtl != "" {
+			log.Printf("obj: group=%s material=%s NOT FOUND", g.Name, g.Usemtl)
+		}
 
-			indexStart := g.IndexBegin
-			for i := 0; i < g.IndexCount; i++ {
-				ci := o.Indices[indexStart+i]
-				s[sc].Verts[vi], s[sc].Verts[vi+1], s[sc].Verts[vi+2] = o.Coord[ci], o.Coord[ci+1], o.Coord[ci+2]
+		local := make(map[int]int) //OBJ vertex number to shape vertex number
+		shape.Indexes = make([]int, g.IndexCount)
+		for i, ci := range o.Indices[g.IndexBegin : g.IndexBegin+g.IndexCount] {
+			vi, ok := local[ci]
+			if !ok {
+				vi = len(local)
+				local[ci] = vi
+				v := o.Coord[ci*stride : (ci+1)*stride]
+				pos := Vec3{v[posOffset], v[posOffset+1], v[posOffset+2]}
+				normal, uv := Vec3{}, Vec2{}
 				if o.NormCoordFound {
-					s[sc].Verts[vi+3], s[sc].Verts[vi+4], s[sc].Verts[vi+5] = o.Coord[ci+normOffset], o.Coord[ci+normOffset+1], o.Coord[ci+normOffset+2]
+					normal = Vec3{v[normOffset], v[normOffset+1], v[normOffset+2]}
 				}
 				if o.TextCoordFound {
-					s[sc].Verts[vi+6], s[sc].Verts[vi+7] = o.Coord[ci+texOffset], o.Coord[ci+texOffset+1]
+					uv = Vec2{v[texOffset], v[texOffset+1]}
 				}
-				s[sc].Verts[vi+8] = col //diffuse taken from lib
-				//s.Verts[vi+9] = alpha //alpha taken from lib
-				vi += vertSize
+				shape.Verts = append(shape.Verts, storeVNTC2(0xffffffff, pos, normal, uv)...)
 			}
+			shape.Indexes[i] = vi
 		}
-
+		shapes = append(shapes, shape)
 	}
-	return s
+	return shapes
 }
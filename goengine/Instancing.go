@@ -0,0 +1,306 @@
+package goengine
+
+import "log"
+
+// instancedVertexShader is vs.txt with each instance's model matrix, colour and UV offset
+// read from an instance buffer
+var instancedVertexShader = shaderVariant{
+	inputs: `attribute vec4 a_Model0;       // per instance model matrix columns,
+attribute vec4 a_Model1;       // replacing u_ModelMatrix
+attribute vec4 a_Model2;
+attribute vec4 a_Model3;
+attribute vec4 a_InstanceColour;  // multiplies u_diffuseColour
+attribute vec2 a_InstanceUV;      // added to the animation offset`,
+	transform: `model = mat4(a_Model0, a_Model1, a_Model2, a_Model3);
+	diffuseColour *= a_InstanceColour;
+	uvOffset += a_InstanceUV;`,
+}.vertexShader()
+
+// INSTANCESIZE is the number of floats per instance in an instance buffer:
+// a model matrix, colour and UV offset
+const INSTANCESIZE = 22
+
+// InstanceLayout is the layout of the instanced shader's per-instance attributes in an instance buffer
+var InstanceLayout = NewVertexLayout(
+	VertexAttribute{Name: "a_Model0", Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: "a_Model1", Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: "a_Model2", Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: "a_Model3", Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: "a_InstanceColour", Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: "a_InstanceUV", Components: 2, Type: AttribFloat},
+)
+
+// Instance is one copy of the mesh drawn by Instances
+type Instance struct {
+	Position Vec3
+	Rotation Vec3
+	Scale    Vec3   //0,0,0 is treated as 1,1,1
+	Colour   uint32 //multiplies the Instances colour, 0 is treated as white
+	UVOffset Vec2   //moves the texture, e.g. to pick a tile from an atlas
+}
+
+// Matrix returns the instance's transform (translate * rotate X,Y,Z * scale)
+func (inst *Instance) Matrix() Mat4s {
+	t := NewTransform(inst.Position, inst.Rotation)
+	if inst.Scale != (Vec3{}) {
+		t.Scale = inst.Scale
+	}
+	return t.Matrix()
+}
+
+func (inst *Instance) colour() uint32 {
+	if inst.Colour == 0 {
+		return 0xffffffff
+	}
+	return inst.Colour
+}
+
+// Instances draws many copies of one mesh, such as the trees of a forest, each with its
+// own transform, colour and UV offset. A Renderer draws them in one call where the backend
+// has instanced arrays, otherwise it merges the copies into a batch for each colour.
+// Backends without shaders draw each copy in immediate mode, without the UV offsets.
+// Call MarkDirty after changing Items directly.
+type Instances struct {
+	Name    string
+	Mesh    *Mesh
+	Texture Texture
+	Colour  uint32
+	Layers  uint32
+	Items   []Instance
+
+	version int //counts changes, so each renderer knows when to update its copy
+}
+
+// NewInstances draws copies of a mesh in the packed vertex layout
+func NewInstances(name string, mesh *Mesh, tex Texture) *Instances {
+	return &Instances{Name: name, Mesh: mesh, Texture: tex, Colour: 0xffffffff, Layers: LayerDefault}
+}
+
+// NewShapeInstances draws copies of a shape's geometry with its texture, colour and layers.
+// The shape's own transform isn't used.
+func NewShapeInstances(shape *Shape) *Instances {
+	col, tex := shape.surface()
+	in := NewInstances(shape.Name, shape.BuildMesh(), tex)
+	in.Colour, in.Layers = col, shape.Layers
+	return in
+}
+
+// Add adds a copy and returns its index in Items
+func (in *Instances) Add(inst Instance) int {
+	in.Items = append(in.Items, inst)
+	in.version++
+	return len(in.Items) - 1
+}
+
+// MarkDirty makes renderers update the instances before they are next drawn
+func (in *Instances) MarkDirty() {
+	in.version++
+}
+
+// instanceData packs the items for an instance buffer
+func (in *Instances) instanceData() []float32 {
+	data := make([]float32, 0, len(in.Items)*INSTANCESIZE)
+	for i := range in.Items {
+		inst := &in.Items[i]
+		m := inst.Matrix()
+		data = append(data, m.ToGLArray()...)
+		cr, cg, cb, ca := ColToRGBA(inst.colour())
+		data = append(data, cr, cg, cb, ca, inst.UVOffset.X, inst.UVOffset.Y)
+	}
+	return data
+}
+
+// instanceState is a renderer's copy of an Instances on the GPU
+type instanceState struct {
+	version int
+	buffer  uint32 //instance buffer, when instancing
+	size    int    //floats the instance buffer can hold
+	batches []*Batch
+}
+
+// instancer returns the renderer for the instanced shader, or nil if the backend can't
+// draw instanced arrays and instances should be batched instead
+func (r *Renderer) instancer() *Renderer {
+	if r.instanced != nil || r.noInstancing {
+		return r.instanced
+	}
+	if ib, ok := CurrentBackend().(InstancingBackend); ok && ib.Instancing() {
+		ir, err := NewShaderRenderer(instancedVertexShader, defaultFragmentShader)
+		if err == nil {
+			r.instanced = ir
+			return ir
+		}
+		log.Println("instancing disabled:", err)
+	}
+	r.noInstancing = true
+	return nil
+}
+
+// DrawInstances draws each Instances in one of the layers in mask through cam
+func (r *Renderer) DrawInstances(cam *Camera, instances map[string]*Instances, mask uint32) {
+	if ir := r.instancer(); ir != nil {
+		ir.Settings, ir.LightColour, ir.Ambient, ir.Specular = r.Settings, r.LightColour, r.Ambient, r.Specular
+		ir.lights = r.lights
+		ir.Begin(cam)
+		for _, in := range instances {
+			if inLayers(in.Layers, mask) && len(in.Items) > 0 {
+				r.drawInstanced(ir, in)
+			}
+		}
+		ir.End()
+		return
+	}
+
+	r.Begin(cam)
+	for _, in := range instances {
+		if !inLayers(in.Layers, mask) {
+			continue
+		}
+		for _, batch := range r.instanceBatches(in) {
+			r.drawBatch(batch)
+		}
+	}
+	r.End()
+}
+
+// drawInstanced draws every copy in one call with the instanced shader renderer ir
+func (r *Renderer) drawInstanced(ir *Renderer, in *Instances) {
+	ib := CurrentBackend().(InstancingBackend)
+	if in.Mesh == nil || len(in.Mesh.Verts) == 0 {
+		return
+	}
+	if !ir.Buffers.Contains(in.Mesh) {
+		if err := ir.Buffers.AddMesh(in.Mesh); err != nil {
+			log.Printf("instances %q: %v", in.Name, err)
+			return
+		}
+	}
+
+	state := r.instanceState(in)
+	if state.buffer == 0 || state.version != in.version {
+		data := in.instanceData()
+		if state.buffer != 0 && len(data) <= state.size {
+			ib.UpdateBuffer(state.buffer, 0, data)
+		} else {
+			if state.buffer != 0 {
+				ib.DeleteBuffer(state.buffer)
+			}
+			buf, err := ib.CreateBuffer(data, true)
+			if err != nil {
+				log.Printf("instances %q: %v", in.Name, err)
+				state.buffer = 0
+				return
+			}
+			state.buffer, state.size = buf, len(data)
+		}
+		state.version = in.version
+	}
+
+	ir.setColour(diffuseRef, in.Colour)
+	tex := in.Texture.id
+	if tex == 0 {
+		tex = ir.white.id
+	}
+	ib.BindTexture(0, tex)
+
+	mesh := in.Mesh
+	attribs := InstanceLayout.Bind(ir.Attributes)
+	mesh.bind(ir.Attributes)
+	ib.BindInstanceBuffer(state.buffer, INSTANCESIZE, attribs)
+	if mesh.Indexed() {
+		ib.DrawElementsInstanced(DrawMode(mesh.Mode), mesh.ibo, mesh.iboType, 0, len(mesh.Indexes), len(in.Items))
+	} else {
+		ib.DrawArraysInstanced(DrawMode(mesh.Mode), mesh.VertOffset/mesh.stride(), mesh.VertSize, len(in.Items))
+	}
+	ib.UnbindInstanceBuffer(attribs)
+}
+
+// instanceBatches returns the copies merged into one world space batch per colour,
+// rebuilding them if the instances have changed
+func (r *Renderer) instanceBatches(in *Instances) []*Batch {
+	state := r.instanceState(in)
+	if state.batches != nil && state.version == in.version {
+		return state.batches
+	}
+	r.freeInstanceBatches(state)
+	state.version = in.version
+	state.batches = []*Batch{}
+	if in.Mesh == nil || len(in.Mesh.Verts) == 0 {
+		return state.batches
+	}
+
+	stride := in.Mesh.stride()
+	uv := in.Mesh.layout().Offset(AttribUV)
+	groups := make(map[uint32]*Batch)
+	for i := range in.Items {
+		inst := &in.Items[i]
+		col := mulColours(in.Colour, inst.colour())
+		batch := groups[col]
+		if batch == nil {
+			batch = &Batch{Texture: in.Texture, Colour: col, Layers: in.Layers, ReceiveShadows: true, mesh: &Mesh{}}
+			batch.mesh.Init()
+			batch.mesh.Layout, batch.mesh.Stride = in.Mesh.Layout, stride
+			groups[col] = batch
+			state.batches = append(state.batches, batch)
+		}
+		part := &Mesh{Verts: append([]float32{}, in.Mesh.Verts...), Layout: in.Mesh.Layout, Stride: stride, Mode: in.Mesh.Mode, Indexes: in.Mesh.Indexes}
+		m := inst.Matrix()
+		part.TransformVerts(&m)
+		for v := 0; uv >= 0 && v+stride <= len(part.Verts); v += stride {
+			part.Verts[v+uv] += inst.UVOffset.X
+			part.Verts[v+uv+1] -= inst.UVOffset.Y //the shaders flip v
+		}
+		batch.mesh.Append(part)
+	}
+	for _, batch := range state.batches {
+		if err := r.Buffers.AddMesh(batch.mesh); err != nil {
+			log.Printf("instances %q: %v", in.Name, err)
+			batch.mesh = nil
+		}
+	}
+	return state.batches
+}
+
+func (r *Renderer) instanceState(in *Instances) *instanceState {
+	state := r.instances[in]
+	if state == nil {
+		state = &instanceState{version: -1}
+		r.instances[in] = state
+	}
+	return state
+}
+
+func (r *Renderer) freeInstanceBatches(state *instanceState) {
+	for _, batch := range state.batches {
+		if batch.mesh != nil {
+			r.Buffers.RemoveMesh(batch.mesh)
+		}
+	}
+	state.batches = nil
+}
+
+// FreeInstances frees the buffers used to draw in, e.g. after removing it from a scene
+func (r *Renderer) FreeInstances(in *Instances) {
+	state := r.instances[in]
+	if state == nil {
+		return
+	}
+	r.freeInstanceBatches(state)
+	if state.buffer != 0 {
+		CurrentBackend().DeleteBuffer(state.buffer)
+	}
+	if r.instanced != nil && in.Mesh != nil {
+		r.instanced.Buffers.RemoveMesh(in.Mesh)
+	}
+	delete(r.instances, in)
+}
+
+// mulColours multiplies two colours channel by channel
+func mulColours(a, b uint32) uint32 {
+	var col uint32
+	for shift := 0; shift < 32; shift += 8 {
+		ca, cb := (a>>shift)&255, (b>>shift)&255
+		col |= (ca * cb / 255) << shift
+	}
+	return col
+}
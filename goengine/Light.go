@@ -0,0 +1,259 @@
+package goengine
+
+import (
+	"sort"
+
+	"github.com/chewxy/math32"
+)
+
+// LightType is how a light shines
+type LightType int
+
+const (
+	DirectionalLight LightType = iota //parallel rays like the sun, no position
+	PointLight                        //in every direction from its position, fading with distance
+	SpotLight                         //a point light limited to a cone
+)
+
+// DefaultMaxLights is the size of the light arrays in the bundled shaders
+const DefaultMaxLights = 8
+
+// Light lights the shapes of a Scene. Lights can be attached to a shape so they move with it,
+// e.g. a car's headlights; Scene.Draw updates them each frame.
+type Light struct {
+	Name        string
+	Type        LightType
+	Colour      uint32
+	Intensity   float32 //multiplies the colour, 0 is 1
+	Position    Vec3    //of point and spot lights, relative to Node if set
+	Direction   Vec3    //the way directional and spot lights shine, turned by Node if set
+	Attenuation Vec3    //constant, linear and quadratic fall off with distance of point and spot lights
+	InnerCone   float32 //spot light half angle in degrees lit at full brightness
+	OuterCone   float32 //spot light half angle in degrees fading to dark at the edge
+	Enabled     bool
+	Node        *Shape //moves the light with a shape
+
+	world   Mat4s //where the light was last placed
+	placed  bool
+	lastPos Vec3 //world position and direction from the last update
+	lastDir Vec3
+}
+
+// NewDirectionalLight returns a light shining along dir, like the sun
+func NewDirectionalLight(name string, dir Vec3, col uint32) *Light {
+	return &Light{Name: name, Type: DirectionalLight, Colour: col, Direction: dir, Enabled: true}
+}
+
+// NewPointLight returns a light shining in every direction from pos, fading to about
+// 1% of its brightness at distance reach
+func NewPointLight(name string, pos Vec3, col uint32, reach float32) *Light {
+	return &Light{Name: name, Type: PointLight, Colour: col, Position: pos, Attenuation: AttenuationForRange(reach), Enabled: true}
+}
+
+// NewSpotLight returns a point light shining along dir within a cone. It is fully lit
+// within inner degrees of dir and fades to dark at outer degrees.
+func NewSpotLight(name string, pos, dir Vec3, col uint32, reach, inner, outer float32) *Light {
+	l := NewPointLight(name, pos, col, reach)
+	l.Type, l.Direction, l.InnerCone, l.OuterCone = SpotLight, dir, inner, outer
+	return l
+}
+
+// AttenuationForRange returns constant, linear and quadratic attenuation that leaves about
+// 1% of a light's brightness at distance reach. 0 doesn't fade.
+func AttenuationForRange(reach float32) Vec3 {
+	if reach <= 0 {
+		return Vec3{1, 0, 0}
+	}
+	return Vec3{1, 4.5 / reach, 75 / (reach * reach)}
+}
+
+// Place moves the light with a transform, e.g. an entity's world matrix. Position and
+// Direction are relative to it until Place is called again. Lights with a Node are placed
+// at the node's model matrix instead.
+func (l *Light) Place(m *Mat4s) {
+	l.world.CopyFrom(m)
+	l.placed = true
+}
+
+// update works out the light's world position and direction for this frame
+func (l *Light) update() {
+	m := Identity4()
+	switch {
+	case l.Node != nil:
+		model := l.Node.ModelMatrix()
+		m = &model
+	case l.placed:
+		m = &l.world
+	}
+	l.lastPos = l.Position.MulMat4(m)
+	dir := V4FromV3(l.Direction, 0).MulMat4(m)
+	l.lastDir = Vec3{dir.X, dir.Y, dir.Z}.Normal()
+	if l.lastDir == (Vec3{}) {
+		l.lastDir = Vec3{0, 0, -1}
+	}
+}
+
+// WorldPosition returns where the light was at the last update
+func (l *Light) WorldPosition() Vec3 {
+	return l.lastPos
+}
+
+// WorldDirection returns the normalized way the light was shining at the last update
+func (l *Light) WorldDirection() Vec3 {
+	return l.lastDir
+}
+
+// radiance returns the light's colour times its intensity
+func (l *Light) radiance() Vec3 {
+	intensity := l.Intensity
+	if intensity == 0 {
+		intensity = 1
+	}
+	r, g, b, _ := ColToRGBA(l.Colour)
+	return Vec3{r, g, b}.MulScalar(intensity)
+}
+
+// falloff returns the attenuation, where 0,0,0 doesn't fade
+func (l *Light) falloff() Vec3 {
+	if l.Attenuation == (Vec3{}) {
+		return Vec3{1, 0, 0}
+	}
+	return l.Attenuation
+}
+
+// cones returns the cosines of the outer and inner cone angles. Lights that aren't spot
+// lights return -2 and -1 so every direction is fully lit.
+func (l *Light) cones() (outer, inner float32) {
+	if l.Type != SpotLight {
+		return -2, -1
+	}
+	outerAngle := math32.Max(l.OuterCone, l.InnerCone)
+	inner = math32.Cos(DegToRad(math32.Min(l.InnerCone, outerAngle)))
+	outer = math32.Cos(DegToRad(outerAngle))
+	if inner-outer < 1e-4 {
+		outer = inner - 1e-4 //hard edged
+	}
+	return outer, inner
+}
+
+// illuminate returns the diffuse and specular light reaching a surface at pos facing normal,
+// seen from eye with a specular exponent of shininess. It matches the shader lighting.
+func (l *Light) illuminate(pos, normal, eye Vec3, shininess float32) (diffuse, specular Vec3) {
+	toLight := l.lastDir.Negate()
+	atten := float32(1)
+	if l.Type != DirectionalLight {
+		toLight = l.lastPos.Sub(pos)
+		dist := toLight.Length()
+		if dist > 0 {
+			toLight = toLight.DivScalar(dist)
+		}
+		a := l.falloff()
+		atten = 1 / math32.Max(a.X+a.Y*dist+a.Z*dist*dist, 1e-4)
+		outer, inner := l.cones()
+		atten *= smoothstep(outer, inner, toLight.Negate().Dot(l.lastDir))
+	}
+	nDotL := normal.Dot(toLight)
+	if nDotL <= 0 || atten <= 0 {
+		return Vec3{}, Vec3{}
+	}
+	radiance := l.radiance().MulScalar(atten)
+	half := toLight.Add(eye.Sub(pos).Normal()).Normal()
+	return radiance.MulScalar(nDotL), radiance.MulScalar(math32.Pow(math32.Max(normal.Dot(half), 0), shininess))
+}
+
+// smoothstep matches the GLSL function
+func smoothstep(edge0, edge1, x float32) float32 {
+	t := Clamp((x-edge0)/(edge1-edge0), 0, 1)
+	return t * t * (3 - 2*t)
+}
+
+// AddLight adds a light to the scene, replacing any light with the same name
+func (s *Scene) AddLight(l *Light) {
+	for i, old := range s.Lights {
+		if old.Name == l.Name {
+			s.Lights[i] = l
+			return
+		}
+	}
+	s.Lights = append(s.Lights, l)
+}
+
+// RemoveLight removes the named light from the scene
+func (s *Scene) RemoveLight(name string) {
+	for i, l := range s.Lights {
+		if l.Name == name {
+			s.Lights = append(s.Lights[:i], s.Lights[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasLight returns true if l is one of the scene's lights
+func (s *Scene) hasLight(l *Light) bool {
+	for _, old := range s.Lights {
+		if old == l {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLight removes l from the scene, leaving other lights with the same name
+func (s *Scene) removeLight(l *Light) {
+	for i, old := range s.Lights {
+		if old == l {
+			s.Lights = append(s.Lights[:i], s.Lights[i+1:]...)
+			return
+		}
+	}
+}
+
+// Light returns the named light, or false if there is no light with that name
+func (s *Scene) Light(name string) (*Light, bool) {
+	for _, l := range s.Lights {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// updateLights moves the scene's lights with their nodes
+func (s *Scene) updateLights() {
+	for _, l := range s.Lights {
+		l.update()
+	}
+}
+
+// maxLights returns how many lights the scene draws with at once
+func (s *Scene) maxLights() int {
+	if s.Renderer != nil {
+		return s.Renderer.MaxLights()
+	}
+	if s.lighting.MaxLights > 0 {
+		return s.lighting.MaxLights
+	}
+	return DefaultMaxLights
+}
+
+// nearestLights returns up to limit enabled lights: directional lights first, then the
+// point and spot lights nearest to eye
+func nearestLights(lights []*Light, limit int, eye Vec3) []*Light {
+	enabled := make([]*Light, 0, len(lights))
+	for _, l := range lights {
+		if l.Enabled {
+			enabled = append(enabled, l)
+		}
+	}
+	if len(enabled) <= limit {
+		return enabled
+	}
+	distance := func(l *Light) float32 {
+		if l.Type == DirectionalLight {
+			return -1
+		}
+		return l.lastPos.DistToSquared(eye)
+	}
+	sort.SliceStable(enabled, func(i, j int) bool { return distance(enabled[i]) < distance(enabled[j]) })
+	return enabled[:limit]
+}
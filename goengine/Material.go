@@ -0,0 +1,141 @@
+package goengine
+
+import (
+	"sort"
+
+	"github.com/chewxy/math32"
+)
+
+// BlendMode is how a material's colour is combined with what has already been drawn
+type BlendMode int
+
+const (
+	BlendOpaque   BlendMode = iota
+	BlendAlpha              //mixed by the colour's alpha, drawn after opaque shapes
+	BlendAdditive           //added, e.g. for glows
+)
+
+// CullMode is which faces of a material aren't drawn
+type CullMode int
+
+const (
+	CullBack CullMode = iota
+	CullNone          //two sided, e.g. for leaves
+	CullFront
+)
+
+// DepthMode is how a material uses the depth buffer
+type DepthMode int
+
+const (
+	DepthReadWrite DepthMode = iota
+	DepthRead                //hidden by what is in front, but doesn't hide what is behind
+	DepthOff
+)
+
+// RenderState is the blend, cull and depth state something is drawn with.
+// The zero value is the state set by Backend.Init.
+type RenderState struct {
+	Blend BlendMode
+	Cull  CullMode
+	Depth DepthMode
+}
+
+// TextureSlot is a texture used by a material, bound to the texture unit of the same number
+type TextureSlot int
+
+const (
+	DiffuseMap TextureSlot = iota
+	NormalMap
+	SpecularMap
+	EmissiveMap
+	TextureSlots //the number of slots
+)
+
+// Material is how a surface looks. One material can be shared by many shapes, so changing
+// it changes them all. A Renderer sets it through the vs.txt/fs.txt uniforms; fixed-function
+// backends only use the diffuse colour and texture.
+type Material struct {
+	Name         string
+	Diffuse      uint32 //0xAABBGGRR, alpha is the opacity
+	Ambient      uint32 //multiplies the renderer's ambient light
+	Specular     uint32
+	Emissive     uint32
+	Shininess    float32 //specular exponent, 0 is 50
+	Reflective   bool    //moves the diffuse texture with the light to fake a reflection
+	Illumination int     //as MTL illum: 0 and 1 are unshaded, 2 adds shading and highlights
+	Textures     [TextureSlots]Texture
+	UVScale      Vec2    //repeats the textures, 0,0 is 1,1
+	UVOffset     Vec2    //moves the textures
+	UVRotation   float32 //turns the textures, in degrees
+	RenderState
+}
+
+// NewMaterial returns a shaded material with the renderer's default ambient and specular
+func NewMaterial(name string, diffuse uint32) *Material {
+	return &Material{
+		Name:         name,
+		Diffuse:      diffuse,
+		Ambient:      0xffffffff,
+		Specular:     0xff202020,
+		Illumination: 2,
+		UVScale:      Vec2{1, 1},
+	}
+}
+
+// SetTexture loads an image file into a texture slot
+func (m *Material) SetTexture(slot TextureSlot, file string) {
+	m.Textures[slot].Delete()
+	m.Textures[slot].LoadTexture(file)
+}
+
+// Transparent returns true if the material is blended with what is behind it
+func (m *Material) Transparent() bool {
+	return m.Blend != BlendOpaque
+}
+
+// uvTransform returns the texture scale and rotation as the columns of a 2x2 matrix
+func (m *Material) uvTransform() [4]float32 {
+	scale := m.UVScale
+	if scale == (Vec2{}) {
+		scale = Vec2{1, 1}
+	}
+	rad := m.UVRotation * math32.Pi / 180
+	sin, cos := math32.Sin(rad), math32.Cos(rad)
+	return [4]float32{cos * scale.X, sin * scale.X, -sin * scale.Y, cos * scale.Y}
+}
+
+func (m *Material) shininess() float32 {
+	if m.Shininess <= 0 {
+		return 50
+	}
+	return m.Shininess
+}
+
+// sortShapes returns the shapes in one of the layers in mask in the order to draw them:
+// opaque shapes first, then transparent ones from the furthest from eye to the nearest
+func sortShapes(shapes map[string]*Shape, mask uint32, eye Vec3) []*Shape {
+	sorted := make([]*Shape, 0, len(shapes))
+	transparent := 0
+	for _, shape := range shapes {
+		if !shape.InLayers(mask) {
+			continue
+		}
+		sorted = append(sorted, shape)
+		if shape.transparent() {
+			transparent++
+		}
+	}
+	if transparent == 0 {
+		return sorted
+	}
+	distance := func(s *Shape) float32 { return s.Position.Sub(eye).Length() }
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].transparent(), sorted[j].transparent()
+		if ti != tj {
+			return tj
+		}
+		return ti && distance(sorted[i]) > distance(sorted[j])
+	})
+	return sorted
+}
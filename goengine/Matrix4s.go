@@ -99,6 +99,16 @@ func (m *Mat4s) ToArray() []float32 {
 	return array
 }
 
+// ToGLArray returns the matrix elements in column major order as expected by OpenGL
+func (m *Mat4s) ToGLArray() []float32 {
+	return []float32{
+		m.m0, m.m1, m.m2, m.m3,
+		m.m4, m.m5, m.m6, m.m7,
+		m.m8, m.m9, m.m10, m.m11,
+		m.m12, m.m13, m.m14, m.m15,
+	}
+}
+
 // SetIdentity sets this matrix as the identity matrix.
 func (m *Mat4s) SetIdentity() {
 	m.Set(
@@ -164,6 +174,26 @@ func (m *Mat4s) SetMul(other *Mat4s) {
 	m.MulMatrices(m, other)
 }
 
+// SetAdd adds each element of other to this matrix (used to blend skinning matrices).
+func (m *Mat4s) SetAdd(other *Mat4s) {
+	m.m0 += other.m0
+	m.m4 += other.m4
+	m.m8 += other.m8
+	m.m12 += other.m12
+	m.m1 += other.m1
+	m.m5 += other.m5
+	m.m9 += other.m9
+	m.m13 += other.m13
+	m.m2 += other.m2
+	m.m6 += other.m6
+	m.m10 += other.m10
+	m.m14 += other.m14
+	m.m3 += other.m3
+	m.m7 += other.m7
+	m.m11 += other.m11
+	m.m15 += other.m15
+}
+
 // SetMulScalar multiplies each element of this matrix by the specified scalar.
 func (m *Mat4s) MulScalar(s float32) {
 	m.m0 *= s
@@ -1,10 +1,6 @@
 package goengine
 
-import (
-	"unsafe"
-
-	"github.com/go-gl/gl/v2.1/gl"
-)
+import "fmt"
 
 type Mesh struct {
 	Verts       []float32
@@ -15,8 +11,22 @@ type Mesh struct {
 	VertOffset  int
 	VertSize    int
 	Mode        int
+	Skinned     bool
+	Indexes     []uint32      //vertex numbers drawn in Mode order, if the mesh is indexed
+	Layout      *VertexLayout //how Verts are packed, nil is PackedLayout (SkinnedLayout if Skinned)
+
+	vbo     uint32    //GL buffer holding the mesh, set by RenderBuffer.AddMesh
+	ibo     uint32    //GL buffer holding Indexes, set by RenderBuffer.AddMesh
+	iboType IndexType //size of the indices in ibo
+
+	MorphTargets []MorphTarget
+	MorphWeights []float32
+	BaseVerts    []float32 //unmorphed vertices, set when the first morph target is added
 }
 
+// Skinned vertices follow the packed vertex with 4 joint indices and 4 joint weights
+const SKINNEDVERTSIZE = VERTSIZE + 8
+
 func (m *Mesh) Init() {
 	m.VC = 0
 	m.MaterialRef = 0
@@ -24,44 +34,287 @@ func (m *Mesh) Init() {
 	m.BufRef = 0
 	m.VertOffset = 0
 	m.VertSize = 0
-	m.Mode = gl.TRIANGLES
+	m.Mode = int(DrawTriangles)
 }
 
+// AddPackedVert adds a vertex in the mesh's layout. Attributes it doesn't take are left zero.
 func (m *Mesh) AddPackedVert(pos Vec3, normal Vec3, uv Vec2, col uint32) {
-	m.Verts = append(m.Verts, pos.X)
-	m.Verts = append(m.Verts, pos.Y)
-	m.Verts = append(m.Verts, pos.Z)
-	m.Verts = append(m.Verts, normal.X)
-	m.Verts = append(m.Verts, normal.Y)
-	m.Verts = append(m.Verts, normal.Z)
-	m.Verts = append(m.Verts, uv.X)
-	m.Verts = append(m.Verts, uv.Y)
-	m.Verts = append(m.Verts, convertColToFloat(col))
+	m.AddVertex(Vertex{Position: pos, Normal: normal, UV: uv, Colour: col})
+}
+
+// AddSkinnedVert adds a packed vertex followed by the joints that move it and their weights
+func (m *Mesh) AddSkinnedVert(pos Vec3, normal Vec3, uv Vec2, col uint32, joints [4]uint8, weights [4]float32) {
+	m.Skinned = true
+	if m.Layout == nil {
+		m.Layout = SkinnedLayout
+	}
+	m.AddVertex(Vertex{Position: pos, Normal: normal, UV: uv, Colour: col, Joints: joints, Weights: weights})
+}
+
+// AddVertex packs a vertex onto the mesh in its layout
+func (m *Mesh) AddVertex(v Vertex) {
+	layout := m.layout()
+	m.Verts = layout.Append(m.Verts, v)
+	m.Stride = layout.Stride
+}
+
+// Vertex unpacks vertex number i
+func (m *Mesh) Vertex(i int) Vertex {
+	return m.layout().Vertex(m.Verts, i)
+}
+
+// SetLayout repacks the mesh's vertices into layout. Attributes the old layout didn't have are
+// zero, except colours which are white. The mesh must be added to a RenderBuffer again.
+func (m *Mesh) SetLayout(layout *VertexLayout) {
+	old := m.layout()
+	if old != layout {
+		m.Verts = layout.Convert(m.Verts, old)
+		if m.BaseVerts != nil {
+			m.BaseVerts = layout.Convert(m.BaseVerts, old)
+		}
+	}
+	m.Layout, m.Stride = layout, layout.Stride
+	m.VC = uint32(len(m.Verts))
 }
 
-func convertColToFloat(col uint32) float32 {
-	return float32(col&255)/256 + float32((col>>8)&255) + float32((col>>16)&255)*256
+func (m *Mesh) layout() *VertexLayout {
+	switch {
+	case m.Layout != nil:
+		return m.Layout
+	case m.Skinned || m.Stride == SKINNEDVERTSIZE:
+		return SkinnedLayout
+	}
+	return PackedLayout
+}
+
+// GenerateTangents sets the tangent of each vertex from its triangles' texture coordinates,
+// for normal mapping. The mesh's layout must have an a_Tangent attribute.
+func (m *Mesh) GenerateTangents() error {
+	layout := m.layout()
+	offset := layout.Offset(AttribTangent)
+	if offset < 0 || layout.Offset(AttribUV) < 0 || layout.Offset(AttribNormal) < 0 {
+		return fmt.Errorf("vertex layout %v has no tangents, normals or uvs", layout)
+	}
+	n := m.VertCount()
+	verts := make([]Vertex, n)
+	for i := range verts {
+		verts[i] = m.Vertex(i)
+	}
+	tangents, bitangents := make([]Vec3, n), make([]Vec3, n)
+	m.Triangles(func(a, b, c int) {
+		e1, e2 := verts[b].Position.Sub(verts[a].Position), verts[c].Position.Sub(verts[a].Position)
+		du1, dv1 := verts[b].UV.X-verts[a].UV.X, verts[b].UV.Y-verts[a].UV.Y
+		du2, dv2 := verts[c].UV.X-verts[a].UV.X, verts[c].UV.Y-verts[a].UV.Y
+		det := du1*dv2 - du2*dv1
+		if det == 0 {
+			return //no texture on this triangle to follow
+		}
+		r := 1 / det
+		t := e1.MulScalar(dv2 * r).Sub(e2.MulScalar(dv1 * r))
+		bt := e2.MulScalar(du1 * r).Sub(e1.MulScalar(du2 * r))
+		for _, v := range [3]int{a, b, c} {
+			tangents[v] = tangents[v].Add(t)
+			bitangents[v] = bitangents[v].Add(bt)
+		}
+	})
+
+	stride := layout.Stride
+	for i, v := range verts {
+		//Gram-Schmidt orthogonalize against the normal, keeping which way the bitangent points
+		t := tangents[i].Sub(v.Normal.MulScalar(v.Normal.Dot(tangents[i]))).Normal()
+		w := float32(1)
+		if v.Normal.Cross(t).Dot(bitangents[i]) < 0 {
+			w = -1
+		}
+		m.Verts[i*stride+offset], m.Verts[i*stride+offset+1], m.Verts[i*stride+offset+2], m.Verts[i*stride+offset+3] = t.X, t.Y, t.Z, w
+	}
+	return nil
 }
 
-func (m *Mesh) RenderMesh() {
-	SetRenderBuffer(uint32(m.BufRef), int32(m.Stride))
-	gl.DrawArrays(uint32(m.Mode), int32(m.VertOffset), int32(m.VertSize))
+// RenderMesh binds the mesh's vertex buffer to the shader attributes (in location order,
+// as returned by GetAttributes) and draws it
+func (m *Mesh) RenderMesh(attributes []string) {
+	m.bind(attributes)
+	m.Render()
 }
 
+// bind sets up the attributes for drawing the mesh. Indices count from the mesh's first
+// vertex rather than the start of the buffer, so indexed meshes bind from there.
+func (m *Mesh) bind(attributes []string) {
+	attribs := m.layout().Bind(attributes)
+	if m.Indexed() {
+		for i := range attribs {
+			attribs[i].Offset += m.VertOffset
+		}
+	}
+	CurrentBackend().BindVertexBuffer(m.vbo, m.stride(), attribs)
+}
+
+// Render draws the mesh from the vertex buffer bound by RenderMesh
 func (m *Mesh) Render() {
-	gl.DrawArrays(uint32(m.Mode), int32(m.VertOffset), int32(m.VertSize))
+	if m.Indexed() {
+		m.RenderIndexed(0, len(m.Indexes))
+		return
+	}
+	CurrentBackend().DrawArrays(DrawMode(m.Mode), m.VertOffset/m.stride(), m.VertSize)
 }
 
-func (m *Mesh) RenderIndexed(indexCo int32, indexes []*uint32) {
-	gl.DrawElements(uint32(m.Mode), indexCo, gl.UNSIGNED_INT, unsafe.Pointer(&indexes))
+// RenderIndexed draws count of the mesh's indices starting from index first
+func (m *Mesh) RenderIndexed(first, count int) {
+	CurrentBackend().DrawElements(DrawMode(m.Mode), m.ibo, m.iboType, first, count)
 }
 
+// Indexed returns true if the mesh draws its vertices in the order of Indexes
+func (m *Mesh) Indexed() bool {
+	return len(m.Indexes) > 0
+}
+
+// IndexType returns the smallest index size that can number every vertex of the mesh
+func (m *Mesh) IndexType() IndexType {
+	if m.VertCount() <= 1<<16 {
+		return Index16
+	}
+	return Index32
+}
+
+// AddStrip appends a triangle strip of vertex numbers, making the mesh draw triangle strips.
+// Strips are joined with degenerate triangles so the whole mesh still draws in one call.
+func (m *Mesh) AddStrip(strip []uint32) {
+	m.Mode = int(DrawTriangleStrip)
+	if len(strip) == 0 {
+		return
+	}
+	if n := len(m.Indexes); n > 0 {
+		m.Indexes = append(m.Indexes, m.Indexes[n-1], strip[0])
+		if n%2 == 1 {
+			m.Indexes = append(m.Indexes, strip[0]) //start the strip on an even index to keep its winding
+		}
+	}
+	m.Indexes = append(m.Indexes, strip...)
+}
+
+// Triangles calls fn with the vertex numbers of each triangle the mesh draws, in order and
+// facing the way they are drawn. Degenerate triangles joining strips are skipped.
+func (m *Mesh) Triangles(fn func(a, b, c int)) {
+	count := m.VertCount()
+	at := func(i int) int { return i }
+	if m.Indexed() {
+		count = len(m.Indexes)
+		at = func(i int) int { return int(m.Indexes[i]) }
+	}
+	switch DrawMode(m.Mode) {
+	case DrawTriangles:
+		for i := 0; i+2 < count; i += 3 {
+			fn(at(i), at(i+1), at(i+2))
+		}
+	case DrawTriangleStrip:
+		for i := 0; i+2 < count; i++ {
+			a, b, c := at(i), at(i+1), at(i+2)
+			if a == b || b == c || a == c {
+				continue
+			}
+			if i%2 == 1 {
+				a, b = b, a
+			}
+			fn(a, b, c)
+		}
+	}
+}
+
+// Append adds another mesh's vertices, and its indices numbered after the mesh's own, e.g. to
+// merge meshes into a batch. The merged mesh is indexed if either is. Triangle lists and
+// strips can be mixed, taking the primitive of the first mesh appended.
+func (m *Mesh) Append(other *Mesh) {
+	if len(m.Verts) == 0 && !m.Indexed() {
+		m.Mode = other.Mode
+		m.Layout, m.Stride, m.Skinned = other.Layout, other.stride(), other.Skinned
+	}
+	base := uint32(m.VertCount())
+	if m.Indexed() || other.Indexed() || m.Mode != other.Mode {
+		if !m.Indexed() {
+			m.Indexes = vertexNumbers(0, int(base))
+		}
+		switch {
+		case m.Mode == other.Mode && DrawMode(m.Mode) == DrawTriangleStrip:
+			strip := other.Indexes
+			if !other.Indexed() {
+				strip = vertexNumbers(0, other.VertCount())
+			}
+			moved := make([]uint32, len(strip))
+			for i, index := range strip {
+				moved[i] = index + base
+			}
+			m.AddStrip(moved)
+		case DrawMode(m.Mode) == DrawTriangleStrip:
+			other.Triangles(func(a, b, c int) {
+				m.AddStrip([]uint32{uint32(a) + base, uint32(b) + base, uint32(c) + base})
+			})
+		default:
+			other.Triangles(func(a, b, c int) {
+				m.Indexes = append(m.Indexes, uint32(a)+base, uint32(b)+base, uint32(c)+base)
+			})
+		}
+	}
+	if layout := m.layout(); layout != other.layout() {
+		m.Verts = append(m.Verts, layout.Convert(other.Verts, other.layout())...)
+	} else {
+		m.Verts = append(m.Verts, other.Verts...)
+	}
+	m.VC = uint32(len(m.Verts))
+}
+
+// vertexNumbers returns first, first+1 ... first+count-1
+func vertexNumbers(first, count int) []uint32 {
+	numbers := make([]uint32, count)
+	for i := range numbers {
+		numbers[i] = uint32(first + i)
+	}
+	return numbers
+}
+
+// indexSize returns the number of bytes in an index
+func indexSize(typ IndexType) int {
+	if typ == Index16 {
+		return 2
+	}
+	return 4
+}
+
+// shortIndexes converts indices for a 16-bit index buffer
+func shortIndexes(indices []uint32) []uint16 {
+	short := make([]uint16, len(indices))
+	for i, index := range indices {
+		short[i] = uint16(index)
+	}
+	return short
+}
+
+// TransformVerts moves the mesh's positions by matrix and turns its normals and tangents to match
 func (m *Mesh) TransformVerts(matrix *Mat4s) {
-	for i := m.VertOffset; i < len(m.Verts) && i < (m.VertOffset+int(m.VC)); i += m.Stride {
-		v := Vec3{m.Verts[i], m.Verts[i+1], m.Verts[i+2]}
-		v.MulMat4(matrix)
-		m.Verts[i] = v.X
-		m.Verts[i+1] = v.Y
-		m.Verts[i+2] = v.Z
+	layout := m.layout()
+	stride := layout.Stride
+	pos, nrm, tan := layout.Offset(AttribPosition), layout.Offset(AttribNormal), layout.Offset(AttribTangent)
+	if pos < 0 {
+		return
+	}
+	normalMatrix, err := matrix.Inverse()
+	if err == nil {
+		normalMatrix = normalMatrix.Transpose()
+	}
+	direction := func(v []float32, mat *Mat4s) {
+		d := V4FromV3(Vec3{v[0], v[1], v[2]}, 0).MulMat4(mat)
+		n := Vec3{d.X, d.Y, d.Z}.Normal()
+		v[0], v[1], v[2] = n.X, n.Y, n.Z
+	}
+	for i := 0; i+stride <= len(m.Verts); i += stride {
+		v := m.Verts[i+pos:]
+		p := Vec3{v[0], v[1], v[2]}.MulMat4(matrix)
+		v[0], v[1], v[2] = p.X, p.Y, p.Z
+		if nrm >= 0 && err == nil {
+			direction(m.Verts[i+nrm:], normalMatrix)
+		}
+		if tan >= 0 {
+			direction(m.Verts[i+tan:], matrix)
+		}
 	}
 }
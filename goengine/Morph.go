@@ -0,0 +1,188 @@
+package goengine
+
+import (
+	"fmt"
+)
+
+// MorphTarget holds per-vertex position and normal offsets from a mesh's base shape
+type MorphTarget struct {
+	Name      string
+	Positions []Vec3
+	Normals   []Vec3 //optional, nil leaves normals unchanged
+}
+
+// VertCount returns the number of vertices in the mesh
+func (m *Mesh) VertCount() int {
+	return len(m.Verts) / m.stride()
+}
+
+// AddMorphTarget adds position (and optionally normal) deltas for every vertex of the mesh.
+// The current vertices become the base shape that weighted targets are added to.
+func (m *Mesh) AddMorphTarget(name string, positions, normals []Vec3) error {
+	n := m.VertCount()
+	if len(positions) != n || (normals != nil && len(normals) != n) {
+		return fmt.Errorf("morph target %q has %d deltas, mesh has %d vertices", name, len(positions), n)
+	}
+	if m.BaseVerts == nil {
+		m.BaseVerts = make([]float32, len(m.Verts))
+		copy(m.BaseVerts, m.Verts)
+	}
+	m.MorphTargets = append(m.MorphTargets, MorphTarget{Name: name, Positions: positions, Normals: normals})
+	m.MorphWeights = append(m.MorphWeights, 0)
+	return nil
+}
+
+// AddMorphTargetFromVerts adds a target from a second set of vertices with the same layout
+// and vertex count, such as a cube and a sphere generated with the same number of vertices
+func (m *Mesh) AddMorphTargetFromVerts(name string, verts []float32) error {
+	base := m.BaseVerts
+	if base == nil {
+		base = m.Verts
+	}
+	if len(verts) != len(base) {
+		return fmt.Errorf("morph target %q has %d floats, mesh has %d", name, len(verts), len(base))
+	}
+	pos, norm := m.layout().Offset(AttribPosition), m.layout().Offset(AttribNormal)
+	if pos < 0 {
+		return fmt.Errorf("morph target %q: mesh vertices have no position", name)
+	}
+	stride := m.stride()
+	n := len(verts) / stride
+	positions := make([]Vec3, n)
+	var normals []Vec3
+	if norm >= 0 {
+		normals = make([]Vec3, n)
+	}
+	for v := 0; v < n; v++ {
+		i := v*stride + pos
+		positions[v] = Vec3{verts[i] - base[i], verts[i+1] - base[i+1], verts[i+2] - base[i+2]}
+		if normals != nil {
+			i = v*stride + norm
+			normals[v] = Vec3{verts[i] - base[i], verts[i+1] - base[i+1], verts[i+2] - base[i+2]}
+		}
+	}
+	return m.AddMorphTarget(name, positions, normals)
+}
+
+// AddSpherifyTarget adds a target that pushes every vertex out to a sphere around center,
+// so a subdivided cube or cylinder can be morphed smoothly into a ball
+func (m *Mesh) AddSpherifyTarget(name string, center Vec3, radius float32) error {
+	base := m.BaseVerts
+	if base == nil {
+		base = m.Verts
+	}
+	posOffset, norm := m.layout().Offset(AttribPosition), m.layout().Offset(AttribNormal)
+	if posOffset < 0 {
+		return fmt.Errorf("morph target %q: mesh vertices have no position", name)
+	}
+	stride := m.stride()
+	n := len(base) / stride
+	positions := make([]Vec3, n)
+	var normals []Vec3
+	if norm >= 0 {
+		normals = make([]Vec3, n)
+	}
+	for v := 0; v < n; v++ {
+		i := v*stride + posOffset
+		pos := Vec3{base[i], base[i+1], base[i+2]}
+		dir := pos.Sub(center)
+		if dir.LengthSq() == 0 {
+			dir = Vec3{0, 1, 0}
+		}
+		dir.SetNormal()
+		positions[v] = center.Add(dir.MulScalar(radius)).Sub(pos)
+		if normals != nil {
+			i = v*stride + norm
+			normals[v] = dir.Sub(Vec3{base[i], base[i+1], base[i+2]})
+		}
+	}
+	return m.AddMorphTarget(name, positions, normals)
+}
+
+func (m *Mesh) stride() int {
+	if m.Layout != nil {
+		return m.Layout.Stride
+	}
+	if m.Stride == 0 {
+		return VERTSIZE
+	}
+	return m.Stride
+}
+
+// MorphTarget returns the index of the named target
+func (m *Mesh) MorphTarget(name string) (int, bool) {
+	for i := range m.MorphTargets {
+		if m.MorphTargets[i].Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// SetMorphWeight sets the weight of the named target, returning false if it doesn't exist
+func (m *Mesh) SetMorphWeight(name string, weight float32) bool {
+	i, ok := m.MorphTarget(name)
+	if ok {
+		m.MorphWeights[i] = weight
+	}
+	return ok
+}
+
+// ApplyMorphs blends the weighted targets onto the base shape on the CPU, writing into Verts
+func (m *Mesh) ApplyMorphs() {
+	if m.BaseVerts == nil {
+		return
+	}
+	copy(m.Verts, m.BaseVerts)
+	pos, norm := m.layout().Offset(AttribPosition), m.layout().Offset(AttribNormal)
+	if pos < 0 {
+		return
+	}
+	stride := m.stride()
+	for t, target := range m.MorphTargets {
+		w := m.MorphWeights[t]
+		if w == 0 {
+			continue
+		}
+		for v, d := range target.Positions {
+			i := v*stride + pos
+			m.Verts[i] += d.X * w
+			m.Verts[i+1] += d.Y * w
+			m.Verts[i+2] += d.Z * w
+		}
+		if norm < 0 {
+			continue
+		}
+		for v, d := range target.Normals {
+			i := v*stride + norm
+			m.Verts[i] += d.X * w
+			m.Verts[i+1] += d.Y * w
+			m.Verts[i+2] += d.Z * w
+		}
+	}
+	if norm < 0 {
+		return
+	}
+	for i := norm; i+2 < len(m.Verts); i += stride {
+		n := Vec3{m.Verts[i], m.Verts[i+1], m.Verts[i+2]}.Normal()
+		m.Verts[i], m.Verts[i+1], m.Verts[i+2] = n.X, n.Y, n.Z
+	}
+}
+
+// BindMorphTargets binds tracks with the given target name to the mesh's morph weights.
+// Each track's property is the name of a morph target and should be a float track.
+func (an *Animator) BindMorphTargets(target string, mesh *Mesh, clip *AnimationClip) error {
+	for _, track := range clip.Tracks {
+		if track.Target != target {
+			continue
+		}
+		i, ok := mesh.MorphTarget(track.Property)
+		if !ok {
+			return fmt.Errorf("animation %q: mesh %q has no morph target %q", clip.Name, target, track.Property)
+		}
+		an.BindFunc(target, track.Property, TrackFloat,
+			func() Vec4 { return Vec4{mesh.MorphWeights[i], 0, 0, 0} },
+			func(v Vec4) { mesh.MorphWeights[i] = v.X })
+	}
+	return nil
+}
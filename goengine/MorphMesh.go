@@ -0,0 +1,143 @@
+package goengine
+
+import "fmt"
+
+// morphVertexShader is vs.txt with the position and normal deltas of MaxGPUMorphs
+// targets added to each vertex
+var morphVertexShader = shaderVariant{
+	inputs: `uniform vec4 u_MorphWeights;   // weights of the 4 active morph targets (MaxGPUMorphs)
+attribute vec3 a_MorphPos0;    // position deltas of the active targets
+attribute vec3 a_MorphPos1;
+attribute vec3 a_MorphPos2;
+attribute vec3 a_MorphPos3;
+attribute vec3 a_MorphNormal0; // normal deltas of the active targets
+attribute vec3 a_MorphNormal1;
+attribute vec3 a_MorphNormal2;
+attribute vec3 a_MorphNormal3;`,
+	transform: `localPosition += u_MorphWeights.x * a_MorphPos0 + u_MorphWeights.y * a_MorphPos1
+		+ u_MorphWeights.z * a_MorphPos2 + u_MorphWeights.w * a_MorphPos3;
+	localNormal += u_MorphWeights.x * a_MorphNormal0 + u_MorphWeights.y * a_MorphNormal1
+		+ u_MorphWeights.z * a_MorphNormal2 + u_MorphWeights.w * a_MorphNormal3;`,
+}.vertexShader()
+
+// MaxGPUMorphs is the number of morph targets the morph shader can blend at once
+const MaxGPUMorphs = 4
+
+// MorphMesh draws a mesh with morph targets, blending on the GPU with the
+// morph variant of Resources/vs.txt or on the CPU when the shader isn't enabled or more than
+// MaxGPUMorphs targets have a weight
+type MorphMesh struct {
+	Mesh    *Mesh
+	Texture Texture
+	Colour  uint32
+
+	deformedMesh
+	deltas     uint32 //vertex buffer of position and normal deltas, one block per target
+	weightsRef int32
+	morphPos   [MaxGPUMorphs]int //attribute locations, -1 if the shader doesn't have them
+	morphNorm  [MaxGPUMorphs]int
+}
+
+func NewMorphMesh(mesh *Mesh) *MorphMesh {
+	return &MorphMesh{Mesh: mesh, Colour: 0xffffffff}
+}
+
+// EnableGPU switches to blending on the GPU with the morph shader.
+// The base shape and all target deltas are uploaded once.
+func (mm *MorphMesh) EnableGPU() error {
+	if mm.Mesh.BaseVerts == nil {
+		return fmt.Errorf("mesh has no morph targets")
+	}
+	if err := mm.enableGPU(morphVertexShader, mm.Mesh.BaseVerts); err != nil {
+		return err
+	}
+	for i := 0; i < MaxGPUMorphs; i++ {
+		mm.morphPos[i] = mm.attribute(fmt.Sprintf("a_MorphPos%d", i))
+		mm.morphNorm[i] = mm.attribute(fmt.Sprintf("a_MorphNormal%d", i))
+	}
+	b := CurrentBackend()
+	mm.weightsRef = b.UniformLocation(mm.program.Program, "u_MorphWeights")
+
+	n := mm.Mesh.VertCount()
+	deltas := make([]float32, 0, len(mm.Mesh.MorphTargets)*n*6)
+	for _, target := range mm.Mesh.MorphTargets {
+		for v := 0; v < n; v++ {
+			d := target.Positions[v]
+			normal := Vec3{}
+			if target.Normals != nil {
+				normal = target.Normals[v]
+			}
+			deltas = append(deltas, d.X, d.Y, d.Z, normal.X, normal.Y, normal.Z)
+		}
+	}
+	if mm.deltas != 0 {
+		b.DeleteBuffer(mm.deltas)
+	}
+	var err error
+	if mm.deltas, err = b.CreateBuffer(deltas, false); err != nil {
+		mm.gpu = false
+		return err
+	}
+	return nil
+}
+
+// activeTargets returns the indexes of targets with a non-zero weight
+func (mm *MorphMesh) activeTargets() []int {
+	active := []int{}
+	for i, w := range mm.Mesh.MorphWeights {
+		if w != 0 {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+// Draw renders the mesh with its current morph weights through cam, placed in the world by model
+func (mm *MorphMesh) Draw(cam *Camera, model *Mat4s) error {
+	active := mm.activeTargets()
+	if mm.gpu && len(active) <= MaxGPUMorphs {
+		return mm.drawGPU(cam, model, active)
+	}
+	mm.Mesh.ApplyMorphs()
+	return mm.drawCPU(mm.Mesh, cam, model, mm.Colour, mm.Texture)
+}
+
+func (mm *MorphMesh) drawGPU(cam *Camera, model *Mat4s, active []int) error {
+	b := CurrentBackend()
+	mm.begin(mm.program, cam, model, mm.Colour, mm.Texture)
+	b.BindVertexBuffer(mm.source, mm.Mesh.stride(), mm.Mesh.layout().Bind(mm.program.Attributes))
+
+	//Point each slot at its target's block of deltas. Unused slots read the first target
+	//with a weight of 0.
+	n := mm.Mesh.VertCount()
+	weights := [MaxGPUMorphs]float32{}
+	attribs := make([]VertexAttrib, 0, MaxGPUMorphs*2)
+	for slot := 0; slot < MaxGPUMorphs; slot++ {
+		block := 0
+		if slot < len(active) {
+			block = active[slot] * n * 6
+			weights[slot] = mm.Mesh.MorphWeights[active[slot]]
+		}
+		if loc := mm.morphPos[slot]; loc >= 0 {
+			attribs = append(attribs, VertexAttrib{Location: uint32(loc), Size: 3, Offset: block})
+		}
+		if loc := mm.morphNorm[slot]; loc >= 0 {
+			attribs = append(attribs, VertexAttrib{Location: uint32(loc), Size: 3, Offset: block + 3})
+		}
+	}
+	b.BindVertexBuffer(mm.deltas, 6, attribs)
+	b.SetUniformFloats(mm.weightsRef, weights[:]...)
+
+	err := mm.draw(mm.Mesh)
+	mm.program.End()
+	return err
+}
+
+// Delete frees the programs and vertex buffers
+func (mm *MorphMesh) Delete() {
+	if mm.deltas != 0 {
+		CurrentBackend().DeleteBuffer(mm.deltas)
+		mm.deltas = 0
+	}
+	mm.deformedMesh.Delete()
+}
@@ -0,0 +1,156 @@
+package goengine
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+type RecordFormat int
+
+const (
+	RecordPNG RecordFormat = iota //numbered files frame00000.png, frame00001.png ... in a directory
+	RecordGIF                     //one animated GIF
+)
+
+// Recorder captures frames of a scene while it is recording. Set it as the scene's
+// Recorder and frames are captured as they are swapped to the window by Scene.Swap.
+type Recorder struct {
+	Format    RecordFormat
+	Path      string  //directory of PNG frames or the GIF file
+	Every     int     //capture every Nth frame
+	FrameRate float32 //frames per second the scene is drawn at, for the GIF frame delay, 0 is 60
+	// FixedTimestep makes App advance game time by exactly 1/FrameRate each frame while
+	// recording, so clips play at the right speed however long capturing takes
+	FixedTimestep bool
+
+	recording bool
+	frame     int
+	captured  int
+	anim      *gif.GIF
+	err       error
+}
+
+// NewRecorder records every frame at 60fps
+func NewRecorder(format RecordFormat, path string) *Recorder {
+	return &Recorder{Format: format, Path: path, Every: 1, FrameRate: 60}
+}
+
+// Start begins a new recording, replacing any frames from the last one
+func (r *Recorder) Start() error {
+	if r.Format == RecordPNG {
+		if err := os.MkdirAll(r.Path, 0o755); err != nil {
+			return err
+		}
+	}
+	r.recording, r.frame, r.captured, r.err = true, 0, 0, nil
+	r.anim = &gif.GIF{}
+	return nil
+}
+
+// Stop ends the recording, writing the GIF if recording one. It returns the first error
+// that stopped the recording early, if any.
+func (r *Recorder) Stop() error {
+	if !r.recording && r.anim == nil {
+		return r.err
+	}
+	r.recording = false
+	anim := r.anim
+	r.anim = nil
+	if r.err != nil || r.Format != RecordGIF {
+		return r.err
+	}
+	if len(anim.Image) == 0 {
+		return fmt.Errorf("no frames recorded")
+	}
+	f, err := os.Create(r.Path)
+	if err != nil {
+		return err
+	}
+	err = gif.EncodeAll(f, anim)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Frames returns the number of frames captured so far
+func (r *Recorder) Frames() int {
+	return r.captured
+}
+
+// FrameTime returns the game time per frame in fixed timestep mode, or 0 when not recording with it
+func (r *Recorder) FrameTime() float32 {
+	if !r.recording || !r.FixedTimestep {
+		return 0
+	}
+	return 1 / r.frameRate()
+}
+
+func (r *Recorder) frameRate() float32 {
+	if r.FrameRate <= 0 {
+		return 60
+	}
+	return r.FrameRate
+}
+
+// due counts a frame and returns true if it should be captured
+func (r *Recorder) due() bool {
+	if !r.recording {
+		return false
+	}
+	r.frame++
+	return (r.frame-1)%max(r.Every, 1) == 0
+}
+
+// Capture adds a frame to the recording. If it fails the recording stops.
+func (r *Recorder) Capture(img *image.RGBA) error {
+	if !r.recording {
+		return fmt.Errorf("not recording")
+	}
+	var err error
+	switch r.Format {
+	case RecordPNG:
+		err = SaveImage(img, filepath.Join(r.Path, fmt.Sprintf("frame%05d.png", r.captured)))
+	case RecordGIF:
+		frame := image.NewPaletted(img.Rect, palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, img.Rect, img, img.Rect.Min)
+		delay := 100 * float64(max(r.Every, 1)) / float64(r.frameRate()) //hundredths of a second
+		r.anim.Image = append(r.anim.Image, frame)
+		r.anim.Delay = append(r.anim.Delay, int(math.Round(delay)))
+	}
+	if err != nil {
+		r.recording, r.err = false, err
+		return err
+	}
+	r.captured++
+	return nil
+}
+
+// Swap shows the frame that has been drawn, capturing it first if a Recorder is recording.
+// Headless scenes have no window to swap, but still record.
+func (s *Scene) Swap() {
+	if s.Recorder != nil && s.Recorder.due() {
+		img, err := s.Screenshot()
+		if err == nil {
+			err = s.Recorder.Capture(img)
+		}
+		if err != nil {
+			s.Recorder.recording, s.Recorder.err = false, err
+			log.Println("recording stopped:", err)
+		}
+	}
+	if s.Window != nil {
+		s.Window.GLSwap()
+	}
+}
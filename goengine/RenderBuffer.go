@@ -1,101 +1,298 @@
 package goengine
 
 import (
-	"unsafe"
+	"errors"
+	"fmt"
+	"sort"
+)
 
-	"github.com/go-gl/gl/v2.1/gl"
+var (
+	ErrEmptyMesh       = errors.New("mesh has no vertices")
+	ErrMeshInBuffer    = errors.New("mesh is already in the render buffer")
+	ErrMeshNotInBuffer = errors.New("mesh is not in the render buffer")
 )
 
+// DefaultBufSize is the number of floats in each vertex buffer of a RenderBuffer
+const DefaultBufSize = 1 << 20
+
+// RenderBuffer packs meshes into shared vertex buffers of MaxBufSize floats, so many small
+// meshes can be drawn without a buffer each. Space is allocated first fit from each buffer's
+// free list, so removing a mesh leaves a gap that later meshes can reuse. Defragment closes
+// the gaps. A mesh larger than MaxBufSize gets a buffer to itself.
 type RenderBuffer struct {
-	BufferID      []uint32
-	Verts         [][]float32
-	VertsPtr      []int
-	MaxBufSize    uint32
-	CurrentBuffer int
+	MaxBufSize uint32 //floats per buffer, DefaultBufSize if 0
+
+	pages  []*bufferPage
+	meshes map[*Mesh]span
+}
+
+// bufferPage is one vertex buffer and a copy of its contents for defragmenting
+type bufferPage struct {
+	id    uint32
+	verts []float32
+	free  []span //sorted by offset with neighbours merged
+}
+
+// span is a range of floats in a page
+type span struct {
+	page, offset, size int
 }
 
+func (s span) end() int {
+	return s.offset + s.size
+}
+
+// Init empties the render buffer, freeing any vertex buffers
 func (b *RenderBuffer) Init() {
-	b.Verts = append(b.Verts, make([]float32, b.MaxBufSize))
-	b.VertsPtr = append(b.VertsPtr, 0)
-	b.CurrentBuffer = 0
+	b.Delete()
+}
+
+// AddMesh copies the mesh's vertices into a vertex buffer and sets the mesh's buffer, offset
+// and vertex count for drawing. The offset is always a whole number of vertices.
+// An indexed mesh also gets an index buffer of its own.
+func (b *RenderBuffer) AddMesh(mesh *Mesh) error {
+	if len(mesh.Verts) == 0 {
+		return ErrEmptyMesh
+	}
+	if _, ok := b.meshes[mesh]; ok {
+		return ErrMeshInBuffer
+	}
+	mesh.Stride = mesh.stride()
+	if err := b.uploadIndexes(mesh); err != nil {
+		return err
+	}
+
+	if b.meshes == nil {
+		b.meshes = make(map[*Mesh]span)
+	}
+	if alloc, ok := b.allocate(len(mesh.Verts), mesh.Stride); ok {
+		b.meshes[mesh] = alloc
+		b.upload(mesh, alloc)
+		return nil
+	}
+
+	//no room, so start a new buffer with the mesh at the beginning
+	size := int(b.MaxBufSize)
+	if size == 0 {
+		size = DefaultBufSize
+	}
+	size = max(size, len(mesh.Verts))
+	page := &bufferPage{verts: make([]float32, size)}
+	copy(page.verts, mesh.Verts)
+	id, err := CurrentBackend().CreateBuffer(page.verts, true)
+	if err != nil {
+		b.deleteIndexes(mesh)
+		return fmt.Errorf("create render buffer: %w", err)
+	}
+	page.id = id
+	alloc := span{page: len(b.pages), size: len(mesh.Verts)}
+	if alloc.end() < size {
+		page.free = []span{{page: alloc.page, offset: alloc.end(), size: size - alloc.end()}}
+	}
+	b.pages = append(b.pages, page)
+	b.meshes[mesh] = alloc
+	b.setMesh(mesh, alloc)
+	return nil
+}
+
+// UpdateMesh uploads a mesh's vertices and indices again after they have changed, moving
+// it if it changed size
+func (b *RenderBuffer) UpdateMesh(mesh *Mesh) error {
+	alloc, ok := b.meshes[mesh]
+	if !ok {
+		return ErrMeshNotInBuffer
+	}
+	if len(mesh.Verts) != alloc.size {
+		if err := b.RemoveMesh(mesh); err != nil {
+			return err
+		}
+		return b.AddMesh(mesh)
+	}
+	b.deleteIndexes(mesh)
+	if err := b.uploadIndexes(mesh); err != nil {
+		return err
+	}
+	b.upload(mesh, alloc)
+	return nil
+}
+
+// RemoveMesh frees the mesh's space for reuse. The mesh can't be drawn until it is added again.
+func (b *RenderBuffer) RemoveMesh(mesh *Mesh) error {
+	alloc, ok := b.meshes[mesh]
+	if !ok {
+		return ErrMeshNotInBuffer
+	}
+	delete(b.meshes, mesh)
+	b.release(alloc)
+	b.deleteIndexes(mesh)
+	mesh.vbo, mesh.VertSize = 0, 0
+	return nil
+}
 
+// Contains returns true if the mesh has been added
+func (b *RenderBuffer) Contains(mesh *Mesh) bool {
+	_, ok := b.meshes[mesh]
+	return ok
 }
 
-func (b *RenderBuffer) RemainingBuffer(bufRef int) int {
-	return len(b.Verts[bufRef]) - b.VertsPtr[bufRef]
+// Buffers returns the number of vertex buffers in use
+func (b *RenderBuffer) Buffers() int {
+	return len(b.pages)
 }
 
-func (b *RenderBuffer) AddMesh(mesh *Mesh) (int32, string) {
-	meshSize := len(mesh.Verts)
-	if meshSize > int(b.MaxBufSize) {
-		return -1, "Cant fit mesh into render buffer - too big"
+// Free returns the number of unused floats in all buffers and the largest unused span
+func (b *RenderBuffer) Free() (total, largest int) {
+	for _, page := range b.pages {
+		for _, f := range page.free {
+			total += f.size
+			largest = max(largest, f.size)
+		}
 	}
-	if meshSize == 0 {
-		return -1, "Mesh has no vertices"
+	return total, largest
+}
+
+// Defragment moves the meshes in each buffer to its start, joining the gaps left by removed
+// meshes into one free span at the end, and uploads each buffer that changed once
+func (b *RenderBuffer) Defragment() {
+	byPage := make([][]*Mesh, len(b.pages))
+	for mesh, alloc := range b.meshes {
+		byPage[alloc.page] = append(byPage[alloc.page], mesh)
 	}
+	for p, page := range b.pages {
+		if len(page.free) == 0 || (len(page.free) == 1 && page.free[0].end() == len(page.verts)) {
+			continue //already packed
+		}
+		meshes := byPage[p]
+		sort.Slice(meshes, func(i, j int) bool { return b.meshes[meshes[i]].offset < b.meshes[meshes[j]].offset })
 
-	//Search for space to fit mesh in the main Verts buffer
-	bufferWithSpace := -1
-	if b.CurrentBuffer > 0 {
-		for buf := 0; buf < len(b.Verts) && bufferWithSpace < 0; buf++ {
-			if b.RemainingBuffer(buf) > meshSize {
-				bufferWithSpace = buf
+		offset := 0
+		page.free = nil
+		for _, mesh := range meshes {
+			alloc := b.meshes[mesh]
+			if start := alignUp(offset, mesh.Stride); start > offset {
+				page.free = append(page.free, span{page: p, offset: offset, size: start - offset})
+				offset = start
 			}
+			copy(page.verts[offset:], page.verts[alloc.offset:alloc.end()])
+			alloc.offset = offset
+			b.meshes[mesh] = alloc
+			mesh.VertOffset = offset
+			offset += alloc.size
 		}
+		if offset < len(page.verts) {
+			page.free = append(page.free, span{page: p, offset: offset, size: len(page.verts) - offset})
+		}
+		CurrentBackend().UpdateBuffer(page.id, 0, page.verts[:offset])
 	}
+}
 
-	offset := 0
-	if bufferWithSpace < 0 {
-		//No buffer found - create a new one with enough space
-		b.Verts = append(b.Verts, make([]float32, b.MaxBufSize))
-		b.VertsPtr = append(b.VertsPtr, 0)
-		b.CurrentBuffer++
-		bufferWithSpace = b.CurrentBuffer
-		copy(b.Verts[bufferWithSpace], mesh.Verts)
-		gl.GenBuffers(1, &b.BufferID[b.CurrentBuffer])
-		gl.BindBuffer(gl.ARRAY_BUFFER, b.BufferID[b.CurrentBuffer])
-		gl.BufferData(gl.ARRAY_BUFFER, int(b.MaxBufSize*4), unsafe.Pointer(&b.Verts[bufferWithSpace][0]), gl.DYNAMIC_DRAW)
-	} else {
-		//Copy mesh verts into the main buffer
-		offset = b.VertsPtr[bufferWithSpace]
-		for v := 0; v < len(mesh.Verts); v++ {
-			//b.Verts[bufferWithSpace] = append(b.Verts[bufferWithSpace], mesh.Verts) //get this to work?
-			b.Verts[bufferWithSpace][offset+v] = mesh.Verts[v]
+// allocate finds the first free span that can hold size floats starting on a multiple of stride
+func (b *RenderBuffer) allocate(size, stride int) (span, bool) {
+	for _, page := range b.pages {
+		for i, f := range page.free {
+			start := alignUp(f.offset, stride)
+			if start+size > f.end() {
+				continue
+			}
+			alloc := span{page: f.page, offset: start, size: size}
+			//keep what is left either side of the allocation free
+			rest := []span{}
+			if start > f.offset {
+				rest = append(rest, span{page: f.page, offset: f.offset, size: start - f.offset})
+			}
+			if alloc.end() < f.end() {
+				rest = append(rest, span{page: f.page, offset: alloc.end(), size: f.end() - alloc.end()})
+			}
+			page.free = append(page.free[:i], append(rest, page.free[i+1:]...)...)
+			return alloc, true
 		}
-		gl.BindBuffer(gl.ARRAY_BUFFER, b.BufferID[b.CurrentBuffer])
-		gl.BufferSubData(gl.ARRAY_BUFFER, offset*4, len(mesh.Verts), unsafe.Pointer(&b.Verts[bufferWithSpace][0]))
 	}
-	b.VertsPtr[bufferWithSpace] += len(mesh.Verts)
-	mesh.BufRef = bufferWithSpace
-	mesh.VertOffset = offset
+	return span{}, false
+}
 
-	return 0, ""
+// release returns a span to its page's free list, merging it with free neighbours
+func (b *RenderBuffer) release(s span) {
+	page := b.pages[s.page]
+	i := sort.Search(len(page.free), func(i int) bool { return page.free[i].offset > s.offset })
+	page.free = append(page.free[:i], append([]span{s}, page.free[i:]...)...)
+	if i+1 < len(page.free) && page.free[i].end() == page.free[i+1].offset {
+		page.free[i].size += page.free[i+1].size
+		page.free = append(page.free[:i+1], page.free[i+2:]...)
+	}
+	if i > 0 && page.free[i-1].end() == page.free[i].offset {
+		page.free[i-1].size += page.free[i].size
+		page.free = append(page.free[:i], page.free[i+1:]...)
+	}
 }
 
-func SetRenderBuffer(bufID uint32, stride int32) {
-	gl.BindBuffer(gl.ARRAY_BUFFER, bufID)
+func (b *RenderBuffer) upload(mesh *Mesh, alloc span) {
+	page := b.pages[alloc.page]
+	copy(page.verts[alloc.offset:], mesh.Verts)
+	CurrentBackend().UpdateBuffer(page.id, alloc.offset, mesh.Verts)
+	b.setMesh(mesh, alloc)
+}
 
-	attribCount := uint32(0)
-	pos := uint32(0)
+// setMesh points the mesh at its place in the buffers for drawing
+func (b *RenderBuffer) setMesh(mesh *Mesh, alloc span) {
+	mesh.BufRef = alloc.page
+	mesh.VertOffset = alloc.offset
+	mesh.VertSize = len(mesh.Verts) / mesh.Stride
+	mesh.vbo = b.pages[alloc.page].id
+}
 
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride*4, unsafe.Pointer(&pos)) //Vertices
-	gl.EnableVertexArrayAttrib(bufID, attribCount)
-	attribCount++
-	pos += 3 * 4
+// uploadIndexes creates an index buffer for an indexed mesh, sized for its vertex count
+func (b *RenderBuffer) uploadIndexes(mesh *Mesh) error {
+	if !mesh.Indexed() {
+		return nil
+	}
+	typ := mesh.IndexType()
+	id, err := CurrentBackend().CreateIndexBuffer(mesh.Indexes, typ)
+	if err != nil {
+		return fmt.Errorf("create index buffer: %w", err)
+	}
+	mesh.ibo, mesh.iboType = id, typ
+	return nil
+}
+
+func (b *RenderBuffer) deleteIndexes(mesh *Mesh) {
+	if mesh.ibo != 0 {
+		CurrentBackend().DeleteBuffer(mesh.ibo)
+		mesh.ibo = 0
+	}
+}
 
-	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride*4, unsafe.Pointer(&pos)) //Normals
-	gl.EnableVertexArrayAttrib(bufID, attribCount)
-	attribCount++
-	pos += 3 * 4
+func alignUp(offset, stride int) int {
+	return (offset + stride - 1) / stride * stride
+}
 
-	gl.VertexAttribPointer(0, 2, gl.FLOAT, false, stride*4, unsafe.Pointer(&pos)) //UVs
-	gl.EnableVertexArrayAttrib(bufID, attribCount)
-	attribCount++
-	pos += 2 * 4
+// SetRenderBuffer binds a vertex buffer of vertices in layout (nil is PackedLayout) to the
+// shader attributes. attributes are in location order, as bound by CreateShaderProgram.
+// Attributes not in the layout are skipped.
+func SetRenderBuffer(bufID uint32, layout *VertexLayout, attributes []string) {
+	if layout == nil {
+		layout = PackedLayout
+	}
+	CurrentBackend().BindVertexBuffer(bufID, layout.Stride, layout.Bind(attributes))
+}
 
-	gl.VertexAttribPointer(0, 1, gl.FLOAT, false, stride*4, unsafe.Pointer(&pos)) //Colour
-	gl.EnableVertexArrayAttrib(bufID, attribCount)
-	attribCount++
-	pos += 1 * 4
+// ClearRenderBuffer disables the attribute arrays enabled by SetRenderBuffer, whatever the layout
+func ClearRenderBuffer(attributes []string) {
+	attribs := make([]VertexAttrib, len(attributes))
+	for i := range attribs {
+		attribs[i].Location = uint32(i)
+	}
+	CurrentBackend().UnbindVertexBuffer(attribs)
+}
 
+// Delete frees the vertex buffers. Meshes that were added must be added again to be drawn.
+func (b *RenderBuffer) Delete() {
+	for _, page := range b.pages {
+		CurrentBackend().DeleteBuffer(page.id)
+	}
+	for mesh := range b.meshes {
+		b.deleteIndexes(mesh)
+		mesh.vbo, mesh.VertSize = 0, 0
+	}
+	b.pages = nil
+	b.meshes = nil
 }
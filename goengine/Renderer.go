@@ -0,0 +1,393 @@
+package goengine
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed Resources/vs.txt
+var defaultVertexShader string
+
+//go:embed Resources/fs.txt
+var defaultFragmentShader string
+
+//go:embed Resources/vs_pixel.txt
+var pixelVertexShader string
+
+//go:embed Resources/fs_pixel.txt
+var pixelFragmentShader string
+
+// Renderer draws shapes with a shader program. Each shape's geometry is built and
+// uploaded to a vertex buffer the first time it is drawn and reused after that.
+// Static shapes are merged into a Batch per texture, colour, material and layers instead.
+type Renderer struct {
+	Program     uint32
+	Attributes  []string //shader attributes in location order
+	Buffers     RenderBuffer
+	Settings    ShaderSettings
+	LightColour uint32
+	Ambient     uint32
+	Specular    uint32
+
+	refs       []int32
+	lightRefs  [][lightFields]int32 //of each element of the shaders' u_lights array
+	lights     []*Light
+	shadows    *ShadowMap
+	shadowRefs [MaxCascades]int32 //of each element of the shaders' u_shadowMatrix array
+	depth      *shadowPass        //draws shadow casters, created on first use
+	meshes     map[*Shape]*Mesh
+	batches    map[batchKey]*Batch
+	batched    map[*Shape]batchState
+	dirty      map[batchKey]bool
+	white      Texture
+	plain      Material //reused by applyMaterial for shapes without a material
+
+	instances    map[*Instances]*instanceState
+	instanced    *Renderer //renderer for the instanced shader, created on first use
+	noInstancing bool
+}
+
+// NewRenderer creates a renderer using the bundled Resources/vs.txt and fs.txt shaders
+func NewRenderer() (*Renderer, error) {
+	return NewShaderRenderer(defaultVertexShader, defaultFragmentShader)
+}
+
+// LightingOptions choose the shaders a Renderer lights shapes with
+type LightingOptions struct {
+	MaxLights int  //lights the shaders use at once, 0 is DefaultMaxLights
+	PerPixel  bool //light each pixel rather than each vertex, so small point and spot lights look round
+}
+
+var maxLightsRe = regexp.MustCompile(`(?m)^#define MAX_LIGHTS \d+`)
+
+// NewLitRenderer creates a renderer using the bundled per-vertex or per-pixel lighting shaders
+func NewLitRenderer(opts LightingOptions) (*Renderer, error) {
+	vertexSrc, fragmentSrc := defaultVertexShader, defaultFragmentShader
+	if opts.PerPixel {
+		vertexSrc, fragmentSrc = pixelVertexShader, pixelFragmentShader
+	}
+	if opts.MaxLights > 0 {
+		define := fmt.Sprintf("#define MAX_LIGHTS %d", opts.MaxLights)
+		vertexSrc = maxLightsRe.ReplaceAllString(vertexSrc, define)
+		fragmentSrc = maxLightsRe.ReplaceAllString(fragmentSrc, define)
+	}
+	return NewShaderRenderer(vertexSrc, fragmentSrc)
+}
+
+// shaderVariant changes how vs.txt places each vertex, e.g. to skin it on the GPU.
+// Variants keep vs.txt's lighting, fog and texture transform.
+type shaderVariant struct {
+	inputs    string //uniforms and attributes declared after a_UV
+	transform string //sets model, localPosition, localNormal, diffuseColour or uvOffset in main
+}
+
+// vertexShader generates the variant of vs.txt
+func (v shaderVariant) vertexShader() string {
+	src := strings.Replace(defaultVertexShader, "//#variant inputs", v.inputs, 1)
+	return strings.Replace(src, "//#variant transform", v.transform, 1)
+}
+
+// NewShaderRenderer creates a renderer from vertex and fragment shader sources that
+// use the same attributes and uniforms as Resources/vs.txt
+func NewShaderRenderer(vertexSrc, fragmentSrc string) (*Renderer, error) {
+	b := CurrentBackend()
+	program, attributes, err := b.CreateProgram(vertexSrc, fragmentSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Renderer{
+		Program:     program,
+		Attributes:  attributes,
+		Buffers:     RenderBuffer{MaxBufSize: DefaultBufSize},
+		Settings:    ShaderSettings{fogMaxDist: 1000, lightPos: Vec3{-50, 50, 100}},
+		LightColour: 0xffffffff,
+		Ambient:     0xff808080,
+		Specular:    0xff202020,
+		meshes:      make(map[*Shape]*Mesh),
+		batches:     make(map[batchKey]*Batch),
+		batched:     make(map[*Shape]batchState),
+		dirty:       make(map[batchKey]bool),
+		instances:   make(map[*Instances]*instanceState),
+		white:       NewColourTexture(0xffffffff),
+	}
+
+	b.UseProgram(program)
+	r.refs = r.Settings.SetupShaderSettings(program)
+	r.lightRefs = lightUniformRefs(program)
+	r.shadowRefs = shadowMatrixRefs(program)
+	b.UseProgram(0)
+	return r, nil
+}
+
+// SetLight sets the world position of the shader's light, used when there are no Lights
+func (r *Renderer) SetLight(pos Vec3, col uint32) {
+	r.Settings.lightPos = pos
+	r.LightColour = col
+}
+
+// SetLights sets the lights used from the next Begin. If there are more than MaxLights
+// enabled, the directional lights and the lights nearest the camera are used.
+func (r *Renderer) SetLights(lights []*Light) {
+	r.lights = lights
+}
+
+// MaxLights returns how many lights the program uses at once. Shaders without the
+// u_lights array, such as the instanced shader, use the nearest light.
+func (r *Renderer) MaxLights() int {
+	return max(len(r.lightRefs), 1)
+}
+
+// SetFog fades shapes to col between minDist and maxDist
+func (r *Renderer) SetFog(col uint32, minDist, maxDist float32) {
+	r.Settings.fogColour, r.Settings.fogMinDist, r.Settings.fogMaxDist = col, minDist, maxDist
+}
+
+// Mesh returns the shape's uploaded mesh, building and uploading it on first use.
+// Shapes with no geometry return nil. A failed upload is tried again on the next call.
+func (r *Renderer) Mesh(shape *Shape) (*Mesh, error) {
+	if mesh, ok := r.meshes[shape]; ok {
+		return mesh, nil
+	}
+	mesh := shape.BuildMesh()
+	if len(mesh.Verts) == 0 {
+		r.meshes[shape] = nil
+		return nil, nil
+	}
+	if err := r.Buffers.AddMesh(mesh); err != nil {
+		return nil, fmt.Errorf("shape %q: %w", shape.Name, err)
+	}
+	r.meshes[shape] = mesh
+	return mesh, nil
+}
+
+// Invalidate makes the renderer rebuild a shape's mesh after its geometry has changed,
+// freeing the old mesh's space in the vertex buffers
+func (r *Renderer) Invalidate(shape *Shape) {
+	r.MarkDirty(shape)
+	if mesh := r.meshes[shape]; mesh != nil {
+		r.Buffers.RemoveMesh(mesh)
+	}
+	delete(r.meshes, shape)
+}
+
+// Begin makes the renderer's program current and sets the camera and scene uniforms
+func (r *Renderer) Begin(cam *Camera) {
+	b := CurrentBackend()
+	b.UseProgram(r.Program)
+	viewProj := cam.ProjectionMatrix().Mul(cam.ViewMatrix())
+	b.SetUniformMatrix(r.refs[perspectiveMatrixRef], viewProj)
+
+	SetFog(r.refs, r.Settings.fogMinDist, r.Settings.fogMaxDist, ColToFloats(r.Settings.fogColour))
+	b.SetUniformFloats(r.refs[eyePosRef], cam.Position.X, cam.Position.Y, cam.Position.Z)
+	r.applyShadows(r.applyLights(cam.Position))
+	r.setColour(ambientRef, r.Ambient)
+	r.setColour(specularRef, r.Specular)
+	r.setColour(emissiveRef, 0)
+	b.SetUniformInt(r.refs[illuminationModelRef], 2)
+	b.SetUniformInt(r.refs[reflectRef], 0)
+	b.SetUniformFloats(r.refs[texAnimRef], 0, 0)
+	b.SetUniformFloats(r.refs[shininessRef], 50)
+	b.SetUniformFloats(r.refs[uvTransformRef], 1, 0, 0, 1)
+}
+
+// applyLights sets the light uniforms for a camera at eye and returns the lights in the
+// u_lights array. Shaders without the array get the nearest light as their single light.
+func (r *Renderer) applyLights(eye Vec3) []*Light {
+	b := CurrentBackend()
+	for _, l := range r.lights {
+		l.update()
+	}
+	lights := nearestLights(r.lights, r.MaxLights(), eye)
+
+	pos, col := r.Settings.lightPos, r.LightColour
+	if len(r.lightRefs) == 0 && len(lights) > 0 {
+		l := lights[0]
+		pos = l.lastPos
+		if l.Type == DirectionalLight {
+			pos = eye.Sub(l.lastDir.MulScalar(1e4)) //far away against the direction
+		}
+		rad := l.radiance()
+		col = RGBAToCol(rad.X, rad.Y, rad.Z, 1)
+	}
+	b.SetUniformFloats(r.refs[lightPosRef], pos.X, pos.Y, pos.Z)
+	r.setColour(lightColRef, col)
+	if len(r.lightRefs) == 0 {
+		return nil
+	}
+
+	if len(lights) == 0 && r.refs[lightPosRef] < 0 {
+		//the per-pixel shaders have no single light, so SetLight's light is passed as a light
+		l := &Light{Type: PointLight, Position: pos, Colour: col, Enabled: true}
+		l.update()
+		lights = []*Light{l}
+	}
+	b.SetUniformInt(r.refs[lightCountRef], int32(len(lights)))
+	for i, l := range lights {
+		refs := r.lightRefs[i]
+		p, dir := l.lastPos, l.lastDir
+		if l.Type == DirectionalLight {
+			b.SetUniformFloats(refs[lightPositionField], -dir.X, -dir.Y, -dir.Z, 0)
+		} else {
+			b.SetUniformFloats(refs[lightPositionField], p.X, p.Y, p.Z, 1)
+		}
+		rad := l.radiance()
+		b.SetUniformFloats(refs[lightColourField], rad.X, rad.Y, rad.Z, 1)
+		outer, inner := l.cones()
+		b.SetUniformFloats(refs[lightDirectionField], dir.X, dir.Y, dir.Z, outer)
+		a := l.falloff()
+		b.SetUniformFloats(refs[lightFalloffField], a.X, a.Y, a.Z, inner)
+	}
+	return lights
+}
+
+// DrawShape draws one shape between Begin and End
+func (r *Renderer) DrawShape(shape *Shape) error {
+	model := shape.ModelMatrix()
+	return r.drawShapeAt(shape, &model)
+}
+
+// drawShapeAt draws a shape placed in the world by model rather than its own transform
+func (r *Renderer) drawShapeAt(shape *Shape, model *Mat4s) error {
+	mesh, err := r.Mesh(shape)
+	if mesh == nil {
+		return err
+	}
+	CurrentBackend().SetUniformMatrix(r.refs[modelMatrixRef], model)
+	r.applyMaterial(shape.Material, shape.Colour, shape.Texture)
+	r.setReceiveShadows(shape.ReceiveShadows)
+	mesh.RenderMesh(r.Attributes)
+	return nil
+}
+
+// applyMaterial sets the uniforms, textures and render state of a material between Begin
+// and End. Without a material, the renderer's lighting is used with colour col and texture tex.
+func (r *Renderer) applyMaterial(m *Material, col uint32, tex Texture) {
+	if m == nil {
+		r.plain = Material{Diffuse: col, Ambient: 0xffffffff, Specular: r.Specular, Illumination: 2}
+		r.plain.Textures[DiffuseMap] = tex
+		m = &r.plain
+	}
+	b := CurrentBackend()
+	r.setColour(diffuseRef, m.Diffuse)
+	r.setColour(ambientRef, mulColours(r.Ambient, m.Ambient))
+	r.setColour(specularRef, m.Specular)
+	r.setColour(emissiveRef, m.Emissive)
+	b.SetUniformFloats(r.refs[shininessRef], m.shininess())
+	reflective := int32(0)
+	if m.Reflective {
+		reflective = 1
+	}
+	b.SetUniformInt(r.refs[reflectRef], reflective)
+	b.SetUniformInt(r.refs[illuminationModelRef], int32(m.Illumination))
+	uv := m.uvTransform()
+	b.SetUniformFloats(r.refs[uvTransformRef], uv[:]...)
+	b.SetUniformFloats(r.refs[texAnimRef], m.UVOffset.X, -m.UVOffset.Y) //the shaders flip v
+
+	diffuse := m.Textures[DiffuseMap].id
+	if diffuse == 0 {
+		diffuse = r.white.id
+	}
+	b.BindTexture(0, diffuse)
+	for slot := NormalMap; slot < TextureSlots; slot++ {
+		if r.refs[textureSlotRefs[slot]] >= 0 {
+			b.BindTexture(int(slot), m.Textures[slot].id)
+		}
+	}
+	b.SetRenderState(m.RenderState)
+}
+
+// End unbinds the program and vertex attributes and restores the default render state
+func (r *Renderer) End() {
+	ClearRenderBuffer(r.Attributes)
+	CurrentBackend().SetRenderState(RenderState{})
+	CurrentBackend().UseProgram(0)
+}
+
+// DrawShapes draws every shape in one of the layers in mask through cam,
+// with one draw call for each batch of static shapes
+func (r *Renderer) DrawShapes(cam *Camera, shapes map[string]*Shape, mask uint32) {
+	r.updateBatches(shapes)
+	r.Begin(cam)
+	sorted := sortShapes(shapes, mask, cam.Position)
+	var transparent []*Batch
+	for _, batch := range r.batches {
+		if !inLayers(batch.Layers, mask) {
+			continue
+		}
+		if batch.transparent() {
+			transparent = append(transparent, batch)
+		} else {
+			r.drawBatch(batch)
+		}
+	}
+	first := len(sorted) //sortShapes puts the transparent shapes last
+	for i, shape := range sorted {
+		if shape.transparent() {
+			first = i
+			break
+		}
+		r.drawMoving(shape)
+	}
+	r.drawTransparent(transparent, sorted[first:], cam.Position)
+	r.End()
+}
+
+// drawTransparent draws transparent batches and shapes from the furthest to the nearest
+// to eye, merging the batches into the back to front order of shapes from sortShapes
+func (r *Renderer) drawTransparent(batches []*Batch, shapes []*Shape, eye Vec3) {
+	distance := func(p Vec3) float32 { return p.Sub(eye).Length() }
+	sort.SliceStable(batches, func(i, j int) bool {
+		return distance(batches[i].centre) > distance(batches[j].centre)
+	})
+	for len(batches) > 0 || len(shapes) > 0 {
+		if len(shapes) == 0 || len(batches) > 0 && distance(batches[0].centre) >= distance(shapes[0].Position) {
+			r.drawBatch(batches[0])
+			batches = batches[1:]
+		} else {
+			r.drawMoving(shapes[0])
+			shapes = shapes[1:]
+		}
+	}
+}
+
+// drawMoving draws a shape that isn't static, static shapes being drawn in their batch
+func (r *Renderer) drawMoving(shape *Shape) {
+	if shape.Static {
+		return
+	}
+	if err := r.DrawShape(shape); err != nil {
+		log.Println(err)
+	}
+}
+
+// Delete frees the program, vertex buffers and textures owned by the renderer
+func (r *Renderer) Delete() {
+	for in := range r.instances {
+		r.FreeInstances(in)
+	}
+	if r.instanced != nil {
+		r.instanced.Delete()
+		r.instanced = nil
+	}
+	if r.depth != nil {
+		CurrentBackend().DeleteProgram(r.depth.program)
+		r.depth = nil
+	}
+	r.Buffers.Delete()
+	r.white.Delete()
+	CurrentBackend().DeleteProgram(r.Program)
+	r.meshes = make(map[*Shape]*Mesh)
+	r.batches = make(map[batchKey]*Batch)
+	r.batched = make(map[*Shape]batchState)
+	r.dirty = make(map[batchKey]bool)
+}
+
+func (r *Renderer) setColour(ref shaderRef, col uint32) {
+	cr, cg, cb, ca := ColToRGBA(col)
+	CurrentBackend().SetUniformFloats(r.refs[ref], cr, cg, cb, ca)
+}
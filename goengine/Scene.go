@@ -1,87 +1,150 @@
 package goengine
 
 import (
+	"fmt"
 	"log"
 
-	"github.com/go-gl/gl/v2.1/gl"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
 type Scene struct {
-	Width  int32
-	Height int32
+	Width          int32 //Drawable size in pixels
+	Height         int32
+	WindowWidth    int32 //Window size in screen units (smaller than Width/Height on high-DPI displays)
+	WindowHeight   int32
+	FullscreenMode FullscreenMode
+	ClearColour    uint32
 
-	Textures map[string]uint32
-	Shapes   map[string]*Shape
+	Textures  map[string]uint32
+	Shapes    map[string]*Shape
+	Instances map[string]*Instances
+	Lights    []*Light   //replace the backend's default light when there are any
+	Shadows   *ShadowMap //makes one of the Lights cast shadows when drawing with a Renderer
 
-	Window  *sdl.Window
-	Context sdl.GLContext
+	Window   *sdl.Window
+	Context  sdl.GLContext
+	Camera   *Camera
+	Views    []*View
+	Renderer *Renderer //draws shapes with shaders and vertex buffers if set, otherwise immediate mode
+	Recorder *Recorder //captures frames in Swap while recording
+
+	lighting        LightingOptions //of the default Renderer
+	noRenderer      bool            //the default Renderer failed to build, e.g. its shaders don't compile
+	instancer       *Renderer       //draws Instances when shapes are drawn in immediate mode
+	noInstancer     bool            //the backend can't create one, e.g. it has no shaders
+	noShadows       bool            //the backend can't draw the shadow map
+	target          uint32          //drawn into instead of the window, e.g. by RenderToImage
+	resizeCallbacks []ResizeCallback
+	drawCallbacks   []DrawCallback
 }
 
-func (s *Scene) Setup(title string, w, h int32) {
+// DrawCallback is called after the scene's shapes have been drawn through cam.
+// Only objects whose layers intersect layerMask should be drawn.
+type DrawCallback func(s *Scene, cam *Camera, layerMask uint32)
+
+// Setup creates a window and OpenGL context using DefaultSceneOptions
+func (s *Scene) Setup(title string, w, h int32) error {
+	return s.SetupWithOptions(title, DefaultSceneOptions(w, h))
+}
+
+// SetupWithOptions creates a window and OpenGL context. If multisampling is requested but not
+// available it retries without it. On failure everything created so far is released.
+func (s *Scene) SetupWithOptions(title string, opts SceneOptions) error {
 
 	if err := sdl.Init(uint32(sdl.INIT_EVERYTHING)); err != nil {
-		panic(err)
+		return fmt.Errorf("sdl init: %w", err)
 	}
-	//defer sdl.Quit()
 
-	window, err := sdl.CreateWindow(title, int32(sdl.WINDOWPOS_UNDEFINED), int32(sdl.WINDOWPOS_UNDEFINED), w, h, sdl.WINDOW_OPENGL)
+	err := s.createWindow(title, opts)
+	if err != nil && opts.MSAASamples > 0 {
+		log.Printf("%v - retrying without multisampling", err)
+		opts.MSAASamples = 0
+		err = s.createWindow(title, opts)
+	}
 	if err != nil {
-		panic(err)
+		sdl.Quit()
+		return err
 	}
-	s.Window = window
 
-	sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, 2)
-	sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, 1)
-	sdl.GLSetAttribute(sdl.GL_BUFFER_SIZE, 32)
-	sdl.GLSetAttribute(sdl.GL_DEPTH_SIZE, 16)
-	sdl.GLSetAttribute(sdl.GL_ACCELERATED_VISUAL, 1)
-	sdl.GLSetSwapInterval(-1)
+	if opts.Backend != nil {
+		SetBackend(opts.Backend)
+	}
+	if err := CurrentBackend().Init(opts); err != nil {
+		s.destroyWindow()
+		sdl.Quit()
+		return err
+	}
 
-	context, err := s.Window.GLCreateContext()
-	s.Context = context
-	if err != nil {
-		panic(err)
+	if err := SetVSync(opts.VSync); err != nil {
+		log.Printf("vsync: %v", err)
 	}
-	s.Window.GLMakeCurrent(context)
 
-	gl.Init()
-	gl.Viewport(0, 0, w, h)
+	s.SetClearColour(opts.ClearColour)
+	s.lighting = opts.Lights
 
-	gl.Enable(gl.DEPTH_TEST)
-	gl.Enable(gl.LIGHTING)
-	gl.Enable(gl.CULL_FACE)
+	//Default camera matches a 90 degree frustum looking down -Z
+	s.Camera = NewCamera(90, float32(opts.Width)/float32(opts.Height), 1, 100)
+	s.Resize()
+	s.ApplyCamera(s.Camera)
 
-	gl.ClearColor(0.5, 0.5, 0.5, 0.0)
-	gl.ClearDepth(1)
-	gl.DepthFunc(gl.LEQUAL)
+	return nil
+}
 
-	ambient := []float32{0.5, 0.5, 0.5, 1}
-	diffuse := []float32{1, 1, 1, 1}
-	lightPosition := []float32{-5, 5, 10, 0}
-	gl.Lightfv(gl.LIGHT0, gl.AMBIENT, &ambient[0])
-	gl.Lightfv(gl.LIGHT0, gl.DIFFUSE, &diffuse[0])
-	gl.Lightfv(gl.LIGHT0, gl.POSITION, &lightPosition[0])
-	gl.Enable(gl.LIGHT0)
+func (s *Scene) createWindow(title string, opts SceneOptions) error {
+	opts.setGLAttributes()
 
-	gl.MatrixMode(gl.PROJECTION)
-	gl.LoadIdentity()
-	f := float64(w)/float64(h) - 1
-	gl.Frustum(-1-f, 1+f, -1, 1, 1.0, 100.0)
-	gl.MatrixMode(gl.MODELVIEW)
-	gl.LoadIdentity()
+	window, err := sdl.CreateWindow(title, int32(sdl.WINDOWPOS_UNDEFINED), int32(sdl.WINDOWPOS_UNDEFINED), opts.Width, opts.Height, opts.windowFlags())
+	if err != nil {
+		return fmt.Errorf("create window: %w", err)
+	}
+	s.Window = window
 
-	s.Width = w
-	s.Height = h
+	context, err := s.Window.GLCreateContext()
+	if err != nil {
+		s.destroyWindow()
+		return fmt.Errorf("create GL %d.%d context: %w", opts.GLMajor, opts.GLMinor, err)
+	}
+	s.Context = context
 
+	if err := s.Window.GLMakeCurrent(context); err != nil {
+		s.destroyWindow()
+		return fmt.Errorf("make context current: %w", err)
+	}
+	return nil
+}
+
+func (s *Scene) destroyWindow() {
+	if s.Context != nil {
+		sdl.GLDeleteContext(s.Context)
+		s.Context = nil
+	}
+	if s.Window != nil {
+		s.Window.Destroy()
+		s.Window = nil
+	}
+}
+
+// SetClearColour sets the colour used to clear the window when there are no views
+func (s *Scene) SetClearColour(col uint32) {
+	s.ClearColour = col
 }
 
 func (s *Scene) Quit() {
+	if s.Renderer != nil {
+		s.Renderer.Delete()
+		s.Renderer = nil
+	}
+	if s.instancer != nil {
+		s.instancer.Delete()
+		s.instancer = nil
+	}
+	if s.Shadows != nil {
+		s.Shadows.Delete()
+	}
 	for _, t := range s.Textures {
-		gl.DeleteTextures(1, &t)
+		CurrentBackend().DeleteTexture(t)
 	}
-	sdl.GLDeleteContext(s.Context)
-	s.Window.Destroy()
+	s.destroyWindow()
 	sdl.Quit()
 }
 
@@ -93,18 +156,176 @@ func (s *Scene) AddShape(name string, shape ShapeType, width, depth, height floa
 	s.Shapes[name] = &newshape
 }
 
+// AddInstances adds copies of a mesh to draw, replacing any Instances with the same name
+func (s *Scene) AddInstances(in *Instances) {
+	if s.Instances == nil {
+		s.Instances = make(map[string]*Instances)
+	}
+	if old, ok := s.Instances[in.Name]; ok && old != in {
+		s.RemoveInstances(in.Name)
+	}
+	s.Instances[in.Name] = in
+}
+
+// RemoveInstances stops drawing the named Instances and frees their buffers
+func (s *Scene) RemoveInstances(name string) {
+	in, ok := s.Instances[name]
+	if !ok {
+		return
+	}
+	for _, r := range []*Renderer{s.Renderer, s.instancer} {
+		if r != nil {
+			r.FreeInstances(in)
+		}
+	}
+	delete(s.Instances, name)
+}
+
+// SetCamera makes cam the active camera used by Draw
+func (s *Scene) SetCamera(cam *Camera) {
+	if s.Height > 0 {
+		cam.SetAspect(float32(s.Width) / float32(s.Height))
+	}
+	s.Camera = cam
+}
+
+// ApplyCamera loads the camera projection and view into the fixed-function matrix stacks
+// (if the backend has them). The Renderer takes the camera matrices as uniforms instead.
+func (s *Scene) ApplyCamera(cam *Camera) {
+	if fb, ok := CurrentBackend().(FixedFunctionBackend); ok {
+		fb.LoadMatrices(cam.ProjectionMatrix(), cam.ViewMatrix())
+	}
+}
+
+// Update moves the active camera and any view cameras using their controllers.
+// Cameras shared by several views are only moved once.
+func (s *Scene) Update(ui *UserInput, dt float32) {
+	if ui.Resized {
+		s.Resize()
+	}
+	updated := map[*Camera]bool{nil: true}
+	update := func(cam *Camera) {
+		if !updated[cam] {
+			updated[cam] = true
+			cam.Update(ui, dt)
+		}
+	}
+	update(s.Camera)
+	for _, view := range s.Views {
+		if view.Enabled {
+			update(view.Camera)
+		}
+	}
+}
+
+// Draw renders every enabled view, or the whole window through the active camera if there are no views
 func (s *Scene) Draw() {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-	for _, shape := range s.Shapes {
-		shape.Draw()
+	s.updateLights()
+	s.drawShadows()
+	if len(s.Views) > 0 {
+		for _, view := range s.Views {
+			if view.Enabled {
+				s.drawView(view)
+			}
+		}
+		CurrentBackend().Viewport(0, 0, s.Width, s.Height)
+		return
+	}
+
+	CurrentBackend().Clear(s.ClearColour, 0, 0, 0, 0)
+	mask := uint32(LayerAll)
+	if s.Camera != nil {
+		s.ApplyCamera(s.Camera)
+		mask = s.Camera.visibleLayers(LayerAll)
+	}
+	s.drawShapes(s.Camera, mask)
+	for _, fn := range s.drawCallbacks {
+		fn(s, s.Camera, mask)
 	}
 }
 
-func (s *Scene) Shape(name string) *Shape {
-	shape, ok := s.Shapes[name]
-	if ok {
-		return shape
+// renderer returns the scene's Renderer, creating a default one for backends without a
+// fixed-function pipeline. It returns nil if shapes are drawn in immediate mode or the
+// default Renderer couldn't be built, which is only tried once.
+func (s *Scene) renderer() *Renderer {
+	if _, fixed := CurrentBackend().(FixedFunctionBackend); s.Renderer == nil && !fixed && !s.noRenderer {
+		renderer, err := NewLitRenderer(s.lighting)
+		if err != nil {
+			log.Println("no renderer, shapes won't be drawn:", err)
+			s.noRenderer = true
+			return nil
+		}
+		s.Renderer = renderer
 	}
-	log.Println("Shape '" + name + "' does not exist. Ignoring")
-	return &Shape{}
+	return s.Renderer
+}
+
+// drawShapes draws with the Renderer if there is one, otherwise in immediate mode
+func (s *Scene) drawShapes(cam *Camera, mask uint32) {
+	if s.renderer() != nil {
+		if cam != nil {
+			s.Renderer.SetLights(s.Lights)
+			s.Renderer.DrawShapes(cam, s.Shapes, mask)
+			if len(s.Instances) > 0 {
+				s.Renderer.DrawInstances(cam, s.Instances, mask)
+			}
+		}
+		return
+	}
+	fb, fixed := CurrentBackend().(FixedFunctionBackend)
+	if !fixed {
+		return
+	}
+	eye := Vec3{}
+	if cam != nil {
+		eye = cam.Position
+	}
+	fb.SetLights(nearestLights(s.Lights, s.maxLights(), eye))
+	for _, shape := range sortShapes(s.Shapes, mask, eye) {
+		model := shape.ModelMatrix()
+		fb.DrawShape(shape, &model)
+	}
+	s.drawInstances(fb, cam, mask)
+}
+
+// drawInstances draws Instances in a scene without a Renderer, which needs one of its own.
+// Backends that can't run shaders draw each copy in immediate mode instead.
+func (s *Scene) drawInstances(fb FixedFunctionBackend, cam *Camera, mask uint32) {
+	if len(s.Instances) == 0 || cam == nil {
+		return
+	}
+	if s.instancer == nil && !s.noInstancer {
+		renderer, err := NewRenderer()
+		if err != nil {
+			log.Println("drawing instances one at a time:", err)
+			s.noInstancer = true
+		}
+		s.instancer = renderer
+	}
+	if s.instancer != nil {
+		s.instancer.SetLights(s.Lights)
+		s.instancer.DrawInstances(cam, s.Instances, mask)
+		return
+	}
+	for _, in := range s.Instances {
+		if !inLayers(in.Layers, mask) || in.Mesh == nil {
+			continue
+		}
+		for i := range in.Items {
+			inst := &in.Items[i]
+			model := inst.Matrix()
+			fb.DrawMesh(in.Mesh, &model, mulColours(in.Colour, inst.colour()), in.Texture)
+		}
+	}
+}
+
+// OnDraw registers a function to draw extra objects in every view, such as a World's entities
+func (s *Scene) OnDraw(fn DrawCallback) {
+	s.drawCallbacks = append(s.drawCallbacks, fn)
+}
+
+// Shape returns the named shape, or false if there is no shape with that name
+func (s *Scene) Shape(name string) (*Shape, bool) {
+	shape, ok := s.Shapes[name]
+	return shape, ok
 }
@@ -0,0 +1,120 @@
+package goengine
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type GLProfile int
+
+const (
+	GLProfileCompatibility GLProfile = iota
+	GLProfileCore
+	GLProfileES
+)
+
+type VSyncMode int
+
+const (
+	VSyncOff VSyncMode = iota
+	VSyncOn
+	VSyncAdaptive // late swaps tear instead of waiting, falls back to VSyncOn if unsupported
+)
+
+// SceneOptions configures the window and OpenGL context created by Scene.SetupWithOptions
+type SceneOptions struct {
+	Width       int32
+	Height      int32
+	GLMajor     int
+	GLMinor     int
+	GLProfile   GLProfile
+	DepthBits   int
+	StencilBits int
+	MSAASamples int // 0 disables multisampling
+	VSync       VSyncMode
+	Hidden      bool
+	Resizable   bool
+	HighDPI     bool
+	ClearColour uint32
+	Lighting    bool            // enable the legacy fixed-function light
+	Lights      LightingOptions // of the default Renderer, and how many Scene.Lights fixed-function backends use
+	Backend     Backend         // nil keeps the current backend (OpenGL 2.1 by default)
+}
+
+// DefaultSceneOptions returns the settings used by Scene.Setup
+func DefaultSceneOptions(w, h int32) SceneOptions {
+	return SceneOptions{
+		Width:       w,
+		Height:      h,
+		GLMajor:     2,
+		GLMinor:     1,
+		GLProfile:   GLProfileCompatibility,
+		DepthBits:   24,
+		StencilBits: 0,
+		MSAASamples: 0,
+		VSync:       VSyncAdaptive,
+		Hidden:      false,
+		Resizable:   true,
+		HighDPI:     true,
+		ClearColour: 0x00808080,
+		Lighting:    true,
+	}
+}
+
+func (o *SceneOptions) windowFlags() uint32 {
+	flags := uint32(sdl.WINDOW_OPENGL)
+	if o.Hidden {
+		flags |= sdl.WINDOW_HIDDEN
+	}
+	if o.Resizable {
+		flags |= sdl.WINDOW_RESIZABLE
+	}
+	if o.HighDPI {
+		flags |= sdl.WINDOW_ALLOW_HIGHDPI
+	}
+	return flags
+}
+
+// setGLAttributes must be called before the window is created
+func (o *SceneOptions) setGLAttributes() {
+	profile := sdl.GL_CONTEXT_PROFILE_COMPATIBILITY
+	es := "0"
+	switch o.GLProfile {
+	case GLProfileCore:
+		profile = sdl.GL_CONTEXT_PROFILE_CORE
+	case GLProfileES:
+		profile = sdl.GL_CONTEXT_PROFILE_ES
+		es = "1"
+	}
+	//create ES contexts through EGL and libGLESv2 rather than a desktop GL driver, and
+	//desktop contexts through the desktop driver again after an ES scene has set the hints
+	sdl.SetHint(sdl.HINT_VIDEO_X11_FORCE_EGL, es)
+	sdl.SetHint("SDL_OPENGL_ES_DRIVER", es)
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_PROFILE_MASK, profile)
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_MAJOR_VERSION, o.GLMajor)
+	sdl.GLSetAttribute(sdl.GL_CONTEXT_MINOR_VERSION, o.GLMinor)
+	sdl.GLSetAttribute(sdl.GL_BUFFER_SIZE, 32)
+	sdl.GLSetAttribute(sdl.GL_DOUBLEBUFFER, 1)
+	sdl.GLSetAttribute(sdl.GL_DEPTH_SIZE, o.DepthBits)
+	sdl.GLSetAttribute(sdl.GL_STENCIL_SIZE, o.StencilBits)
+	sdl.GLSetAttribute(sdl.GL_ACCELERATED_VISUAL, 1)
+	if o.MSAASamples > 0 {
+		sdl.GLSetAttribute(sdl.GL_MULTISAMPLEBUFFERS, 1)
+		sdl.GLSetAttribute(sdl.GL_MULTISAMPLESAMPLES, o.MSAASamples)
+	} else {
+		sdl.GLSetAttribute(sdl.GL_MULTISAMPLEBUFFERS, 0)
+		sdl.GLSetAttribute(sdl.GL_MULTISAMPLESAMPLES, 0)
+	}
+}
+
+// SetVSync sets the swap interval. Adaptive vsync falls back to normal vsync if the driver doesn't support it.
+func SetVSync(mode VSyncMode) error {
+	switch mode {
+	case VSyncOff:
+		return sdl.GLSetSwapInterval(0)
+	case VSyncAdaptive:
+		if err := sdl.GLSetSwapInterval(-1); err == nil {
+			return nil
+		}
+	}
+	return sdl.GLSetSwapInterval(1)
+}
@@ -0,0 +1,71 @@
+package goengine
+
+import (
+	"sort"
+)
+
+// AddTag adds one or more tags to the shape, ignoring any it already has
+func (s *Shape) AddTag(tags ...string) {
+	for _, tag := range tags {
+		if !s.HasTag(tag) {
+			s.Tags = append(s.Tags, tag)
+		}
+	}
+}
+
+func (s *Shape) RemoveTag(tag string) {
+	for i, t := range s.Tags {
+		if t == tag {
+			s.Tags = append(s.Tags[:i], s.Tags[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Shape) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// InLayers returns true if the shape is in any of the layers in mask.
+// Shapes with no layers are in LayerDefault.
+func (s *Shape) InLayers(mask uint32) bool {
+	return inLayers(s.Layers, mask)
+}
+
+// inLayers returns true if layers has any of the layers in mask, treating 0 as LayerDefault
+func inLayers(layers, mask uint32) bool {
+	if layers == 0 {
+		layers = LayerDefault
+	}
+	return layers&mask != 0
+}
+
+// FindShapes returns the shapes that satisfy match, sorted by name
+func (s *Scene) FindShapes(match func(shape *Shape) bool) []*Shape {
+	found := []*Shape{}
+	for _, shape := range s.Shapes {
+		if match(shape) {
+			found = append(found, shape)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found
+}
+
+func (s *Scene) ShapesWithTag(tag string) []*Shape {
+	return s.FindShapes(func(shape *Shape) bool { return shape.HasTag(tag) })
+}
+
+// ShapesInLayers returns the shapes in any of the layers in mask
+func (s *Scene) ShapesInLayers(mask uint32) []*Shape {
+	return s.FindShapes(func(shape *Shape) bool { return shape.InLayers(mask) })
+}
+
+func (s *Scene) ShapesOfType(shapeType ShapeType) []*Shape {
+	return s.FindShapes(func(shape *Shape) bool { return shape.ShapeType == shapeType })
+}
@@ -0,0 +1,106 @@
+package goengine
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func offscreenBackend() (OffscreenBackend, error) {
+	ob, ok := CurrentBackend().(OffscreenBackend)
+	if !ok {
+		return nil, fmt.Errorf("the %s backend can't read pixels", CurrentBackend().Name())
+	}
+	return ob, nil
+}
+
+// RenderToImage draws the scene into an offscreen w x h target and returns it the right way up.
+// The window isn't changed, so it can be any size, e.g. for thumbnails of models.
+func (s *Scene) RenderToImage(w, h int32) (*image.RGBA, error) {
+	ob, err := offscreenBackend()
+	if err != nil {
+		return nil, err
+	}
+	target, err := ob.CreateRenderTarget(w, h)
+	if err != nil {
+		return nil, err
+	}
+	defer ob.DeleteRenderTarget(target)
+
+	width, height := s.Width, s.Height
+	var aspect float32
+	if s.Camera != nil {
+		aspect = s.Camera.Aspect
+		s.Camera.SetAspect(float32(w) / float32(h))
+	}
+
+	ob.BindRenderTarget(target)
+	s.target = target
+	s.Width, s.Height = w, h
+	ob.Viewport(0, 0, w, h)
+	s.Draw()
+	img := ob.ReadPixels(0, 0, w, h)
+
+	ob.BindRenderTarget(0)
+	s.target = 0
+	s.Width, s.Height = width, height
+	ob.Viewport(0, 0, width, height)
+	if s.Camera != nil {
+		s.Camera.SetAspect(aspect)
+	}
+
+	flipRows(img)
+	return img, nil
+}
+
+// Screenshot reads back what has been drawn to the window since the last GLSwap.
+// Call it after Draw and before swapping, or use a hidden window for automated screenshots.
+func (s *Scene) Screenshot() (*image.RGBA, error) {
+	ob, err := offscreenBackend()
+	if err != nil {
+		return nil, err
+	}
+	img := ob.ReadPixels(0, 0, s.Width, s.Height)
+	flipRows(img)
+	return img, nil
+}
+
+// SaveImage writes img as a PNG or JPEG depending on the file extension
+func SaveImage(img image.Image, file string) error {
+	var encode func(f *os.File) error
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".png":
+		encode = func(f *os.File) error { return png.Encode(f, img) }
+	case ".jpg", ".jpeg":
+		encode = func(f *os.File) error { return jpeg.Encode(f, img, &jpeg.Options{Quality: 90}) }
+	default:
+		return fmt.Errorf("unknown image type %q", ext)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = encode(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// flipRows turns an image read from OpenGL, bottom row first, the right way up
+func flipRows(img *image.RGBA) {
+	h := img.Rect.Dy()
+	row := make([]uint8, img.Rect.Dx()*4)
+	for y := 0; y < h/2; y++ {
+		top := img.Pix[y*img.Stride : y*img.Stride+len(row)]
+		bottom := img.Pix[(h-1-y)*img.Stride : (h-1-y)*img.Stride+len(row)]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+}
@@ -1,8 +1,6 @@
 package goengine
 
-import (
-	"github.com/go-gl/gl/v2.1/gl"
-)
+import "fmt"
 
 // Note: these settings are particular to the provided vertex shader and are not generic
 
@@ -31,74 +29,149 @@ const (
 	perspectiveMatrixRef
 	modelMatrixRef
 	illuminationModelRef
+	shininessRef
+	uvTransformRef
+	normalMapRef
+	specularMapRef
+	emissiveMapRef
+	eyePosRef
+	lightCountRef
+	shadowMapRef
+	shadowCascadesRef
+	shadowParamsRef
+	shadowLightRef
+	receiveShadowsRef
 	lastRef //dont remove this and always leave it last
 )
 
+// uniformNames are the vs.txt/fs.txt uniforms in shaderRef order
+var uniformNames = [lastRef]string{
+	fogColourRef:         "u_fogColour",
+	fogRangeRef:          "u_fogRange",
+	fogMaxRef:            "u_fogMaxDist",
+	specularRef:          "u_specularColour",
+	diffuseRef:           "u_diffuseColour",
+	ambientRef:           "u_ambientColour",
+	emissiveRef:          "u_emissiveColour",
+	texAnimRef:           "u_animoffset",
+	lightPosRef:          "u_LightPos",
+	lightColRef:          "u_lightColour",
+	textureRef:           "u_Texture",
+	reflectRef:           "u_reflective",
+	perspectiveMatrixRef: "u_ProjMatrix",
+	modelMatrixRef:       "u_ModelMatrix",
+	illuminationModelRef: "u_illuminationModel",
+	shininessRef:         "u_shininess",
+	uvTransformRef:       "u_uvTransform",
+	normalMapRef:         "u_NormalMap",
+	specularMapRef:       "u_SpecularMap",
+	emissiveMapRef:       "u_EmissiveMap",
+	eyePosRef:            "u_eyePos",
+	lightCountRef:        "u_lightCount",
+	shadowMapRef:         "u_shadowMap",
+	shadowCascadesRef:    "u_shadowCascades",
+	shadowParamsRef:      "u_shadowParams",
+	shadowLightRef:       "u_shadowLight",
+	receiveShadowsRef:    "u_receiveShadows",
+}
+
+// The fields of each element of the shaders' u_lights array
+const (
+	lightPositionField = iota
+	lightColourField
+	lightDirectionField
+	lightFalloffField
+	lightFields
+)
+
+var lightFieldNames = [lightFields]string{"position", "colour", "direction", "falloff"}
+
+// lightUniformRefs looks up the fields of each element of a program's u_lights array.
+// Programs without one, such as the instanced shader, return none.
+func lightUniformRefs(program uint32) [][lightFields]int32 {
+	b := CurrentBackend()
+	refs := [][lightFields]int32{}
+	for i := 0; ; i++ {
+		light := [lightFields]int32{}
+		for field, name := range lightFieldNames {
+			light[field] = b.UniformLocation(program, fmt.Sprintf("u_lights[%d].%s", i, name))
+		}
+		if light[lightPositionField] < 0 {
+			return refs
+		}
+		refs = append(refs, light)
+	}
+}
+
+// shadowMatrixRefs looks up each element of a program's u_shadowMatrix array
+func shadowMatrixRefs(program uint32) [MaxCascades]int32 {
+	b := CurrentBackend()
+	refs := [MaxCascades]int32{}
+	for i := range refs {
+		refs[i] = b.UniformLocation(program, fmt.Sprintf("u_shadowMatrix[%d]", i))
+	}
+	return refs
+}
+
+// textureSlotRefs are the sampler uniforms of each material TextureSlot
+var textureSlotRefs = [TextureSlots]shaderRef{textureRef, normalMapRef, specularMapRef, emissiveMapRef}
+
+// shadowUnit is the texture unit of the shadow map, after the material's textures
+const shadowUnit = int(TextureSlots)
+
+// SetupShaderSettings looks up the uniform references of the current program and sets the fog,
+// light position and texture units from the settings
 func (settings *ShaderSettings) SetupShaderSettings(program uint32) []int32 {
+	b := CurrentBackend()
 	refs := make([]int32, lastRef)
-	refs[illuminationModelRef] = gl.GetUniformLocation(program, gl.Str("u_illuminationModel\x00"))
-	refs[fogColourRef] = GetSetVec3(program, gl.Str("u_fogColour\x00"), ColToFloats(settings.fogColour))
-	refs[fogRangeRef] = GetSetFloat(program, gl.Str("u_fogRange\x00"), 1/(settings.fogMaxDist-settings.fogMinDist))
-	refs[fogMaxRef] = GetSetFloat(program, gl.Str("u_fogMaxDist\x00"), settings.fogMaxDist)
-	refs[specularRef] = gl.GetUniformLocation(program, gl.Str("u_specularColour\x00"))
-	refs[diffuseRef] = gl.GetUniformLocation(program, gl.Str("u_diffuseColour\x00"))
-	refs[ambientRef] = gl.GetUniformLocation(program, gl.Str("u_ambientColour\x00"))
-	refs[emissiveRef] = gl.GetUniformLocation(program, gl.Str("u_emissiveColour\x00"))
-	refs[texAnimRef] = gl.GetUniformLocation(program, gl.Str("u_animoffset\x00"))
-	refs[lightPosRef] = GetSetVec3(program, gl.Str("u_LightPos\x00"), Vec3toFloats(&settings.lightPos))
-	refs[lightColRef] = gl.GetUniformLocation(program, gl.Str("u_lightColour\x00"))
-	refs[reflectRef] = gl.GetUniformLocation(program, gl.Str("u_reflective\x00"))
-	refs[perspectiveMatrixRef] = gl.GetUniformLocation(program, gl.Str("u_ProjMatrix\x00"))
-	refs[modelMatrixRef] = gl.GetUniformLocation(program, gl.Str("u_ModelMatrix\x00"))
-	refs[textureRef] = GetSetInt(program, gl.Str("u_Texture\x00"), 0)
+	for i, name := range uniformNames {
+		refs[i] = b.UniformLocation(program, name)
+	}
+	fog := ColToFloats(settings.fogColour)
+	b.SetUniformFloats(refs[fogColourRef], fog...)
+	b.SetUniformFloats(refs[fogRangeRef], 1/(settings.fogMaxDist-settings.fogMinDist))
+	b.SetUniformFloats(refs[fogMaxRef], settings.fogMaxDist)
+	b.SetUniformFloats(refs[lightPosRef], Vec3toFloats(&settings.lightPos)...)
+	for slot, ref := range textureSlotRefs {
+		b.SetUniformInt(refs[ref], int32(slot))
+	}
+	b.SetUniformInt(refs[shadowMapRef], int32(shadowUnit))
+	b.SetUniformInt(refs[shadowLightRef], -1)
 	return refs
 }
 
 func ActiveTexture(texID uint32, uniformId string, program uint32, texActive uint32) int32 {
-	texh := gl.GetUniformLocation(program, gl.Str(uniformId+"\x00"))
+	texh := CurrentBackend().UniformLocation(program, uniformId)
 	if texh >= 0 {
-		gl.ActiveTexture(gl.TEXTURE0 + texActive)
-		gl.BindTexture(gl.TEXTURE_2D, texID)
+		CurrentBackend().BindTexture(int(texActive), texID)
 	}
 	return texh
 }
 
 func SetTexture(uniformId string, program uint32, texLoc int32) int32 {
-	texh := gl.GetUniformLocation(program, gl.Str(uniformId+"\x00"))
+	texh := CurrentBackend().UniformLocation(program, uniformId)
 	if texh >= 0 {
-		gl.Uniform1i(texh, texLoc)
+		CurrentBackend().SetUniformInt(texh, texLoc)
 	}
 	return texh
 }
 
 func SetFog(refs []int32, minDist, maxDist float32, colour []float32) {
-	gl.Uniform3fv(refs[fogColourRef], 1, &colour[0])
-	gl.Uniform1f(refs[fogRangeRef], 1/(maxDist-minDist))
-	gl.Uniform1f(refs[fogMaxRef], maxDist)
+	b := CurrentBackend()
+	b.SetUniformFloats(refs[fogColourRef], colour[:3]...)
+	b.SetUniformFloats(refs[fogRangeRef], 1/(maxDist-minDist))
+	b.SetUniformFloats(refs[fogMaxRef], maxDist)
 }
 
-func GetSetInt(program uint32, name *uint8, v int32) int32 {
-	loc := gl.GetUniformLocation(program, name)
-	if loc >= 0 {
-		gl.Uniform1i(loc, v)
-	}
-	return loc
+// ColToRGBA splits a colour into red, green, blue and alpha floats in the range 0..1
+func ColToRGBA(col uint32) (float32, float32, float32, float32) {
+	return float32(col&255) / 255, float32((col>>8)&255) / 255, float32((col>>16)&255) / 255, float32((col>>24)&255) / 255
 }
 
-func GetSetFloat(program uint32, name *uint8, v float32) int32 {
-	loc := gl.GetUniformLocation(program, name)
-	if loc >= 0 {
-		gl.Uniform1f(loc, v)
-	}
-	return loc
-}
-
-func GetSetVec3(program uint32, name *uint8, vec []float32) int32 {
-	loc := gl.GetUniformLocation(program, name)
-	if loc >= 0 {
-		gl.Uniform3fv(loc, 1, &vec[0])
-	}
-	return loc
+// RGBAToCol packs red, green, blue and alpha (0..1) into a colour, the inverse of ColToRGBA
+func RGBAToCol(r, g, b, a float32) uint32 {
+	c := func(f float32) uint32 { return uint32(Clamp(f, 0, 1)*255 + 0.5) }
+	return c(r) | c(g)<<8 | c(b)<<16 | c(a)<<24
 }
 
 func ColToFloats(col uint32) []float32 {
@@ -0,0 +1,368 @@
+package goengine
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+
+	"github.com/chewxy/math32"
+)
+
+//go:embed Resources/vs_depth.txt
+var depthVertexShader string
+
+//go:embed Resources/fs_depth.txt
+var depthFragmentShader string
+
+// MaxCascades is the most cascades a ShadowMap can split a directional light's shadows into
+const MaxCascades = 4
+
+// ShadowMap makes one light cast shadows. Each frame the shapes that cast shadows are drawn
+// into a depth texture from the light, which the fs.txt and fs_pixel.txt shaders compare
+// against to leave shapes that receive shadows unlit.
+//
+// A directional light's shadows follow the camera and can be split into cascades: each
+// covers a slice of the camera's view further away than the last with the same number of
+// texels, so large terrains get sharp shadows close up and still have them in the distance.
+// Spot lights have a single perspective map covering their cone. Point lights don't cast shadows.
+type ShadowMap struct {
+	Light      *Light
+	Size       int32   //width and height of each cascade in texels, 0 is 1024
+	Cascades   int     //slices of a directional light's shadows, 1 to MaxCascades
+	Distance   float32 //from the camera that directional light shadows reach, 0 is the camera's far plane
+	Split      float32 //spaces the cascades from 0, evenly, to 1, closer together near the camera
+	Bias       float32 //pushes depths back to stop surfaces shadowing themselves, in 0..1 depth units
+	NormalBias float32 //looks up a surface's shadow this far off it along its normal, in world units
+	PCF        int     //blurs the shadow edges over up to 2 texels each way, 0 is hard edged
+
+	target, texture uint32
+	allocated       [2]int32 //size and cascades of the depth texture
+	matrices        [MaxCascades]Mat4s
+	count           int //cascades drawn this frame, 0 if the light can't cast shadows
+}
+
+// NewShadowMap returns a shadow map for light with a single 1024x1024 cascade and 3x3 PCF
+func NewShadowMap(light *Light) *ShadowMap {
+	return &ShadowMap{
+		Light:      light,
+		Size:       1024,
+		Cascades:   1,
+		Split:      0.75,
+		Bias:       0.002,
+		NormalBias: 0.05,
+		PCF:        1,
+	}
+}
+
+// NewCascadedShadowMap returns a directional light's shadow map split into cascades,
+// reaching distance from the camera
+func NewCascadedShadowMap(light *Light, cascades int, distance float32) *ShadowMap {
+	sm := NewShadowMap(light)
+	sm.Cascades, sm.Distance = cascades, distance
+	return sm
+}
+
+func (sm *ShadowMap) size() int32 {
+	if sm.Size <= 0 {
+		return 1024
+	}
+	return sm.Size
+}
+
+// cascades returns how many cascades the light is drawn with
+func (sm *ShadowMap) cascades() int {
+	if sm.Light.Type != DirectionalLight {
+		return 1
+	}
+	return int(Clamp(float32(sm.Cascades), 1, MaxCascades))
+}
+
+// allocate creates the depth texture, with the cascades side by side, or recreates it
+// if the size or number of cascades has changed
+func (sm *ShadowMap) allocate(sb ShadowBackend) error {
+	want := [2]int32{sm.size(), int32(sm.cascades())}
+	if sm.target != 0 && sm.allocated == want {
+		return nil
+	}
+	sm.Delete()
+	target, texture, err := sb.CreateDepthTarget(want[0]*want[1], want[0])
+	if err != nil {
+		return fmt.Errorf("shadow map: %w", err)
+	}
+	sm.target, sm.texture, sm.allocated = target, texture, want
+	return nil
+}
+
+// Delete frees the shadow map's depth texture. It is created again when next drawn.
+func (sm *ShadowMap) Delete() {
+	if sm.target == 0 {
+		return
+	}
+	if sb, ok := CurrentBackend().(ShadowBackend); ok {
+		sb.DeleteRenderTarget(sm.target)
+	}
+	sm.target, sm.texture, sm.allocated = 0, 0, [2]int32{}
+}
+
+// fit works out the light's view and projection for each cascade this frame
+func (sm *ShadowMap) fit(cam *Camera) {
+	sm.count = 0
+	l := sm.Light
+	if l == nil || !l.Enabled {
+		return
+	}
+	switch l.Type {
+	case DirectionalLight:
+		near, far := cam.Near, cam.Far
+		if sm.Distance > 0 {
+			far = math32.Min(far, sm.Distance)
+		}
+		sm.count = sm.cascades()
+		start := near
+		for c := 0; c < sm.count; c++ {
+			end := cascadeSplit(near, far, sm.Split, float32(c+1)/float32(sm.count))
+			sm.matrices[c] = sm.directionalMatrix(cam, start, end, far)
+			start = end
+		}
+	case SpotLight:
+		reach := lightReach(l.falloff())
+		if reach == 0 {
+			reach = math32.Max(sm.Distance, cam.Far)
+		}
+		outer := math32.Max(l.OuterCone, l.InnerCone)
+		view := lookAlong(l.lastPos, l.lastDir)
+		proj := Mat4s{}
+		proj.SetPerspective(Clamp(2*outer, 1, 170), 1, reach*0.01, reach)
+		sm.matrices[0].MulMatrices(&proj, &view)
+		sm.count = 1
+	}
+}
+
+// cascadeSplit returns the distance from the camera a fraction t of the way through its
+// shadows, mixing even spacing with logarithmic spacing by split
+func cascadeSplit(near, far, split, t float32) float32 {
+	even := near + (far-near)*t
+	logarithmic := near * math32.Pow(far/near, t)
+	return even + (logarithmic-even)*Clamp(split, 0, 1)
+}
+
+// directionalMatrix returns the light's view/projection covering the slice of cam's view
+// from start to end. casters is how far back from the slice shapes still cast shadows into it.
+func (sm *ShadowMap) directionalMatrix(cam *Camera, start, end, casters float32) Mat4s {
+	//a sphere around the slice's corners is the same size whichever way the camera turns,
+	//so the shadows don't shimmer as it does
+	forward := cam.Forward()
+	right := forward.Cross(cam.Up).Normal()
+	up := right.Cross(forward)
+	corners := make([]Vec3, 0, 8)
+	centre := Vec3{}
+	for _, d := range []float32{start, end} {
+		halfH := cam.OrthoHeight / 2
+		if cam.Projection == ProjectionPerspective {
+			halfH = d * math32.Tan(DegToRad(cam.Fov/2))
+		}
+		halfW := halfH * cam.Aspect
+		mid := cam.Position.Add(forward.MulScalar(d))
+		for _, xy := range [4][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+			corner := mid.Add(right.MulScalar(xy[0] * halfW)).Add(up.MulScalar(xy[1] * halfH))
+			corners = append(corners, corner)
+			centre = centre.Add(corner)
+		}
+	}
+	centre = centre.DivScalar(8)
+	radius := float32(0)
+	for _, corner := range corners {
+		radius = math32.Max(radius, corner.Sub(centre).Length())
+	}
+
+	dir := sm.Light.lastDir
+	view := lookAlong(centre.Sub(dir.MulScalar(radius+casters)), dir)
+	proj := Mat4s{}
+	proj.SetOrthographic(2*radius, 2*radius, 0, 2*radius+casters)
+	m := Mat4s{}
+	m.MulMatrices(&proj, &view)
+
+	//move the map in whole texels, so the shadows don't shimmer as the camera moves
+	half := float32(sm.size()) / 2
+	origin := Vec3{}.MulMat4(&m)
+	proj.m12 += (math32.Round(origin.X*half) - origin.X*half) / half
+	proj.m13 += (math32.Round(origin.Y*half) - origin.Y*half) / half
+	m.MulMatrices(&proj, &view)
+	return m
+}
+
+// lookAlong returns the view matrix of something at eye looking along dir
+func lookAlong(eye, dir Vec3) Mat4s {
+	up := Vec3{0, 1, 0}
+	if math32.Abs(dir.Y) > 0.99 {
+		up = Vec3{0, 0, -1}
+	}
+	world := Mat4s{}
+	world.SetIdentity()
+	world.LookAt(eye, eye.Add(dir), up)
+	world.SetPos(eye)
+	view := Mat4s{}
+	view.SetInverse(&world)
+	return view
+}
+
+// lightReach returns the distance at which attenuation leaves about 1% of a light's
+// brightness, the inverse of AttenuationForRange. Lights that don't fade return 0.
+func lightReach(a Vec3) float32 {
+	const dim = 80 //1 + 4.5 + 75, the attenuation AttenuationForRange gives at its reach
+	switch {
+	case a.Z > 0:
+		return (-a.Y + math32.Sqrt(a.Y*a.Y-4*a.Z*(a.X-dim))) / (2 * a.Z)
+	case a.Y > 0:
+		return (dim - a.X) / a.Y
+	}
+	return 0
+}
+
+// shadowPass is the program drawing the depth of shadow casters
+type shadowPass struct {
+	program     uint32
+	attributes  []string
+	viewProjRef int32
+	modelRef    int32
+}
+
+// SetShadows makes shapes drawn from the next Begin receive sm's shadows, or none if sm is nil.
+// Draw it with RenderShadows first.
+func (r *Renderer) SetShadows(sm *ShadowMap) {
+	r.shadows = sm
+}
+
+// RenderShadows draws the depth of every shape in one of the layers in mask that casts
+// shadows into sm from its light. A directional light's cascades are fitted to cam.
+// Transparent shapes and Instances don't cast shadows. Drawing is directed back to the
+// window afterwards.
+func (r *Renderer) RenderShadows(sm *ShadowMap, cam *Camera, shapes map[string]*Shape, mask uint32) error {
+	sb, ok := CurrentBackend().(ShadowBackend)
+	if !ok {
+		return fmt.Errorf("the %s backend can't draw shadow maps", CurrentBackend().Name())
+	}
+	if sm.Light != nil {
+		sm.Light.update()
+	}
+	sm.fit(cam)
+	if sm.count == 0 {
+		return nil
+	}
+	if err := sm.allocate(sb); err != nil {
+		return err
+	}
+	if r.depth == nil {
+		program, attributes, err := sb.CreateProgram(depthVertexShader, depthFragmentShader)
+		if err != nil {
+			return fmt.Errorf("shadow depth shader: %w", err)
+		}
+		r.depth = &shadowPass{
+			program:     program,
+			attributes:  attributes,
+			viewProjRef: sb.UniformLocation(program, uniformNames[perspectiveMatrixRef]),
+			modelRef:    sb.UniformLocation(program, uniformNames[modelMatrixRef]),
+		}
+	}
+	r.updateBatches(shapes)
+
+	size := sm.size()
+	sb.BindRenderTarget(sm.target)
+	sb.Viewport(0, 0, size*int32(sm.count), size)
+	sb.Clear(0xffffffff, 0, 0, 0, 0)
+	sb.UseProgram(r.depth.program)
+	sb.SetRenderState(RenderState{Cull: CullNone}) //planes and leaves cast shadows from either side
+	for c := 0; c < sm.count; c++ {
+		sb.Viewport(int32(c)*size, 0, size, size)
+		sb.SetUniformMatrix(r.depth.viewProjRef, &sm.matrices[c])
+		sb.SetUniformMatrix(r.depth.modelRef, Identity4())
+		for _, batch := range r.batches {
+			if batch.mesh != nil && batch.CastShadows && inLayers(batch.Layers, mask) && !batch.transparent() {
+				batch.mesh.RenderMesh(r.depth.attributes)
+			}
+		}
+		for _, shape := range shapes {
+			if shape.Static || !shape.CastShadows || !shape.InLayers(mask) || shape.transparent() {
+				continue
+			}
+			mesh, err := r.Mesh(shape)
+			if mesh == nil {
+				if err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			model := shape.ModelMatrix()
+			sb.SetUniformMatrix(r.depth.modelRef, &model)
+			mesh.RenderMesh(r.depth.attributes)
+		}
+	}
+	ClearRenderBuffer(r.depth.attributes)
+	sb.SetRenderState(RenderState{})
+	sb.UseProgram(0)
+	sb.BindRenderTarget(0)
+	return nil
+}
+
+// applyShadows sets the shadow uniforms between Begin and End. lights are the lights
+// uploaded to the u_lights array; the shadow map's light must be one of them.
+func (r *Renderer) applyShadows(lights []*Light) {
+	b := CurrentBackend()
+	sm := r.shadows
+	index := -1
+	if sm != nil && sm.count > 0 && sm.target != 0 {
+		for i, l := range lights {
+			if l == sm.Light {
+				index = i
+			}
+		}
+	}
+	b.SetUniformInt(r.refs[shadowLightRef], int32(index))
+	if index < 0 {
+		b.SetUniformInt(r.refs[shadowCascadesRef], 0)
+		return
+	}
+	if r.refs[shadowMapRef] >= 0 {
+		b.BindTexture(shadowUnit, sm.texture)
+	}
+	for c := 0; c < sm.count; c++ {
+		b.SetUniformMatrix(r.shadowRefs[c], &sm.matrices[c])
+	}
+	b.SetUniformInt(r.refs[shadowCascadesRef], int32(sm.count))
+	pcf := Clamp(float32(sm.PCF), 0, 2)
+	b.SetUniformFloats(r.refs[shadowParamsRef], sm.Bias, sm.NormalBias, pcf, 1/float32(sm.size()))
+}
+
+// setReceiveShadows sets whether the next shape drawn is darkened by the shadow map
+func (r *Renderer) setReceiveShadows(receive bool) {
+	v := int32(0)
+	if receive {
+		v = 1
+	}
+	CurrentBackend().SetUniformInt(r.refs[receiveShadowsRef], v)
+}
+
+// drawShadows draws the shadow map for this frame, fitted to the active camera.
+// Scenes drawn in immediate mode don't have shadows.
+func (s *Scene) drawShadows() {
+	r := s.renderer()
+	if r == nil {
+		return
+	}
+	if s.Shadows == nil || s.Shadows.Light == nil || s.Camera == nil || s.noShadows {
+		r.SetShadows(nil)
+		return
+	}
+	err := r.RenderShadows(s.Shadows, s.Camera, s.Shapes, s.Camera.visibleLayers(LayerAll))
+	if err != nil {
+		log.Println("shadows disabled:", err)
+		s.noShadows = true
+		r.SetShadows(nil)
+		return
+	}
+	r.SetShadows(s.Shadows)
+	if ob, ok := CurrentBackend().(OffscreenBackend); ok && s.target != 0 {
+		ob.BindRenderTarget(s.target)
+	}
+	CurrentBackend().Viewport(0, 0, s.Width, s.Height)
+}
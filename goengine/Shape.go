@@ -2,9 +2,9 @@ package goengine
 
 import (
 	"github.com/chewxy/math32"
-	"github.com/go-gl/gl/v2.1/gl"
 )
 
+// VERTSIZE is the number of floats in a PackedLayout vertex, the layout of Shape.Verts
 const VERTSIZE = 9
 
 type ShapeType int
@@ -25,22 +25,29 @@ const (
 )
 
 type Shape struct {
-	Name      string
-	ShapeType ShapeType
-	Position  Vec3
-	Rotation  Vec3
-	Scale     Vec3
-	Center    Vec3
-	Colour    uint32
-	Texture   Texture
-	W         float32
-	H         float32
-	D         float32
-	Edges     uint32
-	Path      []Vec2
-	Verts     []float32
-	Indexes   []int
-	Group     []Shape
+	Name           string
+	ShapeType      ShapeType
+	Position       Vec3
+	Rotation       Vec3
+	Scale          Vec3
+	Center         Vec3
+	Colour         uint32
+	Layers         uint32
+	Static         bool //rarely moves, so a Renderer batches it with shapes of the same texture and colour
+	CastShadows    bool //drawn into the scene's ShadowMap
+	ReceiveShadows bool //darkened where the ShadowMap's light is blocked
+	Tags           []string
+	Texture        Texture
+	Material       *Material //replaces Colour and Texture when set, and may be shared with other shapes
+	W              float32
+	H              float32
+	D              float32
+	Edges          uint32
+	Path           []Vec2
+	Verts          []float32 //in PackedLayout
+	Indexes        []int
+	Layout         *VertexLayout //of the meshes built by BuildMesh, nil is PackedLayout
+	Group          []Shape
 }
 
 func NewShape(name string, shape ShapeType, width, height, depth float32, position, rotation Vec3, edges, col uint32, textureFile string) Shape {
@@ -51,127 +58,158 @@ func NewShape(name string, shape ShapeType, width, height, depth float32, positi
 	}
 
 	return Shape{
-		Name:      name,
-		ShapeType: shape,
-		W:         width,
-		H:         height,
-		D:         depth,
-		Position:  position,
-		Rotation:  rotation,
-		Edges:     edges,
-		Colour:    col,
-		Texture:   tex,
-		Verts:     nil,
-		Indexes:   nil,
-		Path:      nil,
-		Group:     nil,
+		Name:           name,
+		ShapeType:      shape,
+		W:              width,
+		H:              height,
+		D:              depth,
+		Position:       position,
+		Rotation:       rotation,
+		Scale:          Vec3{1, 1, 1},
+		Edges:          edges,
+		Colour:         col,
+		Layers:         LayerDefault,
+		CastShadows:    true,
+		ReceiveShadows: true,
+		Texture:        tex,
+		Verts:          nil,
+		Indexes:        nil,
+		Path:           nil,
+		Group:          nil,
 	}
 }
 
+// Draw draws the shape where it is with the current backend's fixed-function pipeline.
+// Backends without one draw shapes with a Renderer, so it does nothing on them.
 func (s *Shape) Draw() {
-
-	gl.MatrixMode(gl.MODELVIEW)
-	gl.LoadIdentity()
-
-	gl.Translatef(s.Position.X, s.Position.Y, s.Position.Z)
-
-	if s.Rotation.X != 0 {
-		gl.Rotatef(s.Rotation.X, 1, 0, 0)
+	if fb, ok := CurrentBackend().(FixedFunctionBackend); ok {
+		model := s.ModelMatrix()
+		fb.DrawShape(s, &model)
 	}
-	if s.Rotation.Y != 0 {
-		gl.Rotatef(s.Rotation.Y, 0, 1, 0)
+}
+
+// surface returns the colour and texture the shape is drawn with, from its material if it has one
+func (s *Shape) surface() (uint32, Texture) {
+	if s.Material != nil {
+		return s.Material.Diffuse, s.Material.Textures[DiffuseMap]
 	}
-	if s.Rotation.Z != 0 {
-		gl.Rotatef(s.Rotation.Z, 0, 0, 1)
+	return s.Colour, s.Texture
+}
+
+// renderState returns the blend, cull and depth state of the shape's material
+func (s *Shape) renderState() RenderState {
+	if s.Material != nil {
+		return s.Material.RenderState
 	}
+	return RenderState{}
+}
 
-	gl.Color4f(float32(s.Colour&255)/255, float32((s.Colour>>8)&255)/255, float32((s.Colour>>16)&255)/255, float32((s.Colour>>24)&255)/255)
+func (s *Shape) transparent() bool {
+	return s.Material != nil && s.Material.Transparent()
+}
 
-	gl.BindTexture(gl.TEXTURE_2D, uint32(s.Texture.id))
+type primitive int
+
+const (
+	primNone primitive = iota
+	primIndexed
+	primQuads
+	primSharedQuads //rows of Edges+1 vertices from CreateLathe
+)
 
+// geometry creates the shape's vertices and returns them with the way they are assembled
+func (s *Shape) geometry() ([]float32, primitive) {
 	switch s.ShapeType {
 	case ShapeTriangles:
-		s.DrawTriangles()
+		return s.Verts, primIndexed
 	case ShapeCuboid:
-		DrawQuads(s.CreateCuboid())
+		return s.CreateCuboid(), primQuads
 	case ShapePlane:
-		DrawQuads(s.CreatePlane())
+		return s.CreatePlane(), primQuads
 	case ShapeSphere:
-		DrawSharedQuads(s.CreateSphere(), int(s.Edges))
+		return s.CreateSphere(), primSharedQuads
 	case ShapeCylinder:
-		DrawSharedQuads(s.CreateCylinder(), int(s.Edges))
+		return s.CreateCylinder(), primSharedQuads
 	case ShapeCone:
-		DrawSharedQuads(s.CreateCone(), int(s.Edges))
+		return s.CreateCone(), primSharedQuads
 	case ShapeTCone:
-		DrawSharedQuads(s.CreateTCone(), int(s.Edges))
+		return s.CreateTCone(), primSharedQuads
 	case ShapeTube:
-		DrawSharedQuads(s.CreateTube(), int(s.Edges))
+		return s.CreateTube(), primSharedQuads
 	case ShapeTorus:
-		DrawSharedQuads(s.CreateTorus(), int(s.Edges))
+		return s.CreateTorus(), primSharedQuads
 	case ShapeSpring:
-		DrawSharedQuads(s.CreateSpring(), int(s.Edges))
-	case ShapeLathe:
-	case ShapeExtrude:
+		return s.CreateSpring(), primSharedQuads
 	}
+	return nil, primNone
 }
 
-func (s *Shape) DrawTriangles() {
-	gl.Begin(gl.TRIANGLES)
-	for i := 0; i < len(s.Indexes); i += 3 {
-		drawVert(s.Verts, s.Indexes[i]*VERTSIZE)
-		drawVert(s.Verts, s.Indexes[i+1]*VERTSIZE)
-		drawVert(s.Verts, s.Indexes[i+2]*VERTSIZE)
+// BuildMesh returns the shape's geometry as a triangle list in the packed Mesh vertex
+// layout, in the shape's local space, for uploading to a vertex buffer.
+// ShapeTriangles meshes keep the shape's indices rather than repeating shared vertices.
+func (s *Shape) BuildMesh() *Mesh {
+	mesh := &Mesh{}
+	mesh.Init()
+	mesh.Layout = s.Layout
+	verts, prim := s.geometry()
+	add := func(i int) {
+		mesh.AddVertex(PackedLayout.Vertex(verts, i/VERTSIZE))
 	}
-	gl.End()
-}
-
-func DrawQuads(verts []float32) {
-	vstep := VERTSIZE
-	nextQuad := 4 * vstep
-	quadCount := len(verts) / nextQuad
-	i := 0
-
-	gl.Begin(gl.QUADS)
-	for q := 0; q < quadCount; q++ {
-		drawVert(verts, i)
-		drawVert(verts, i+vstep)
-		drawVert(verts, i+vstep*2)
-		drawVert(verts, i+vstep*3)
-		i += nextQuad
+	quad := func(a, b, c, d int) {
+		add(a)
+		add(b)
+		add(c)
+		add(a)
+		add(c)
+		add(d)
 	}
-	gl.End()
-}
 
-func DrawSharedQuads(verts []float32, edges int) {
 	vstep := VERTSIZE
-	nextLevel := int(edges+1) * vstep //There's an extra edge for the quads to seamlessly join
-	pathLength := len(verts) / nextLevel
-
-	gl.Begin(gl.QUADS)
-	for p := 0; p < pathLength-1; p++ {
-		i := p * nextLevel
-		for e := 0; e < edges; e++ {
-			drawVert(verts, i+vstep)
-			drawVert(verts, i)
-			drawVert(verts, i+nextLevel)
-			drawVert(verts, i+vstep+nextLevel)
-			i += vstep
+	switch prim {
+	case primIndexed:
+		for i := 0; i+vstep <= len(verts); i += vstep {
+			add(i)
+		}
+		mesh.Indexes = make([]uint32, len(s.Indexes))
+		for i, index := range s.Indexes {
+			mesh.Indexes[i] = uint32(index)
+		}
+	case primQuads:
+		for i := 0; i+4*vstep <= len(verts); i += 4 * vstep {
+			quad(i, i+vstep, i+vstep*2, i+vstep*3)
+		}
+	case primSharedQuads:
+		nextLevel := (int(s.Edges) + 1) * vstep
+		pathLength := len(verts) / nextLevel
+		for p := 0; p < pathLength-1; p++ {
+			i := p * nextLevel
+			for e := 0; e < int(s.Edges); e++ {
+				quad(i+vstep, i, i+nextLevel, i+vstep+nextLevel)
+				i += vstep
+			}
 		}
 	}
-	gl.End()
+	mesh.VC = uint32(len(mesh.Verts))
+	if mesh.layout().Has(AttribTangent) {
+		mesh.GenerateTangents()
+	}
+	return mesh
 }
 
-func drawVert(verts []float32, i int) {
-	gl.Normal3f(verts[i+4], verts[i+5], verts[i+6])
-	gl.TexCoord2f(verts[i+7], verts[i+8])
-	gl.Vertex3f(verts[i+1], verts[i+2], verts[i+3])
+// ModelMatrix returns the shape's transform (translate * rotate X,Y,Z * scale) as used by Draw
+func (s *Shape) ModelMatrix() Mat4s {
+	t := NewTransform(s.Position, s.Rotation)
+	if s.Scale != (Vec3{}) {
+		t.Scale = s.Scale
+	}
+	return t.Matrix()
 }
 
 func (c *Shape) CreatePlane() []float32 {
 	if c.Verts != nil {
 		return c.Verts
 	}
-	col := 0xffffff
+	col := uint32(0xffffffff)
 	c.Verts = []float32{}
 	c.Verts = append(c.Verts, storeVNTC2(col, Vec3{-c.W, -c.H, 0}, Vec3{0, 0, 1}, Vec2{0, 0})...)
 	c.Verts = append(c.Verts, storeVNTC2(col, Vec3{c.W, -c.H, 0}, Vec3{0, 0, 1}, Vec2{1, 0})...)
@@ -184,7 +222,7 @@ func (c *Shape) CreateCuboid() []float32 {
 	if c.Verts != nil {
 		return c.Verts
 	}
-	col := 0xffffff
+	col := uint32(0xffffffff)
 
 	c.Verts = []float32{}
 	c.Verts = append(c.Verts, storeVNTC2(col, Vec3{-c.W, -c.H, c.D}, Vec3{0, 0, 1}, Vec2{0, 0})...)
@@ -331,7 +369,7 @@ func CreateLathe(lpath []Vec2, inverted, startAngle, endAngle, rise float32, edg
 		}
 	}
 	cy := (maxy + miny) / 2
-	tc := 0
+	col := uint32(0xffffffff)
 
 	verts := []float32{}
 	for p := 0; p < len(path); p++ {
@@ -349,24 +387,25 @@ func CreateLathe(lpath []Vec2, inverted, startAngle, endAngle, rise float32, edg
 		risey := path[p].Y
 
 		for r := 0; r < int(edges); r++ {
-			verts = append(verts, storeVNTClathe(p, tc, startAngle+float32(r)*angStep, risey, Vec2{tcx * float32(r), tcy}, pos, path, normals)...)
+			verts = append(verts, storeVNTClathe(p, col, startAngle+float32(r)*angStep, risey, Vec2{tcx * float32(r), tcy}, pos, path, normals)...)
 			risey += rdiv
 		}
-		verts = append(verts, storeVNTClathe(p, tc, startAngle, risey, Vec2{0.9999, tcy}, pos, path, normals)...)
+		verts = append(verts, storeVNTClathe(p, col, startAngle, risey, Vec2{0.9999, tcy}, pos, path, normals)...)
 	}
 
 	return verts
 }
 
-func storeVNTClathe(p, col int, ang, risey float32, uv Vec2, pos Vec3, path, normals []Vec2) []float32 {
+func storeVNTClathe(p int, col uint32, ang, risey float32, uv Vec2, pos Vec3, path, normals []Vec2) []float32 {
 	sinr, cosr := math32.Sin(ang), math32.Cos(ang)
 	v := Vec3{pos.X + path[p].X*sinr, pos.Y + risey, pos.Z + path[p].X*cosr}
 	n := Vec3{normals[p].X * sinr, normals[p].Y, normals[p].X * cosr}
-	return []float32{float32(col), v.X, v.Y, v.Z, n.X, n.Y, n.Z, uv.X, uv.Y}
+	return storeVNTC2(col, v, n, uv)
 }
 
-func storeVNTC2(col int, pos, normal Vec3, uv Vec2) []float32 {
-	return []float32{float32(col), pos.X, pos.Y, pos.Z, normal.X, normal.Y, normal.Z, uv.X, uv.Y}
+// storeVNTC2 packs a vertex in PackedLayout
+func storeVNTC2(col uint32, pos, normal Vec3, uv Vec2) []float32 {
+	return PackedLayout.Append(make([]float32, 0, VERTSIZE), Vertex{Position: pos, Normal: normal, UV: uv, Colour: col})
 }
 
 func calcPathNormals(path []Vec2, creaseAngle float32, joined bool, inverted float32) ([]Vec2, []Vec2) {
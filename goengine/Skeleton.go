@@ -0,0 +1,134 @@
+package goengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxJoints is the size of the skinned shader's joint matrix array
+const MaxJoints = 48
+
+// Joint is a bone in a skeleton. Position, Rotation and Scale are the
+// current local pose relative to the parent joint.
+type Joint struct {
+	Name        string
+	Parent      int //index of the parent joint or -1 for a root
+	Position    Vec3
+	Rotation    Quat
+	Scale       Vec3
+	InverseBind Mat4s //inverse of the joint's world transform in the bind pose
+
+	world Mat4s
+}
+
+// Skeleton is a hierarchy of joints. Parents must be added before their children.
+type Skeleton struct {
+	Joints   []Joint
+	Matrices []Mat4s //skinning matrices (world * inverse bind) updated by Update
+}
+
+func NewSkeleton() *Skeleton {
+	return &Skeleton{}
+}
+
+// AddJoint adds a joint in its bind pose and returns its index
+func (sk *Skeleton) AddJoint(name string, parent int, position Vec3, rotation Quat, scale Vec3) int {
+	sk.Joints = append(sk.Joints, Joint{
+		Name:     name,
+		Parent:   parent,
+		Position: position,
+		Rotation: rotation,
+		Scale:    scale,
+	})
+	sk.Matrices = append(sk.Matrices, Mat4s{})
+	return len(sk.Joints) - 1
+}
+
+// Joint returns the index of the named joint
+func (sk *Skeleton) Joint(name string) (int, bool) {
+	for i := range sk.Joints {
+		if sk.Joints[i].Name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// SetBindPose records the current pose as the bind pose that mesh vertices are modelled in
+func (sk *Skeleton) SetBindPose() {
+	sk.updateWorld()
+	for i := range sk.Joints {
+		sk.Joints[i].InverseBind.SetInverse(&sk.Joints[i].world)
+	}
+	sk.Update()
+}
+
+func (sk *Skeleton) updateWorld() {
+	for i := range sk.Joints {
+		j := &sk.Joints[i]
+		local := Mat4s{}
+		local.SetTransform(j.Position, j.Rotation, j.Scale)
+		if j.Parent >= 0 {
+			j.world.MulMatrices(&sk.Joints[j.Parent].world, &local)
+		} else {
+			j.world = local
+		}
+	}
+}
+
+// Update recalculates the joint world transforms and skinning matrices from the current pose
+func (sk *Skeleton) Update() {
+	sk.updateWorld()
+	for i := range sk.Joints {
+		sk.Matrices[i].MulMatrices(&sk.Joints[i].world, &sk.Joints[i].InverseBind)
+	}
+}
+
+// JointWorld returns the world transform of a joint, e.g. for attaching a shape to a hand
+func (sk *Skeleton) JointWorld(index int) *Mat4s {
+	return &sk.Joints[index].world
+}
+
+// MatrixArray returns the skinning matrices in column major order for u_Joints
+func (sk *Skeleton) MatrixArray() []float32 {
+	array := make([]float32, 0, len(sk.Matrices)*16)
+	for i := range sk.Matrices {
+		array = append(array, sk.Matrices[i].ToGLArray()...)
+	}
+	return array
+}
+
+// AnimProperty returns accessors for a joint's position, rotation (quaternion) or scale
+func (sk *Skeleton) AnimProperty(joint, property string) (func() Vec4, func(Vec4), error) {
+	i, ok := sk.Joint(joint)
+	if !ok {
+		return nil, nil, fmt.Errorf("skeleton has no joint %q", joint)
+	}
+	j := &sk.Joints[i]
+	switch strings.ToLower(property) {
+	case "position":
+		return func() Vec4 { return V4FromV3(j.Position, 0) }, func(v Vec4) { j.Position.Set(v.X, v.Y, v.Z) }, nil
+	case "scale":
+		return func() Vec4 { return V4FromV3(j.Scale, 0) }, func(v Vec4) { j.Scale.Set(v.X, v.Y, v.Z) }, nil
+	case "rotation", "quaternion":
+		return func() Vec4 { return Vec4{j.Rotation.X, j.Rotation.Y, j.Rotation.Z, j.Rotation.W} },
+			func(v Vec4) { j.Rotation.Set(v.X, v.Y, v.Z, v.W) }, nil
+	}
+	return nil, nil, fmt.Errorf("joint %q has no animatable property %q", joint, property)
+}
+
+// BindSkeleton binds the clip's tracks whose target is a joint name to the skeleton.
+// Call Skeleton.Update after Animator.Update to apply the new pose.
+func (an *Animator) BindSkeleton(sk *Skeleton, clip *AnimationClip) error {
+	for _, track := range clip.Tracks {
+		if _, ok := sk.Joint(track.Target); !ok {
+			continue
+		}
+		get, set, err := sk.AnimProperty(track.Target, track.Property)
+		if err != nil {
+			return err
+		}
+		an.BindFunc(track.Target, track.Property, track.Kind, get, set)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package goengine
+
+import "fmt"
+
+// skinnedVertexShader is vs.txt with each vertex blended by up to 4 of MaxJoints joint matrices
+var skinnedVertexShader = shaderVariant{
+	inputs: fmt.Sprintf(`#define MAX_JOINTS %d
+uniform mat4 u_Joints[MAX_JOINTS]; // skinning matrices (joint world * inverse bind)
+attribute vec4 a_Joints;       // up to 4 joint indices per vertex
+attribute vec4 a_Weights;      // joint weights summing to 1`, MaxJoints),
+	transform: `model = u_ModelMatrix * (a_Weights.x * u_Joints[int(a_Joints.x)] +
+	                               a_Weights.y * u_Joints[int(a_Joints.y)] +
+	                               a_Weights.z * u_Joints[int(a_Joints.z)] +
+	                               a_Weights.w * u_Joints[int(a_Joints.w)]);`,
+}.vertexShader()
+
+// SkinnedMesh deforms a mesh built with AddSkinnedVert by a skeleton, either on the CPU
+// or on the GPU with the skinned variant of Resources/vs.txt
+type SkinnedMesh struct {
+	Mesh      *Mesh
+	Skeleton  *Skeleton
+	Texture   Texture
+	Colour    uint32
+	BindVerts []float32 //copy of the mesh vertices in the bind pose
+
+	deformedMesh
+	jointRefs []int32 //of each element of the shader's u_Joints array
+}
+
+func NewSkinnedMesh(mesh *Mesh, sk *Skeleton) *SkinnedMesh {
+	bind := make([]float32, len(mesh.Verts))
+	copy(bind, mesh.Verts)
+	return &SkinnedMesh{Mesh: mesh, Skeleton: sk, Colour: 0xffffffff, BindVerts: bind}
+}
+
+// SkinCPU writes the skinned bind pose into Mesh.Verts using the skeleton's current matrices
+func (sm *SkinnedMesh) SkinCPU() {
+	stride := SKINNEDVERTSIZE
+	joints, weights := SkinnedLayout.Offset(AttribJoints), SkinnedLayout.Offset(AttribWeights)
+	for i := 0; i+stride <= len(sm.BindVerts); i += stride {
+		v := sm.BindVerts[i : i+stride]
+		skin := Mat4s{}
+		for k := 0; k < 4; k++ {
+			w := v[weights+k]
+			if w == 0 {
+				continue
+			}
+			jm := sm.Skeleton.Matrices[int(v[joints+k])]
+			jm.MulScalar(w)
+			skin.SetAdd(&jm)
+		}
+		pos := Vec3{v[0], v[1], v[2]}.MulMat4(&skin)
+		normal := V4(v[3], v[4], v[5], 0).MulMat4(&skin)
+		n := Vec3{normal.X, normal.Y, normal.Z}.Normal()
+
+		out := sm.Mesh.Verts[i : i+stride]
+		out[0], out[1], out[2] = pos.X, pos.Y, pos.Z
+		out[3], out[4], out[5] = n.X, n.Y, n.Z
+	}
+}
+
+// EnableGPU switches to skinning on the GPU with the skinned shader.
+// The bind pose vertices are uploaded once to a vertex buffer.
+func (sm *SkinnedMesh) EnableGPU() error {
+	if len(sm.Skeleton.Joints) > MaxJoints {
+		return fmt.Errorf("skeleton has %d joints, the shader supports %d", len(sm.Skeleton.Joints), MaxJoints)
+	}
+	if err := sm.enableGPU(skinnedVertexShader, sm.BindVerts); err != nil {
+		return err
+	}
+	b := CurrentBackend()
+	sm.jointRefs = sm.jointRefs[:0]
+	for i := range sm.Skeleton.Joints {
+		sm.jointRefs = append(sm.jointRefs, b.UniformLocation(sm.program.Program, fmt.Sprintf("u_Joints[%d]", i)))
+	}
+	return nil
+}
+
+// Draw renders the mesh in its current pose through cam, placed in the world by model
+func (sm *SkinnedMesh) Draw(cam *Camera, model *Mat4s) error {
+	sm.Skeleton.Update()
+	if sm.gpu {
+		return sm.drawGPU(cam, model)
+	}
+	sm.SkinCPU()
+	return sm.drawCPU(sm.Mesh, cam, model, sm.Colour, sm.Texture)
+}
+
+func (sm *SkinnedMesh) drawGPU(cam *Camera, model *Mat4s) error {
+	b := CurrentBackend()
+	sm.begin(sm.program, cam, model, sm.Colour, sm.Texture)
+	for i, ref := range sm.jointRefs {
+		if i < len(sm.Skeleton.Matrices) {
+			b.SetUniformMatrix(ref, &sm.Skeleton.Matrices[i])
+		}
+	}
+	b.BindVertexBuffer(sm.source, SKINNEDVERTSIZE, SkinnedLayout.Bind(sm.program.Attributes))
+	err := sm.draw(sm.Mesh)
+	sm.program.End()
+	return err
+}
@@ -7,8 +7,6 @@ import (
 	_ "image/png"
 	"log"
 	"os"
-
-	"github.com/go-gl/gl/v2.1/gl"
 )
 
 type Texture struct {
@@ -16,6 +14,26 @@ type Texture struct {
 	id   uint32
 }
 
+// NewColourTexture creates a 1x1 texture of a single colour, used to draw untextured
+// shapes with shaders that always sample a texture
+func NewColourTexture(col uint32) Texture {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Pix[0], img.Pix[1], img.Pix[2], img.Pix[3] = uint8(col), uint8(col>>8), uint8(col>>16), uint8(col>>24)
+	id, err := CurrentBackend().CreateTexture(img, false)
+	if err != nil {
+		log.Println(err)
+	}
+	return Texture{id: id}
+}
+
+// Delete frees the texture
+func (t *Texture) Delete() {
+	if t.id != 0 {
+		CurrentBackend().DeleteTexture(t.id)
+		t.id = 0
+	}
+}
+
 func (t *Texture) LoadTexture(file string) {
 	imgFile, err := os.Open(file)
 	if err != nil {
@@ -32,24 +50,11 @@ func (t *Texture) LoadTexture(file string) {
 	}
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
-	var texture uint32
-	gl.Enable(gl.TEXTURE_2D)
-	gl.GenTextures(1, &texture)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
+	texture, err := CurrentBackend().CreateTexture(rgba, true)
+	if err != nil {
+		log.Printf("texture %q: %v\n", file, err)
+		return
+	}
 
 	t.file = file
 	t.id = texture
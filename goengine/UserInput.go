@@ -4,10 +4,33 @@ import (
 	"github.com/veandco/go-sdl2/sdl"
 )
 
+type Key = sdl.Keycode
+
+const (
+	KeyLeft   Key = sdl.K_LEFT
+	KeyRight  Key = sdl.K_RIGHT
+	KeyUp     Key = sdl.K_UP
+	KeyDown   Key = sdl.K_DOWN
+	KeyEscape Key = sdl.K_ESCAPE
+	KeySpace  Key = sdl.K_SPACE
+	KeyReturn Key = sdl.K_RETURN
+	KeyF9     Key = sdl.K_F9
+	KeyF11    Key = sdl.K_F11
+	KeyA      Key = sdl.K_a
+	KeyD      Key = sdl.K_d
+	KeyE      Key = sdl.K_e
+	KeyQ      Key = sdl.K_q
+	KeyS      Key = sdl.K_s
+	KeyW      Key = sdl.K_w
+)
+
 type Mouse struct {
 	X            int32
 	Y            int32
 	Z            int32
+	DX           int32 //Movement since the last GetUserInput
+	DY           int32
+	Wheel        int32
 	LeftButton   bool
 	RightButton  bool
 	MiddleButton bool
@@ -25,18 +48,54 @@ type UserInput struct {
 	Mouse     Mouse
 	Quit      bool
 	PlayerPos Vec3
+	Keys      map[Key]bool
+	Pressed   map[Key]bool //Keys pressed since the last GetUserInput
+	Resized   bool         //Window size changed since the last GetUserInput
+	Minimised bool
+}
+
+// KeyDown returns true while the key is held down
+func (ui *UserInput) KeyDown(key Key) bool {
+	return ui.Keys[key]
+}
+
+// KeyPressed returns true if the key went down since the last GetUserInput (ignoring key repeat)
+func (ui *UserInput) KeyPressed(key Key) bool {
+	return ui.Pressed[key]
 }
 
 func (ui *UserInput) GetUserInput() {
 
+	if ui.Keys == nil {
+		ui.Keys = make(map[Key]bool)
+	}
+	clear(ui.Pressed)
+	if ui.Pressed == nil {
+		ui.Pressed = make(map[Key]bool)
+	}
+	ui.Mouse.DX, ui.Mouse.DY, ui.Mouse.Wheel = 0, 0, 0
+	ui.Resized = false
+
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 		switch ev := event.(type) {
 		case *sdl.QuitEvent:
 			ui.Quit = true
+		case *sdl.WindowEvent:
+			switch ev.Event {
+			case sdl.WINDOWEVENT_SIZE_CHANGED:
+				ui.Resized = true
+			case sdl.WINDOWEVENT_MINIMIZED:
+				ui.Minimised = true
+			case sdl.WINDOWEVENT_RESTORED:
+				ui.Minimised = false
+				ui.Resized = true
+			}
 		case *sdl.MouseMotionEvent:
-			ui.Mouse.LeftButton = (ev.State == sdl.BUTTON_LEFT)
-			ui.Mouse.RightButton = (ev.State == sdl.BUTTON_RIGHT)
-			ui.Mouse.MiddleButton = (ev.State == sdl.BUTTON_MIDDLE)
+			ui.Mouse.LeftButton = (ev.State&sdl.ButtonLMask() != 0)
+			ui.Mouse.RightButton = (ev.State&sdl.ButtonRMask() != 0)
+			ui.Mouse.MiddleButton = (ev.State&sdl.ButtonMMask() != 0)
+			ui.Mouse.DX += ev.XRel
+			ui.Mouse.DY += ev.YRel
 
 			if ui.Mouse.LeftButton {
 				x, y := ui.Mouse.GetDelta(ev.X, ev.Y)
@@ -45,10 +104,27 @@ func (ui *UserInput) GetUserInput() {
 			}
 			ui.Mouse.SetCoords(ev.X, ev.Y)
 
+		case *sdl.MouseButtonEvent:
+			pressed := ev.State == sdl.PRESSED
+			switch ev.Button {
+			case sdl.BUTTON_LEFT:
+				ui.Mouse.LeftButton = pressed
+			case sdl.BUTTON_RIGHT:
+				ui.Mouse.RightButton = pressed
+			case sdl.BUTTON_MIDDLE:
+				ui.Mouse.MiddleButton = pressed
+			}
+
 		case *sdl.MouseWheelEvent:
 			ui.PlayerPos.Z += float32(ev.Y)
+			ui.Mouse.Wheel += ev.Y
+			ui.Mouse.Z += ev.Y
 		case *sdl.KeyboardEvent:
 			if ev.State == sdl.PRESSED {
+				ui.Keys[ev.Keysym.Sym] = true
+				if ev.Repeat == 0 {
+					ui.Pressed[ev.Keysym.Sym] = true
+				}
 				switch ev.Keysym.Sym {
 				case sdl.K_LEFT:
 					ui.PlayerPos.X -= 4
@@ -63,7 +139,7 @@ func (ui *UserInput) GetUserInput() {
 				}
 			}
 			if ev.State == sdl.RELEASED {
-
+				ui.Keys[ev.Keysym.Sym] = false
 			}
 		}
 	}
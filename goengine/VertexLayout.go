@@ -0,0 +1,264 @@
+package goengine
+
+import (
+	"fmt"
+	"math"
+)
+
+// AttribType is the GL type of an attribute's components in a vertex buffer
+type AttribType uint32
+
+const (
+	AttribFloat AttribType = 0x1406 //GL_FLOAT, one component per float
+	AttribUByte AttribType = 0x1401 //GL_UNSIGNED_BYTE, 4 components packed in each float
+)
+
+// Names of the vertex attributes understood by Vertex, as declared in the shaders
+const (
+	AttribPosition = "a_Position"
+	AttribNormal   = "a_Normal"
+	AttribUV       = "a_UV"
+	AttribColour   = "a_Colour"
+	AttribTangent  = "a_Tangent" //xyz and the handedness of the bitangent in w
+	AttribUV2      = "a_UV2"     //second texture coordinates, e.g. for light maps
+	AttribJoints   = "a_Joints"
+	AttribWeights  = "a_Weights"
+)
+
+// VertexAttribute is one named attribute of a vertex layout
+type VertexAttribute struct {
+	Name       string
+	Components int
+	Type       AttribType
+	Normalized bool //bytes are read by the shader as 0..1 rather than 0..255
+	Offset     int  //in floats from the start of the vertex, set by NewVertexLayout
+}
+
+// slots returns the number of floats the attribute takes up in a vertex
+func (a *VertexAttribute) slots() int {
+	if a.Type == AttribUByte {
+		return (a.Components + 3) / 4
+	}
+	return a.Components
+}
+
+// VertexLayout describes how the attributes of each vertex are packed into a mesh's floats
+type VertexLayout struct {
+	Attributes []VertexAttribute
+	Stride     int //floats per vertex
+}
+
+// NewVertexLayout packs attributes one after another in the order given
+func NewVertexLayout(attributes ...VertexAttribute) *VertexLayout {
+	l := &VertexLayout{Attributes: append([]VertexAttribute{}, attributes...)}
+	for i := range l.Attributes {
+		l.Attributes[i].Offset = l.Stride
+		l.Stride += l.Attributes[i].slots()
+	}
+	return l
+}
+
+// PackedLayout is the default mesh vertex: position, normal, uv and an RGBA byte colour
+var PackedLayout = NewVertexLayout(
+	VertexAttribute{Name: AttribPosition, Components: 3, Type: AttribFloat},
+	VertexAttribute{Name: AttribNormal, Components: 3, Type: AttribFloat},
+	VertexAttribute{Name: AttribUV, Components: 2, Type: AttribFloat},
+	VertexAttribute{Name: AttribColour, Components: 4, Type: AttribUByte, Normalized: true},
+)
+
+// SkinnedLayout is the packed vertex followed by 4 joint indices and 4 joint weights
+var SkinnedLayout = NewVertexLayout(append(append([]VertexAttribute{}, PackedLayout.Attributes...),
+	VertexAttribute{Name: AttribJoints, Components: 4, Type: AttribFloat},
+	VertexAttribute{Name: AttribWeights, Components: 4, Type: AttribFloat},
+)...)
+
+// TangentLayout is the packed vertex with tangents for normal mapping
+var TangentLayout = NewVertexLayout(append(append([]VertexAttribute{}, PackedLayout.Attributes...),
+	VertexAttribute{Name: AttribTangent, Components: 4, Type: AttribFloat},
+)...)
+
+// Attribute returns the named attribute
+func (l *VertexLayout) Attribute(name string) (VertexAttribute, bool) {
+	for _, a := range l.Attributes {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return VertexAttribute{}, false
+}
+
+// Has returns true if the layout has the named attribute
+func (l *VertexLayout) Has(name string) bool {
+	_, ok := l.Attribute(name)
+	return ok
+}
+
+// Offset returns the offset in floats of the named attribute, or -1 if there isn't one
+func (l *VertexLayout) Offset(name string) int {
+	if a, ok := l.Attribute(name); ok {
+		return a.Offset
+	}
+	return -1
+}
+
+// Bind returns the shader attributes (in location order, as returned by GetAttributes)
+// found in the layout. Shader attributes the layout doesn't have are skipped.
+func (l *VertexLayout) Bind(attributes []string) []VertexAttrib {
+	attribs := make([]VertexAttrib, 0, len(attributes))
+	for i, name := range attributes {
+		if a, ok := l.Attribute(name); ok {
+			attribs = append(attribs, VertexAttrib{Location: uint32(i), Size: a.Components, Offset: a.Offset, Type: a.Type, Normalized: a.Normalized})
+		}
+	}
+	return attribs
+}
+
+// Vertex holds every attribute a layout can have. Append only stores the ones in the layout.
+type Vertex struct {
+	Position Vec3
+	Normal   Vec3
+	UV       Vec2
+	UV2      Vec2
+	Colour   uint32 //0xAABBGGRR
+	Tangent  Vec4
+	Joints   [4]uint8
+	Weights  [4]float32
+}
+
+// Append packs v onto the end of verts
+func (l *VertexLayout) Append(verts []float32, v Vertex) []float32 {
+	for i := range l.Attributes {
+		a := &l.Attributes[i]
+		values := v.values(a.Name)
+		if a.Type == AttribUByte {
+			verts = append(verts, packBytes(values[:a.Components], a.Normalized)...)
+			continue
+		}
+		verts = append(verts, values[:a.Components]...)
+	}
+	return verts
+}
+
+// Vertex unpacks vertex number i of verts
+func (l *VertexLayout) Vertex(verts []float32, i int) Vertex {
+	v := Vertex{}
+	vert := verts[i*l.Stride : (i+1)*l.Stride]
+	for j := range l.Attributes {
+		a := &l.Attributes[j]
+		values := [4]float32{}
+		if a.Type == AttribUByte {
+			unpackBytes(values[:a.Components], vert[a.Offset:], a.Normalized)
+		} else {
+			copy(values[:a.Components], vert[a.Offset:])
+		}
+		v.set(a.Name, values)
+	}
+	return v
+}
+
+// Convert repacks verts from another layout into this one. Attributes from doesn't have
+// are zero, except colours which are white.
+func (l *VertexLayout) Convert(verts []float32, from *VertexLayout) []float32 {
+	white := !from.Has(AttribColour)
+	n := len(verts) / from.Stride
+	out := make([]float32, 0, n*l.Stride)
+	for i := 0; i < n; i++ {
+		v := from.Vertex(verts, i)
+		if white {
+			v.Colour = 0xffffffff
+		}
+		out = l.Append(out, v)
+	}
+	return out
+}
+
+// String lists the attributes, e.g. a_Position:3f a_Colour:4ub
+func (l *VertexLayout) String() string {
+	s := ""
+	for i, a := range l.Attributes {
+		if i > 0 {
+			s += " "
+		}
+		typ := "f"
+		if a.Type == AttribUByte {
+			typ = "ub"
+		}
+		s += fmt.Sprintf("%s:%d%s", a.Name, a.Components, typ)
+	}
+	return s
+}
+
+// values returns the named attribute's components, normalized bytes as 0..1
+func (v *Vertex) values(name string) [4]float32 {
+	switch name {
+	case AttribPosition:
+		return [4]float32{v.Position.X, v.Position.Y, v.Position.Z}
+	case AttribNormal:
+		return [4]float32{v.Normal.X, v.Normal.Y, v.Normal.Z}
+	case AttribUV:
+		return [4]float32{v.UV.X, v.UV.Y}
+	case AttribUV2:
+		return [4]float32{v.UV2.X, v.UV2.Y}
+	case AttribColour:
+		r, g, b, a := ColToRGBA(v.Colour)
+		return [4]float32{r, g, b, a}
+	case AttribTangent:
+		return [4]float32{v.Tangent.X, v.Tangent.Y, v.Tangent.Z, v.Tangent.W}
+	case AttribJoints:
+		return [4]float32{float32(v.Joints[0]), float32(v.Joints[1]), float32(v.Joints[2]), float32(v.Joints[3])}
+	case AttribWeights:
+		return v.Weights
+	}
+	return [4]float32{}
+}
+
+func (v *Vertex) set(name string, values [4]float32) {
+	switch name {
+	case AttribPosition:
+		v.Position = Vec3{values[0], values[1], values[2]}
+	case AttribNormal:
+		v.Normal = Vec3{values[0], values[1], values[2]}
+	case AttribUV:
+		v.UV = Vec2{values[0], values[1]}
+	case AttribUV2:
+		v.UV2 = Vec2{values[0], values[1]}
+	case AttribColour:
+		v.Colour = RGBAToCol(values[0], values[1], values[2], values[3])
+	case AttribTangent:
+		v.Tangent = Vec4{values[0], values[1], values[2], values[3]}
+	case AttribJoints:
+		for k := range v.Joints {
+			v.Joints[k] = uint8(values[k])
+		}
+	case AttribWeights:
+		v.Weights = values
+	}
+}
+
+// packBytes stores up to 4 byte components in the bits of each float, in memory order,
+// so the GPU reads them back as unsigned bytes
+func packBytes(values []float32, normalized bool) []float32 {
+	packed := make([]float32, 0, (len(values)+3)/4)
+	for i := 0; i < len(values); i += 4 {
+		bits := uint32(0)
+		for k := 0; k < 4 && i+k < len(values); k++ {
+			c := values[i+k]
+			if normalized {
+				c = c*255 + 0.5
+			}
+			bits |= uint32(Clamp(c, 0, 255)) << (8 * k)
+		}
+		packed = append(packed, math.Float32frombits(bits))
+	}
+	return packed
+}
+
+func unpackBytes(values, packed []float32, normalized bool) {
+	for i := range values {
+		c := float32((math.Float32bits(packed[i/4]) >> (8 * (i % 4))) & 255)
+		if normalized {
+			c /= 255
+		}
+		values[i] = c
+	}
+}
@@ -0,0 +1,137 @@
+package goengine
+
+import (
+	"strconv"
+)
+
+const (
+	LayerDefault uint32 = 1 << iota
+	LayerUI
+	LayerMinimap
+	LayerAll uint32 = 0xffffffff
+)
+
+// Viewport is a rectangle of the window in normalised coordinates (0..1)
+// with the origin at the bottom left, as used by OpenGL.
+type Viewport struct {
+	X float32
+	Y float32
+	W float32
+	H float32
+}
+
+var FullViewport = Viewport{0, 0, 1, 1}
+
+// Pixels converts the viewport to window pixels for a window of size w x h
+func (v Viewport) Pixels(w, h int32) (int32, int32, int32, int32) {
+	return int32(v.X * float32(w)), int32(v.Y * float32(h)), int32(v.W * float32(w)), int32(v.H * float32(h))
+}
+
+// View pairs a camera with the part of the window it renders into.
+// Only shapes with a layer in LayerMask are drawn.
+type View struct {
+	Name        string
+	Camera      *Camera
+	Viewport    Viewport
+	ClearColour uint32
+	LayerMask   uint32
+	Enabled     bool
+}
+
+// AddView adds a camera and viewport pair to the scene. Views are drawn in the order they are added.
+// A nil cam uses the scene's camera.
+func (s *Scene) AddView(name string, cam *Camera, viewport Viewport, clearColour, layerMask uint32) *View {
+	if cam == nil {
+		cam = s.Camera
+	}
+	view := &View{
+		Name:        name,
+		Camera:      cam,
+		Viewport:    viewport,
+		ClearColour: clearColour,
+		LayerMask:   layerMask,
+		Enabled:     true,
+	}
+	s.Views = append(s.Views, view)
+	return view
+}
+
+// View returns the named view or nil if it doesn't exist
+func (s *Scene) View(name string) *View {
+	for _, view := range s.Views {
+		if view.Name == name {
+			return view
+		}
+	}
+	return nil
+}
+
+func (s *Scene) RemoveView(name string) {
+	for i, view := range s.Views {
+		if view.Name == name {
+			s.Views = append(s.Views[:i], s.Views[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Scene) ClearViews() {
+	s.Views = nil
+}
+
+// SetupSplitScreen divides the window into side by side views, one per camera
+func (s *Scene) SetupSplitScreen(clearColour uint32, cams ...*Camera) {
+	s.ClearViews()
+	w := 1 / float32(len(cams))
+	for i, cam := range cams {
+		s.AddView("split"+strconv.Itoa(i+1), cam, Viewport{float32(i) * w, 0, w, 1}, clearColour, LayerAll)
+	}
+}
+
+// SetupQuadView creates CAD style top, front, side and perspective views looking at target.
+// size is the height of the orthographic views in world units.
+func (s *Scene) SetupQuadView(target Vec3, size float32, clearColour uint32) {
+	s.ClearViews()
+	dist := size * 2
+
+	top := NewCamera(45, 1, 0.1, dist*4)
+	top.SetOrthographic(size, 1, 0.1, dist*4)
+	top.Position, top.Target, top.Up = target.Add(Vec3{0, dist, 0}), target, Vec3{0, 0, -1}
+
+	front := NewCamera(45, 1, 0.1, dist*4)
+	front.SetOrthographic(size, 1, 0.1, dist*4)
+	front.Position, front.Target = target.Add(Vec3{0, 0, dist}), target
+
+	side := NewCamera(45, 1, 0.1, dist*4)
+	side.SetOrthographic(size, 1, 0.1, dist*4)
+	side.Position, side.Target = target.Add(Vec3{dist, 0, 0}), target
+
+	orbit := NewOrbitController(target, dist*1.7)
+	orbit.Yaw, orbit.Pitch = 45, 35
+	persp := NewCamera(60, 1, 0.1, dist*4)
+	persp.Position, persp.Target = target.Add(Vec3{dist, dist, dist}), target
+	persp.Controller = orbit
+
+	s.AddView("top", top, Viewport{0, 0.5, 0.5, 0.5}, clearColour, LayerAll)
+	s.AddView("perspective", persp, Viewport{0.5, 0.5, 0.5, 0.5}, clearColour, LayerAll)
+	s.AddView("front", front, Viewport{0, 0, 0.5, 0.5}, clearColour, LayerAll)
+	s.AddView("side", side, Viewport{0.5, 0, 0.5, 0.5}, clearColour, LayerAll)
+}
+
+// drawView clears the view's rectangle and draws the shapes visible to its layer mask
+func (s *Scene) drawView(view *View) {
+	x, y, w, h := view.Viewport.Pixels(s.Width, s.Height)
+	if w <= 0 || h <= 0 || view.Camera == nil {
+		return
+	}
+	CurrentBackend().Viewport(x, y, w, h)
+	CurrentBackend().Clear(view.ClearColour, x, y, w, h)
+
+	view.Camera.SetAspect(float32(w) / float32(h))
+	s.ApplyCamera(view.Camera)
+	mask := view.Camera.visibleLayers(view.LayerMask)
+	s.drawShapes(view.Camera, mask)
+	for _, fn := range s.drawCallbacks {
+		fn(s, view.Camera, mask)
+	}
+}
@@ -0,0 +1,92 @@
+package goengine
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+type FullscreenMode int
+
+const (
+	Windowed FullscreenMode = iota
+	Fullscreen
+	FullscreenBorderless // fullscreen at the desktop resolution without a mode change
+)
+
+// ResizeCallback is called after the scene has updated its viewport and camera for a new size.
+// width and height are the drawable size in pixels.
+type ResizeCallback func(s *Scene, width, height int32)
+
+// OnResize registers a function to be called whenever the window size changes
+func (s *Scene) OnResize(fn ResizeCallback) {
+	s.resizeCallbacks = append(s.resizeCallbacks, fn)
+}
+
+// Resize reads the window and drawable sizes (which differ on high-DPI displays),
+// updates the viewport and camera aspect and calls any resize callbacks
+func (s *Scene) Resize() {
+	windowWidth, windowHeight := s.Window.GetSize()
+	width, height := s.Window.GLGetDrawableSize()
+	s.setSize(windowWidth, windowHeight, width, height)
+}
+
+// setSize records the window size in screen units and the drawable size in pixels,
+// updating the viewport, camera aspect and resize callbacks unless minimised
+func (s *Scene) setSize(windowWidth, windowHeight, width, height int32) {
+	s.WindowWidth, s.WindowHeight = windowWidth, windowHeight
+	s.Width, s.Height = width, height
+	if s.Width <= 0 || s.Height <= 0 {
+		return //minimised
+	}
+
+	CurrentBackend().Viewport(0, 0, s.Width, s.Height)
+	if s.Camera != nil {
+		s.Camera.SetAspect(float32(s.Width) / float32(s.Height))
+	}
+
+	for _, fn := range s.resizeCallbacks {
+		fn(s, s.Width, s.Height)
+	}
+}
+
+// PixelScale returns the number of drawable pixels per window unit (greater than 1 on high-DPI displays)
+func (s *Scene) PixelScale() float32 {
+	if s.WindowWidth == 0 {
+		return 1
+	}
+	return float32(s.Width) / float32(s.WindowWidth)
+}
+
+// SetFullscreen switches between windowed, fullscreen and borderless fullscreen
+func (s *Scene) SetFullscreen(mode FullscreenMode) error {
+	flags := uint32(0)
+	switch mode {
+	case Fullscreen:
+		flags = sdl.WINDOW_FULLSCREEN
+	case FullscreenBorderless:
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	if err := s.Window.SetFullscreen(flags); err != nil {
+		return err
+	}
+	s.FullscreenMode = mode
+	s.Resize()
+	return nil
+}
+
+// ToggleFullscreen switches between windowed and borderless fullscreen
+func (s *Scene) ToggleFullscreen() error {
+	if s.FullscreenMode == Windowed {
+		return s.SetFullscreen(FullscreenBorderless)
+	}
+	return s.SetFullscreen(Windowed)
+}
+
+func (s *Scene) SetResizable(resizable bool) {
+	s.Window.SetResizable(resizable)
+}
+
+// SetWindowSize sets the window size in screen units
+func (s *Scene) SetWindowSize(w, h int32) {
+	s.Window.SetSize(w, h)
+	s.Resize()
+}
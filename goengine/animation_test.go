@@ -0,0 +1,117 @@
+package goengine
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func almostEqual(a, b float32) bool {
+	return math32.Abs(a-b) < 1e-4
+}
+
+func TestTrackSample(t *testing.T) {
+	track := NewVec3Track("cube1", "position", []float32{0, 1, 3}, []Vec3{{0, 0, 0}, {10, 0, 0}, {10, 20, 0}}, InterpLinear)
+
+	tests := []struct {
+		time float32
+		want Vec3
+	}{
+		{-1, Vec3{0, 0, 0}},
+		{0.5, Vec3{5, 0, 0}},
+		{2, Vec3{10, 10, 0}},
+		{5, Vec3{10, 20, 0}},
+	}
+	for _, test := range tests {
+		v := track.Sample(test.time)
+		if !almostEqual(v.X, test.want.X) || !almostEqual(v.Y, test.want.Y) || !almostEqual(v.Z, test.want.Z) {
+			t.Errorf("Sample(%v) = %v, want %v", test.time, v, test.want)
+		}
+	}
+
+	track.Interpolation = InterpStep
+	if v := track.Sample(0.9); v.X != 0 {
+		t.Errorf("step Sample(0.9) = %v, want 0", v.X)
+	}
+
+	track.Interpolation = InterpCubic
+	if v := track.Sample(1); !almostEqual(v.X, 10) {
+		t.Errorf("cubic Sample(1) = %v, want key value 10", v.X)
+	}
+}
+
+func TestQuatTrackSlerp(t *testing.T) {
+	q0 := NewQuatAxisAngle(Vec3{0, 1, 0}, 0)
+	q1 := NewQuatAxisAngle(Vec3{0, 1, 0}, math32.Pi/2)
+	track := NewQuatTrack("cube1", "quaternion", []float32{0, 1}, []Quat{q0, q1}, InterpLinear)
+
+	v := track.Sample(0.5)
+	want := NewQuatAxisAngle(Vec3{0, 1, 0}, math32.Pi/4)
+	if !almostEqual(v.Y, want.Y) || !almostEqual(v.W, want.W) {
+		t.Errorf("Sample(0.5) = %v, want %v", v, want)
+	}
+}
+
+func TestLoopTime(t *testing.T) {
+	tests := []struct {
+		time float32
+		loop LoopMode
+		want float32
+	}{
+		{2.5, LoopOnce, 2},
+		{2.5, LoopRepeat, 0.5},
+		{2.5, LoopPingPong, 1.5},
+		{4.5, LoopPingPong, 0.5},
+	}
+	for _, test := range tests {
+		if got := loopTime(test.time, 2, test.loop); !almostEqual(got, test.want) {
+			t.Errorf("loopTime(%v, %v) = %v, want %v", test.time, test.loop, got, test.want)
+		}
+	}
+}
+
+func TestEasingEnds(t *testing.T) {
+	for name, ease := range Easings {
+		if !almostEqual(ease(0), 0) || !almostEqual(ease(1), 1) {
+			t.Errorf("easing %s: f(0)=%v f(1)=%v", name, ease(0), ease(1))
+		}
+	}
+}
+
+func TestAnimatorBlend(t *testing.T) {
+	shape := &Shape{Name: "cube1", Colour: 0xff0000ff}
+	walk := NewAnimationClip("walk", NewFloatTrack("cube1", "position.x", []float32{0, 1}, []float32{0, 10}, InterpLinear))
+	run := NewAnimationClip("run", NewFloatTrack("cube1", "position.x", []float32{0, 1}, []float32{20, 20}, InterpLinear))
+	fade := NewAnimationClip("fade", NewColourTrack("cube1", "colour", []float32{0, 1}, []uint32{0xff0000ff, 0xffff0000}, InterpLinear))
+
+	animator := NewAnimator()
+	for _, clip := range []*AnimationClip{walk, run, fade} {
+		if err := animator.BindShape(shape, clip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	animator.Play(walk, LoopRepeat)
+	animator.Update(0.5)
+	if !almostEqual(shape.Position.X, 5) {
+		t.Errorf("walk at 0.5s: x = %v, want 5", shape.Position.X)
+	}
+
+	animator.Action(walk).Weight = 0.5
+	animator.Play(run, LoopRepeat).Weight = 0.5
+	animator.Update(0.25) //walk at 0.75s = 7.5, run = 20
+	if !almostEqual(shape.Position.X, 13.75) {
+		t.Errorf("blend: x = %v, want 13.75", shape.Position.X)
+	}
+
+	animator.StopAll()
+	animator.Play(fade, LoopOnce)
+	animator.Update(2)
+	if shape.Colour != 0xffff0000 {
+		t.Errorf("colour after fade = %x, want ffff0000", shape.Colour)
+	}
+
+	if _, _, err := shape.AnimProperty("size"); err == nil {
+		t.Error("expected error binding unknown property")
+	}
+}
@@ -0,0 +1,98 @@
+package goengine
+
+import "testing"
+
+func TestAppAdvance(t *testing.T) {
+	tests := []struct {
+		name      string
+		app       App
+		frames    []float32
+		updates   uint64
+		alpha     float32
+		gameTime  float32
+		updatesDt float32
+	}{
+		{"one step", App{UpdateRate: 10, TimeScale: 1}, []float32{0.1}, 1, 0, 0.1, 0.1},
+		{"leftover", App{UpdateRate: 10, TimeScale: 1}, []float32{0.25}, 2, 0.5, 0.2, 0.1},
+		{"accumulates", App{UpdateRate: 10, TimeScale: 1}, []float32{0.06, 0.06}, 1, 0.2, 0.1, 0.1},
+		{"paused", App{UpdateRate: 10, TimeScale: 1, Paused: true}, []float32{0.5}, 0, 0, 0, 0},
+		{"slow motion", App{UpdateRate: 10, TimeScale: 0.5}, []float32{0.4}, 2, 0, 0.2, 0.1},
+		{"fast forward", App{UpdateRate: 10, TimeScale: 2}, []float32{0.1}, 2, 0, 0.2, 0.1},
+		{"default rate", App{TimeScale: 1}, []float32{2.5 / 60}, 2, 0.5, 2.0 / 60, 1.0 / 60},
+	}
+	for _, test := range tests {
+		app := test.app
+		dt := float32(0)
+		app.Update = func(step float32) { dt = step }
+		alpha := float32(0)
+		for _, frame := range test.frames {
+			alpha = app.advance(frame)
+		}
+		if app.Stats.Updates != test.updates || !almostEqual(alpha, test.alpha) {
+			t.Errorf("%s: %d updates alpha %v, want %d and %v", test.name, app.Stats.Updates, alpha, test.updates, test.alpha)
+		}
+		if !almostEqual(app.Time, test.gameTime) || !almostEqual(dt, test.updatesDt) {
+			t.Errorf("%s: game time %v with steps of %v, want %v and %v", test.name, app.Time, dt, test.gameTime, test.updatesDt)
+		}
+	}
+}
+
+func TestAppPauseResume(t *testing.T) {
+	app := NewApp(nil)
+	app.advance(0.5 / 60)
+	app.TogglePause()
+	if alpha := app.advance(1); !almostEqual(alpha, 0.5) || app.Stats.Updates != 0 {
+		t.Errorf("paused: alpha %v after %d updates, want the 0.5 left before pausing", alpha, app.Stats.Updates)
+	}
+	app.SetPaused(false)
+	if app.advance(0.5 / 60); app.Stats.Updates != 1 {
+		t.Errorf("%d updates after resuming, want 1", app.Stats.Updates)
+	}
+}
+
+func TestAppWorldUpdate(t *testing.T) {
+	app := NewApp(nil)
+	app.World = NewWorld(nil)
+	runs := 0
+	app.World.AddSystem("count", 0, SystemFunc(func(w *World, dt float32) { runs++ }))
+	app.advance(3.5 / 60)
+	if runs != 3 {
+		t.Errorf("world updated %d times, want 3", runs)
+	}
+}
+
+func TestAppMaxFrameTime(t *testing.T) {
+	if got := (&App{}).maxFrameTime(); got != defaultMaxFrameTime {
+		t.Errorf("zero MaxFrameTime = %v, want the default %v", got, defaultMaxFrameTime)
+	}
+	if got := (&App{MaxFrameTime: -1}).maxFrameTime(); got != defaultMaxFrameTime {
+		t.Errorf("negative MaxFrameTime = %v, want the default %v", got, defaultMaxFrameTime)
+	}
+	if got := (&App{UpdateRate: -30}).step(); !almostEqual(got, 1.0/60) {
+		t.Errorf("negative UpdateRate steps by %v, want 1/60", got)
+	}
+}
+
+func TestFrameStats(t *testing.T) {
+	stats := FrameStats{}
+	for _, frame := range []float32{0.1, 0.2, 0.1} {
+		stats.addFrame(frame)
+	}
+	if stats.Frames != 3 || stats.FPS != 0 {
+		t.Fatalf("%d frames with FPS %v, want 3 frames and no stats before StatsInterval", stats.Frames, stats.FPS)
+	}
+
+	stats.addFrame(0.2) //0.6s, past StatsInterval
+	if !almostEqual(stats.FPS, 4/0.6) || !almostEqual(stats.FrameTime, 0.15) {
+		t.Errorf("FPS %v frame time %v, want %v and 0.15", stats.FPS, stats.FrameTime, 4/0.6)
+	}
+	if stats.MinFrameTime != 0.1 || stats.MaxFrameTime != 0.2 {
+		t.Errorf("min %v max %v, want 0.1 and 0.2", stats.MinFrameTime, stats.MaxFrameTime)
+	}
+
+	//the next interval starts afresh
+	stats.addFrame(0.6)
+	if stats.Frames != 5 || stats.MinFrameTime != 0.6 || stats.MaxFrameTime != 0.6 || !almostEqual(stats.FPS, 1/0.6) {
+		t.Errorf("second interval: min %v max %v FPS %v, want 0.6, 0.6 and %v", stats.MinFrameTime, stats.MaxFrameTime, stats.FPS, 1/0.6)
+	}
+}
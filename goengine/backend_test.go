@@ -0,0 +1,413 @@
+package goengine
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fakeDraw struct {
+	mode         DrawMode
+	buffer       uint32
+	first, count int
+	uniforms     map[int32][]float32
+	indexBuffer  uint32 //for DrawElements
+	indexType    IndexType
+	offset       int //attribute offset in floats the vertex buffer was bound with
+	state        RenderState
+	textures     map[int]uint32 //texture unit to texture
+	program      uint32
+	target       uint32 //render target drawn into, 0 for the window
+	viewport     [4]int32
+}
+
+// fakeBackend records the calls the engine makes so scene logic can be tested without a GL context
+type fakeBackend struct {
+	next     uint32
+	buffers  map[uint32][]float32
+	indexes  map[uint32][]uint32
+	textures map[uint32]*image.RGBA
+	uniforms map[int32][]float32
+	bound    uint32
+	offset   int
+	state    RenderState
+	units    map[int]uint32
+	uploads  int
+	clears   []string
+	draws    []fakeDraw
+	lightMax map[uint32]int //size of each program's u_lights array
+	program  uint32
+	target   uint32
+	targets  map[uint32][2]int32 //size of each render target
+	viewport [4]int32
+
+	programs                int   //CreateProgram calls
+	failProgram, failBuffer error //returned by CreateProgram and CreateBuffer when set
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		buffers:  make(map[uint32][]float32),
+		indexes:  make(map[uint32][]uint32),
+		textures: make(map[uint32]*image.RGBA),
+		uniforms: make(map[int32][]float32),
+		units:    make(map[int]uint32),
+		lightMax: make(map[uint32]int),
+		targets:  make(map[uint32][2]int32),
+	}
+}
+
+func (f *fakeBackend) handle() uint32 {
+	f.next++
+	return f.next
+}
+
+func (f *fakeBackend) Name() string                        { return "fake" }
+func (f *fakeBackend) Init(opts SceneOptions) error        { return nil }
+func (f *fakeBackend) Viewport(x, y, w, h int32)           { f.viewport = [4]int32{x, y, w, h} }
+func (f *fakeBackend) UseProgram(program uint32)           { f.program = program }
+func (f *fakeBackend) DeleteProgram(program uint32)        {}
+func (f *fakeBackend) BindTexture(unit int, tex uint32)    { f.units[unit] = tex }
+func (f *fakeBackend) DeleteTexture(tex uint32)            { delete(f.textures, tex) }
+func (f *fakeBackend) UnbindVertexBuffer(a []VertexAttrib) { f.bound = 0 }
+func (f *fakeBackend) SetRenderState(state RenderState)    { f.state = state }
+
+func (f *fakeBackend) Clear(col uint32, x, y, w, h int32) {
+	f.clears = append(f.clears, fmt.Sprintf("%08x %d,%d,%d,%d", col, x, y, w, h))
+}
+
+func (f *fakeBackend) CreateBuffer(data []float32, dynamic bool) (uint32, error) {
+	if f.failBuffer != nil {
+		return 0, f.failBuffer
+	}
+	buf := f.handle()
+	f.buffers[buf] = append([]float32{}, data...)
+	f.uploads++
+	return buf, nil
+}
+
+func (f *fakeBackend) UpdateBuffer(buf uint32, offset int, data []float32) {
+	copy(f.buffers[buf][offset:], data)
+	f.uploads++
+}
+
+func (f *fakeBackend) DeleteBuffer(buf uint32) {
+	delete(f.buffers, buf)
+	delete(f.indexes, buf)
+}
+
+func (f *fakeBackend) CreateIndexBuffer(indices []uint32, typ IndexType) (uint32, error) {
+	buf := f.handle()
+	f.indexes[buf] = append([]uint32{}, indices...)
+	f.uploads++
+	return buf, nil
+}
+
+func (f *fakeBackend) CreateTexture(img *image.RGBA, smooth bool) (uint32, error) {
+	tex := f.handle()
+	f.textures[tex] = img
+	return tex, nil
+}
+
+func (f *fakeBackend) CreateRenderTarget(w, h int32) (uint32, error) {
+	target := f.handle()
+	f.targets[target] = [2]int32{w, h}
+	return target, nil
+}
+
+func (f *fakeBackend) CreateDepthTarget(w, h int32) (uint32, uint32, error) {
+	target, _ := f.CreateRenderTarget(w, h)
+	return target, f.handle(), nil
+}
+
+func (f *fakeBackend) BindRenderTarget(target uint32)   { f.target = target }
+func (f *fakeBackend) DeleteRenderTarget(target uint32) { delete(f.targets, target) }
+
+func (f *fakeBackend) ReadPixels(x, y, w, h int32) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, int(w), int(h)))
+}
+
+func (f *fakeBackend) CreateProgram(vertexSrc, fragmentSrc string) (uint32, []string, error) {
+	f.programs++
+	if f.failProgram != nil {
+		return 0, nil, f.failProgram
+	}
+	attributes, err := GetAttributes(vertexSrc)
+	if err != "" {
+		return 0, nil, fmt.Errorf("%s", err)
+	}
+	program := f.handle()
+	if m := fakeMaxLightsRe.FindStringSubmatch(vertexSrc + fragmentSrc); m != nil && strings.Contains(vertexSrc+fragmentSrc, "u_lights[") {
+		f.lightMax[program], _ = strconv.Atoi(m[1])
+	}
+	return program, attributes, nil
+}
+
+var fakeMaxLightsRe = regexp.MustCompile(`#define MAX_LIGHTS (\d+)`)
+
+// fakeLightRef is the location of a field of an element of the u_lights array
+func fakeLightRef(i, field int) int32 {
+	return int32(1000 + i*lightFields + field)
+}
+
+// fakeShadowMatrixRef is the location of an element of the u_shadowMatrix array
+func fakeShadowMatrixRef(i int) int32 {
+	return int32(2000 + i)
+}
+
+// fakeJointRef is the location of an element of the u_Joints array
+func fakeJointRef(i int) int32 {
+	return int32(3000 + i)
+}
+
+// fakeMorphWeightsRef is the location of the morph shader's u_MorphWeights
+const fakeMorphWeightsRef = 4000
+
+func (f *fakeBackend) UniformLocation(program uint32, name string) int32 {
+	for i, n := range uniformNames {
+		if n == name {
+			return int32(i)
+		}
+	}
+	if name == "u_MorphWeights" {
+		return fakeMorphWeightsRef
+	}
+	i, field := 0, ""
+	if _, err := fmt.Sscanf(name, "u_shadowMatrix[%d]", &i); err == nil && i < MaxCascades {
+		return fakeShadowMatrixRef(i)
+	}
+	if _, err := fmt.Sscanf(name, "u_Joints[%d]", &i); err == nil && i < MaxJoints {
+		return fakeJointRef(i)
+	}
+	if _, err := fmt.Sscanf(strings.Replace(name, ".", " ", 1), "u_lights[%d] %s", &i, &field); err == nil && i < f.lightMax[program] {
+		for f, n := range lightFieldNames {
+			if n == field {
+				return fakeLightRef(i, f)
+			}
+		}
+	}
+	return -1
+}
+
+func (f *fakeBackend) SetUniformInt(loc int32, v int32) {
+	f.SetUniformFloats(loc, float32(v))
+}
+
+func (f *fakeBackend) SetUniformFloats(loc int32, v ...float32) {
+	if loc >= 0 {
+		f.uniforms[loc] = append([]float32{}, v...)
+	}
+}
+
+func (f *fakeBackend) SetUniformMatrix(loc int32, m *Mat4s) {
+	f.SetUniformFloats(loc, m.ToGLArray()...)
+}
+
+func (f *fakeBackend) BindVertexBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	f.bound = buf
+	if len(attribs) > 0 {
+		f.offset = attribs[0].Offset
+	}
+}
+
+func (f *fakeBackend) DrawArrays(mode DrawMode, first, count int) {
+	uniforms := make(map[int32][]float32)
+	for k, v := range f.uniforms {
+		uniforms[k] = v
+	}
+	textures := make(map[int]uint32)
+	for k, v := range f.units {
+		textures[k] = v
+	}
+	f.draws = append(f.draws, fakeDraw{mode: mode, buffer: f.bound, first: first, count: count, uniforms: uniforms, state: f.state, textures: textures, program: f.program, target: f.target, viewport: f.viewport})
+}
+
+func (f *fakeBackend) DrawElements(mode DrawMode, buf uint32, typ IndexType, first, count int) {
+	f.DrawArrays(mode, first, count)
+	draw := &f.draws[len(f.draws)-1]
+	draw.indexBuffer, draw.indexType, draw.offset = buf, typ, f.offset
+}
+
+func TestRendererUploadsOnce(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100), ClearColour: 0xff102030}
+	scene.AddShape("cube1", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xff0000ff, "")
+	scene.AddShape("plane1", ShapePlane, 2, 2, 0, Vec3{5, 0, -10}, Vec3{}, 0, 0xff00ff00, "")
+
+	scene.Draw()
+	scene.Draw()
+
+	if scene.Renderer == nil {
+		t.Fatal("no renderer created for a backend without fixed-function support")
+	}
+	if fake.uploads != 2 {
+		t.Errorf("%d buffer uploads for two shapes over two frames, want 2", fake.uploads)
+	}
+	if len(fake.draws) != 4 {
+		t.Fatalf("%d draw calls, want 4", len(fake.draws))
+	}
+	if len(fake.clears) != 2 || fake.clears[0] != "ff102030 0,0,0,0" {
+		t.Errorf("clears = %v", fake.clears)
+	}
+
+	//Each draw uses the shape's own vertices and model matrix
+	for _, draw := range fake.draws {
+		model := draw.uniforms[int32(modelMatrixRef)]
+		var shape *Shape
+		for _, s := range scene.Shapes {
+			if s.Position.X == model[12] {
+				shape = s
+			}
+		}
+		if shape == nil {
+			t.Fatalf("draw with unexpected model matrix %v", model)
+		}
+		mesh := shape.BuildMesh()
+		if draw.count != len(mesh.Verts)/VERTSIZE {
+			t.Errorf("%s: drew %d vertices, want %d", shape.Name, draw.count, len(mesh.Verts)/VERTSIZE)
+		}
+		uploaded := fake.buffers[draw.buffer][draw.first*VERTSIZE:]
+		if uploaded[0] != mesh.Verts[0] || math.Float32bits(uploaded[len(mesh.Verts)-1]) != math.Float32bits(mesh.Verts[len(mesh.Verts)-1]) {
+			t.Errorf("%s: buffer contents don't match the mesh", shape.Name)
+		}
+		r, _, _, _ := ColToRGBA(shape.Colour)
+		if diffuse := draw.uniforms[int32(diffuseRef)]; diffuse[0] != r {
+			t.Errorf("%s: diffuse = %v, want red %v", shape.Name, diffuse, r)
+		}
+	}
+}
+
+func TestSceneRendererFailsOnce(t *testing.T) {
+	fake := newFakeBackend()
+	fake.failProgram = errors.New("no shaders")
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("cube1", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xff0000ff, "")
+	scene.Draw()
+	scene.Draw()
+	if scene.Renderer != nil || len(fake.draws) != 0 {
+		t.Errorf("renderer %v drew %d times, want none", scene.Renderer, len(fake.draws))
+	}
+	if fake.programs != 1 {
+		t.Errorf("%d attempts to build the renderer over two frames, want 1", fake.programs)
+	}
+}
+
+func TestRendererMeshRetriesUpload(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	r, err := NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene := Scene{}
+	scene.AddShape("cube1", ShapeCuboid, 1, 1, 1, Vec3{}, Vec3{}, 0, 0xff0000ff, "")
+	shape := scene.Shapes["cube1"]
+	fake.failBuffer = errors.New("out of memory")
+	if mesh, err := r.Mesh(shape); mesh != nil || err == nil {
+		t.Fatalf("Mesh = %v, %v, want the upload error", mesh, err)
+	}
+	if _, err := r.Mesh(shape); err == nil {
+		t.Error("second Mesh returned no error, the failed upload was cached")
+	}
+	fake.failBuffer = nil
+	if mesh, err := r.Mesh(shape); mesh == nil || err != nil {
+		t.Errorf("Mesh = %v, %v after the backend recovered, want the uploaded mesh", mesh, err)
+	}
+}
+
+func TestViewsClearTheirViewport(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Width: 200, Height: 100}
+	scene.SetupSplitScreen(0xff000000, NewCamera(90, 1, 1, 100), NewCamera(90, 1, 1, 100))
+	scene.Draw()
+
+	want := "[ff000000 0,0,100,100 ff000000 100,0,100,100]"
+	if got := fmt.Sprint(fake.clears); got != want {
+		t.Errorf("clears = %v, want %v", got, want)
+	}
+}
+
+type countingController struct {
+	updates int
+}
+
+func (c *countingController) Update(cam *Camera, ui *UserInput, dt float32) {
+	c.updates++
+}
+
+func TestViewsUpdateCamerasOnce(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Width: 200, Height: 100, Camera: NewCamera(90, 1, 1, 100)}
+	counter := &countingController{}
+	shared := NewCamera(90, 1, 1, 100)
+	shared.Controller = counter
+	scene.AddView("left", shared, Viewport{0, 0, 0.5, 1}, 0, LayerAll)
+	scene.AddView("right", shared, Viewport{0.5, 0, 0.5, 1}, 0, LayerAll)
+	scene.AddView("overlay", nil, FullViewport, 0, LayerAll)
+	scene.Update(&UserInput{}, 0.1)
+
+	if counter.updates != 1 {
+		t.Errorf("camera shared by two views updated %d times, want once", counter.updates)
+	}
+	if scene.View("overlay").Camera != scene.Camera {
+		t.Error("view added without a camera doesn't use the scene camera")
+	}
+	scene.Draw()
+	if len(fake.clears) != 3 {
+		t.Errorf("%d views cleared, want 3", len(fake.clears))
+	}
+}
+
+func TestESShaderSource(t *testing.T) {
+	if src := ESShaderSource(defaultFragmentShader, true); src != defaultFragmentShader {
+		t.Error("fs.txt already declares a precision and shouldn't be changed")
+	}
+	src := "void main() { gl_FragColor = vec4(1.0); }"
+	if got := ESShaderSource(src, true); !strings.HasPrefix(got, "#ifdef GL_FRAGMENT_PRECISION_HIGH") || !strings.HasSuffix(got, src) {
+		t.Errorf("fragment shader = %q", got)
+	}
+	if got := ESShaderSource(src, false); got != "precision highp float;\n"+src {
+		t.Errorf("vertex shader = %q", got)
+	}
+}
+
+// TestGLESScene draws through OpenGL ES 2.0. Without a GPU run it with LIBGL_ALWAYS_SOFTWARE=1.
+func TestGLESScene(t *testing.T) {
+	defer SetBackend(nil)
+	opts := GLESSceneOptions(320, 240)
+	opts.Hidden = true
+	scene := Scene{}
+	if err := scene.SetupWithOptions("GLES", opts); err != nil {
+		t.Skip("no OpenGL ES context:", err)
+	}
+	defer scene.Quit()
+
+	scene.AddShape("cube1", ShapeCuboid, 3, 3, 3, Vec3{0, 0, -20}, Vec3{0, 0, 0}, 6, 0xff00ffff, "")
+	scene.Draw()
+	if scene.Renderer == nil {
+		t.Fatal("scene didn't create a renderer for the GLES backend")
+	}
+	if _, err := scene.Renderer.Mesh(scene.Shapes["cube1"]); err != nil {
+		t.Error(err)
+	}
+	scene.Window.GLSwap()
+}
@@ -0,0 +1,142 @@
+package goengine
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func newBatchScene(t *testing.T) (*Scene, *fakeBackend) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	t.Cleanup(func() { SetBackend(nil) })
+
+	scene := &Scene{Camera: NewCamera(90, 1, 1, 100)}
+	for i := 0; i < 100; i++ {
+		col := uint32(0xff0000ff)
+		if i%2 == 1 {
+			col = 0xff00ff00
+		}
+		name := fmt.Sprintf("cube%03d", i)
+		scene.AddShape(name, ShapeCuboid, 0.5, 0.5, 0.5, Vec3{float32(i), 0, -10}, Vec3{}, 0, col, "")
+		scene.Shapes[name].Static = true
+	}
+	scene.AddShape("mover", ShapeSphere, 1, 1, 1, Vec3{0, 2, -10}, Vec3{}, 12, 0xffffffff, "")
+	return scene, fake
+}
+
+func TestBatchDrawCalls(t *testing.T) {
+	scene, fake := newBatchScene(t)
+
+	scene.Draw()
+	if len(fake.draws) != 3 {
+		t.Fatalf("%d draw calls for 2 colours of static shapes and 1 moving shape, want 3", len(fake.draws))
+	}
+	batches := scene.Renderer.Batches()
+	if len(batches) != 2 || len(batches[0].Shapes) != 50 || len(batches[1].Shapes) != 50 {
+		t.Fatalf("batches = %v", batches)
+	}
+	cube := scene.Shapes["cube000"].BuildMesh()
+	if batches[0].Verts() != 50*cube.VertCount() {
+		t.Errorf("batch has %d vertices, want %d", batches[0].Verts(), 50*cube.VertCount())
+	}
+
+	uploads := fake.uploads
+	scene.Draw()
+	if fake.uploads != uploads {
+		t.Errorf("%d uploads drawing an unchanged scene again", fake.uploads-uploads)
+	}
+	if len(fake.draws) != 6 {
+		t.Errorf("%d draw calls over two frames, want 6", len(fake.draws))
+	}
+}
+
+func TestBatchWorldSpace(t *testing.T) {
+	scene, fake := newBatchScene(t)
+	scene.Draw()
+
+	for _, draw := range fake.draws {
+		model := draw.uniforms[int32(modelMatrixRef)]
+		if draw.count > 1000 && (model[0] != 1 || model[5] != 1 || model[12] != 0 || model[14] != 0) {
+			t.Errorf("batch drawn with model matrix %v, want identity", model)
+		}
+	}
+
+	//cube001 is the first green cube, the second batch
+	batch := scene.Renderer.Batches()[1]
+	verts := fake.buffers[batch.mesh.vbo][batch.mesh.VertOffset:]
+	local := scene.Shapes["cube001"].BuildMesh()
+	if verts[0] != local.Verts[0]+1 || verts[1] != local.Verts[1] || verts[2] != local.Verts[2]-10 {
+		t.Errorf("first batched vertex %v, want %v moved by (1,0,-10)", verts[:3], local.Verts[:3])
+	}
+}
+
+func TestBatchRebuildsChangedGroup(t *testing.T) {
+	scene, fake := newBatchScene(t)
+	scene.Draw()
+	red, green := scene.Renderer.Batches()[0], scene.Renderer.Batches()[1]
+	redMesh, greenMesh := red.mesh, green.mesh
+
+	scene.Shapes["cube002"].Position.Y = 5
+	scene.Draw()
+	if red.mesh == redMesh {
+		t.Error("moving a red cube didn't rebuild its batch")
+	}
+	if green.mesh != greenMesh {
+		t.Error("moving a red cube rebuilt the green batch")
+	}
+
+	//Recolouring moves a shape between batches
+	scene.Shapes["cube002"].Colour = 0xff00ff00
+	scene.Draw()
+	if len(red.Shapes) != 49 || len(green.Shapes) != 51 {
+		t.Errorf("after recolouring, batches have %d and %d shapes, want 49 and 51", len(red.Shapes), len(green.Shapes))
+	}
+
+	//Geometry changes are only picked up when marked dirty
+	greenMesh = green.mesh
+	scene.Shapes["cube003"].W = 2
+	scene.Draw()
+	if green.mesh != greenMesh {
+		t.Error("batch rebuilt without the shape being marked dirty")
+	}
+	scene.Renderer.MarkDirty(scene.Shapes["cube003"])
+	scene.Draw()
+	if green.mesh == greenMesh {
+		t.Error("batch not rebuilt after marking a shape dirty")
+	}
+
+	//Shapes no longer static are drawn on their own
+	draws := len(fake.draws)
+	scene.Shapes["cube004"].Static = false
+	scene.Draw()
+	if len(fake.draws)-draws != 4 {
+		t.Errorf("%d draw calls after unbatching a shape, want 4", len(fake.draws)-draws)
+	}
+}
+
+func TestTransformVerts(t *testing.T) {
+	shape := NewShape("cube", ShapeCuboid, 1, 1, 1, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+	mesh := shape.BuildMesh()
+	want := shape.BuildMesh()
+
+	tf := NewTransform(Vec3{1, 2, 3}, Vec3{0, 90, 0})
+	tf.Scale = Vec3{2, 2, 2}
+	m := tf.Matrix()
+	mesh.TransformVerts(&m)
+
+	for i := 0; i < len(mesh.Verts); i += VERTSIZE {
+		pos := Vec3{want.Verts[i], want.Verts[i+1], want.Verts[i+2]}.MulMat4(&m)
+		got := Vec3{mesh.Verts[i], mesh.Verts[i+1], mesh.Verts[i+2]}
+		if got.Sub(pos).Length() > 1e-4 {
+			t.Fatalf("vertex %d at %v, want %v", i/VERTSIZE, got, pos)
+		}
+		normal := Vec3{mesh.Verts[i+3], mesh.Verts[i+4], mesh.Verts[i+5]}
+		if l := normal.Length(); l < 0.999 || l > 1.001 {
+			t.Fatalf("vertex %d normal %v isn't unit length after scaling", i/VERTSIZE, normal)
+		}
+		if mesh.Verts[i+6] != want.Verts[i+6] || math.Float32bits(mesh.Verts[i+8]) != math.Float32bits(want.Verts[i+8]) {
+			t.Fatalf("vertex %d uv or colour changed", i/VERTSIZE)
+		}
+	}
+}
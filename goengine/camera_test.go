@@ -0,0 +1,125 @@
+package goengine
+
+import "testing"
+
+func vec3AlmostEqual(a, b Vec3) bool {
+	return almostEqual(a.X, b.X) && almostEqual(a.Y, b.Y) && almostEqual(a.Z, b.Z)
+}
+
+func matAlmostEqual(m *Mat4s, want []float32) bool {
+	for i, v := range m.ToGLArray() {
+		if !almostEqual(v, want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCameraProjectionMatrix(t *testing.T) {
+	perspective := NewCamera(90, 2, 1, 3)
+	ortho := NewCamera(90, 2, 1, 3)
+	ortho.SetOrthographic(10, 2, 1, 3)
+
+	tests := []struct {
+		name string
+		cam  *Camera
+		want []float32 //column major, as uploaded to the shader
+	}{
+		{"perspective", perspective, []float32{0.5, 0, 0, 0, 0, 1, 0, 0, 0, 0, -2, -1, 0, 0, -3, 0}},
+		{"orthographic", ortho, []float32{0.1, 0, 0, 0, 0, 0.2, 0, 0, 0, 0, -1, 0, 0, 0, -2, 1}},
+	}
+	for _, test := range tests {
+		if got := test.cam.ProjectionMatrix(); !matAlmostEqual(got, test.want) {
+			t.Errorf("%s: projection %v, want %v", test.name, got.ToGLArray(), test.want)
+		}
+	}
+}
+
+func TestCameraViewMatrix(t *testing.T) {
+	tests := []struct {
+		name             string
+		position, target Vec3
+		want             []float32
+	}{
+		{"looking down -Z", Vec3{1, 2, 3}, Vec3{1, 2, 0}, []float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, -1, -2, -3, 1}},
+		{"looking down +X", Vec3{}, Vec3{1, 0, 0}, []float32{0, 0, -1, 0, 0, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1}},
+		{"looking back at the origin", Vec3{0, 0, -5}, Vec3{}, []float32{-1, 0, 0, 0, 0, 1, 0, 0, 0, 0, -1, 0, 0, 0, -5, 1}},
+	}
+	for _, test := range tests {
+		cam := NewCamera(60, 1, 0.1, 100)
+		cam.Position, cam.Target = test.position, test.target
+		if got := cam.ViewMatrix(); !matAlmostEqual(got, test.want) {
+			t.Errorf("%s: view %v, want %v", test.name, got.ToGLArray(), test.want)
+		}
+	}
+}
+
+func TestCameraDirections(t *testing.T) {
+	cam := NewCamera(60, 1, 0.1, 100)
+	if !vec3AlmostEqual(cam.Forward(), Vec3{0, 0, -1}) || !vec3AlmostEqual(cam.Right(), Vec3{1, 0, 0}) {
+		t.Errorf("forward %v right %v, want -Z and +X", cam.Forward(), cam.Right())
+	}
+	cam.Target = cam.Position
+	if cam.Forward() != (Vec3{0, 0, -1}) {
+		t.Errorf("forward %v with the target at the camera, want -Z", cam.Forward())
+	}
+	if cam.SetFov(200); cam.Fov != 179 {
+		t.Errorf("fov %v, want it clamped to 179", cam.Fov)
+	}
+}
+
+func TestCameraControllers(t *testing.T) {
+	held := func(keys ...Key) *UserInput {
+		ui := &UserInput{Keys: make(map[Key]bool)}
+		for _, key := range keys {
+			ui.Keys[key] = true
+		}
+		return ui
+	}
+	drag := func(dx, dy int32) *UserInput {
+		return &UserInput{Mouse: Mouse{DX: dx, DY: dy, LeftButton: true}}
+	}
+
+	tests := []struct {
+		name             string
+		controller       CameraController
+		ui               *UserInput
+		dt               float32
+		position, target Vec3
+	}{
+		{"orbit at rest", NewOrbitController(Vec3{1, 0, 0}, 10), &UserInput{}, 0.1, Vec3{1, 0, 10}, Vec3{1, 0, 0}},
+		{"orbit rotate", NewOrbitController(Vec3{}, 10), drag(-300, 0), 0.1, Vec3{10, 0, 0}, Vec3{}},
+		{"orbit pitch clamped", NewOrbitController(Vec3{}, 10), drag(0, -1000), 0.1, Vec3{0, 9.9985, 0.1745}, Vec3{}},
+		{"orbit zoom", NewOrbitController(Vec3{}, 10), &UserInput{Mouse: Mouse{Wheel: 1}}, 0.1, Vec3{0, 0, 9}, Vec3{}},
+		{"orbit zoom clamped", &OrbitController{Distance: 10, MinDistance: 2, MaxDistance: 20, ZoomSpeed: 1}, &UserInput{Mouse: Mouse{Wheel: 1}}, 0.1, Vec3{0, 0, 2}, Vec3{}},
+		{"fly forward", NewFlyController(2), held(KeyW), 0.5, Vec3{0, 0, -1}, Vec3{0, 0, -2}},
+		{"fly strafe and climb", NewFlyController(2), held(KeyD, KeyE), 0.5, Vec3{1, 1, 0}, Vec3{1, 1, -1}},
+		{"fly keys cancel", NewFlyController(2), held(KeyW, KeyS), 0.5, Vec3{}, Vec3{0, 0, -1}},
+		{"fly turn", NewFlyController(2), drag(-450, 0), 0.5, Vec3{}, Vec3{-1, 0, 0}},
+		{"first person walk", NewFirstPersonController(1.5, 2), held(KeyUp), 0.5, Vec3{0, 1.5, -1}, Vec3{0, 1.5, -2}},
+		{"first person ignores climb", NewFirstPersonController(1.5, 2), held(KeyE), 0.5, Vec3{0, 1.5, 0}, Vec3{0, 1.5, -1}},
+		{"first person looks without a button", NewFirstPersonController(1.5, 2), &UserInput{Mouse: Mouse{DX: -450}}, 0.5, Vec3{0, 1.5, 0}, Vec3{-1, 1.5, 0}},
+	}
+	for _, test := range tests {
+		cam := NewCamera(60, 1, 0.1, 100)
+		cam.Controller = test.controller
+		cam.Update(test.ui, test.dt)
+		if !vec3AlmostEqual(cam.Position, test.position) || !vec3AlmostEqual(cam.Target, test.target) {
+			t.Errorf("%s: camera at %v looking at %v, want %v and %v", test.name, cam.Position, cam.Target, test.position, test.target)
+		}
+	}
+}
+
+func TestFirstPersonWalksFlat(t *testing.T) {
+	fp := NewFirstPersonController(1.5, 2)
+	cam := NewCamera(60, 1, 0.1, 100)
+	cam.Controller = fp
+	cam.Update(&UserInput{Mouse: Mouse{DY: -150}}, 0) //look 30 degrees up
+	cam.Update(&UserInput{Keys: map[Key]bool{KeyW: true}}, 1)
+	if !vec3AlmostEqual(cam.Position, Vec3{0, 1.5, -2}) {
+		t.Errorf("walked to %v looking up, want to stay at eye height", cam.Position)
+	}
+	if fp.Pitch != 30 {
+		t.Errorf("pitch %v, want 30", fp.Pitch)
+	}
+}
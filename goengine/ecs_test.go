@@ -0,0 +1,147 @@
+package goengine
+
+import "testing"
+
+type velocity struct {
+	V Vec3
+}
+
+func TestWorldComponents(t *testing.T) {
+	w := NewWorld(nil)
+	a := w.Create("a")
+	b := w.Create("b")
+	c := w.Create("c")
+	for _, e := range []Entity{a, b, c} {
+		AddComponent(w, e, NewTransform(Vec3{}, Vec3{}))
+	}
+	AddComponent(w, a, velocity{Vec3{1, 0, 0}})
+	AddComponent(w, c, velocity{Vec3{0, 2, 0}})
+
+	w.AddSystem("move", 0, SystemFunc(func(w *World, dt float32) {
+		Each2(w, func(e Entity, v *velocity, t *Transform) {
+			t.Position.SetAdd(v.V.MulScalar(dt))
+		})
+	}))
+	w.Update(0.5)
+
+	if ta, _ := GetComponent[Transform](w, a); !almostEqual(ta.Position.X, 0.5) {
+		t.Errorf("a moved to %v, want x 0.5", ta.Position)
+	}
+	if tb, _ := GetComponent[Transform](w, b); tb.Position != (Vec3{}) {
+		t.Errorf("b without velocity moved to %v", tb.Position)
+	}
+
+	fast := Query(w, func(e Entity, v *velocity) bool { return v.V.Length() > 1 })
+	if len(fast) != 1 || fast[0] != c {
+		t.Errorf("query = %v, want [%v]", fast, c)
+	}
+
+	//Destroying inside a system is deferred so iteration isn't disturbed
+	w.AddSystem("cull", 1, SystemFunc(func(w *World, dt float32) {
+		Each(w, func(e Entity, v *velocity) { w.Destroy(e) })
+	}))
+	w.Update(0)
+	if w.Alive(a) || w.Alive(c) || !w.Alive(b) || Components[velocity](w).Len() != 0 {
+		t.Errorf("after cull: alive a=%v b=%v c=%v", w.Alive(a), w.Alive(b), w.Alive(c))
+	}
+	if e, ok := w.Lookup("b"); !ok || e != b {
+		t.Errorf("Lookup(b) = %v, %v", e, ok)
+	}
+}
+
+func TestWorldMatrixParent(t *testing.T) {
+	w := NewWorld(nil)
+	parent := w.Create("parent")
+	child := w.Create("child")
+	AddComponent(w, parent, NewTransform(Vec3{10, 0, 0}, Vec3{0, 90, 0}))
+	ct := NewTransform(Vec3{0, 0, -1}, Vec3{})
+	ct.Parent = parent
+	AddComponent(w, child, ct)
+
+	//Rotating 90 degrees about Y takes -Z to -X
+	m := w.WorldMatrix(child)
+	if pos := m.Pos(); !almostEqual(pos.X, 9) || !almostEqual(pos.Z, 0) {
+		t.Errorf("child world position = %v, want (9,0,0)", pos)
+	}
+}
+
+func TestWorldDraw(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := &Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("wheel", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -1}, Vec3{}, 0, 0xffffffff, "")
+	w := NewWorld(scene)
+	car := w.Create("car")
+	AddComponent(w, car, NewTransform(Vec3{10, 0, 0}, Vec3{0, 90, 0}))
+	wheel := w.SpawnShape(scene.Shapes["wheel"])
+	place, _ := GetComponent[Transform](w, wheel)
+	place.Parent = car
+	if len(scene.Shapes) != 0 {
+		t.Error("spawned shape left in the scene's shapes")
+	}
+	lamp := NewPointLight("lamp", Vec3{0, 2, 0}, 0xffffffff, 10)
+	AddComponent(w, car, LightComponent{Light: lamp, Enabled: true})
+	w.Update(0)
+	if l, ok := scene.Light("lamp"); !ok || l != lamp {
+		t.Error("entity's light not added to the scene")
+	}
+
+	scene.Draw()
+	if len(fake.draws) != 1 {
+		t.Fatalf("%d draws, want the wheel once", len(fake.draws))
+	}
+	model := fake.draws[0].uniforms[int32(modelMatrixRef)]
+	if !almostEqual(model[12], 9) || !almostEqual(model[14], 0) {
+		t.Errorf("wheel drawn at (%v,%v,%v), want (9,0,0) on the turned car", model[12], model[13], model[14])
+	}
+	if fake.draws[0].uniforms[int32(lightCountRef)][0] != 1 {
+		t.Error("wheel not lit by the entity's light")
+	}
+}
+
+func TestWorldLights(t *testing.T) {
+	scene := &Scene{}
+	sun := NewDirectionalLight("lamp", Vec3{0, -1, 0}, 0xffffffff)
+	scene.AddLight(sun)
+	w := NewWorld(scene)
+
+	//a light with the same name as a scene light is added alongside it
+	lamp := NewPointLight("lamp", Vec3{}, 0xffffffff, 10)
+	post := w.Create("post")
+	AddComponent(w, post, LightComponent{Light: lamp, Enabled: true})
+	w.Update(0)
+	if len(scene.Lights) != 2 || !scene.hasLight(sun) || !scene.hasLight(lamp) {
+		t.Fatalf("scene lights = %v, want the sun and the entity's lamp", scene.Lights)
+	}
+	w.Update(0)
+	if len(scene.Lights) != 2 {
+		t.Errorf("%d scene lights after a second update, want 2", len(scene.Lights))
+	}
+
+	RemoveComponent[LightComponent](w, post)
+	w.Update(0)
+	if len(scene.Lights) != 1 || scene.Lights[0] != sun {
+		t.Errorf("scene lights = %v after removing the component, want just the sun", scene.Lights)
+	}
+
+	torch := w.Create("torch")
+	AddComponent(w, torch, LightComponent{Light: lamp, Enabled: true})
+	w.Update(0)
+	w.Destroy(torch)
+	w.Update(0)
+	if len(scene.Lights) != 1 || scene.Lights[0] != sun {
+		t.Errorf("scene lights = %v after destroying the entity, want just the sun", scene.Lights)
+	}
+
+	//lights added to the scene by the caller stay when their component goes
+	sign := w.Create("sign")
+	AddComponent(w, sign, LightComponent{Light: sun, Enabled: true})
+	w.Update(0)
+	RemoveComponent[LightComponent](w, sign)
+	w.Update(0)
+	if !scene.hasLight(sun) {
+		t.Error("scene's own light removed with the component")
+	}
+}
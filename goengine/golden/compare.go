@@ -0,0 +1,100 @@
+package golden
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Tolerance decides when two images match. A pixel only counts as different if a channel
+// differs by more than Channel and its perceptual difference is more than Perceptual.
+// The images match if no more than MaxDiffPixels of the pixels are different.
+type Tolerance struct {
+	Channel       uint8   //0..255
+	Perceptual    float64 //0..1, the YIQ colour distance used by pixelmatch
+	MaxDiffPixels float64 //fraction of the image
+}
+
+// DefaultTolerance absorbs rounding and edge rasterization differences but not changed shapes or colours
+var DefaultTolerance = Tolerance{Channel: 8, Perceptual: 0.01, MaxDiffPixels: 0.002}
+
+// Result describes how two images differ
+type Result struct {
+	OK            bool
+	Pixels        int
+	DiffPixels    int
+	MaxChannel    uint8
+	MaxPerceptual float64
+	Diff          *image.RGBA //the reference in grey with different pixels in red, nil if the sizes differ
+	sizeMismatch  string
+}
+
+func (r Result) String() string {
+	if r.sizeMismatch != "" {
+		return r.sizeMismatch
+	}
+	return fmt.Sprintf("%d of %d pixels differ (%.3f%%), max channel difference %d, max perceptual difference %.4f",
+		r.DiffPixels, r.Pixels, 100*float64(r.DiffPixels)/float64(max(r.Pixels, 1)), r.MaxChannel, r.MaxPerceptual)
+}
+
+// Compare compares got with the reference want
+func Compare(want, got *image.RGBA, tol Tolerance) Result {
+	if want.Rect.Size() != got.Rect.Size() {
+		return Result{sizeMismatch: fmt.Sprintf("image is %v, reference is %v", got.Rect.Size(), want.Rect.Size())}
+	}
+	size := want.Rect.Size()
+	result := Result{Pixels: size.X * size.Y, Diff: image.NewRGBA(image.Rect(0, 0, size.X, size.Y))}
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			w := want.RGBAAt(want.Rect.Min.X+x, want.Rect.Min.Y+y)
+			g := got.RGBAAt(got.Rect.Min.X+x, got.Rect.Min.Y+y)
+			channel := max(absDiff(w.R, g.R), absDiff(w.G, g.G), absDiff(w.B, g.B), absDiff(w.A, g.A))
+			perceptual := colourDelta(w, g)
+			result.MaxChannel = max(result.MaxChannel, channel)
+			result.MaxPerceptual = max(result.MaxPerceptual, perceptual)
+
+			if channel > tol.Channel && perceptual > tol.Perceptual {
+				result.DiffPixels++
+				result.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				grey := uint8(255 - (255-luma(w))/4) //faded
+				result.Diff.SetRGBA(x, y, color.RGBA{grey, grey, grey, 255})
+			}
+		}
+	}
+	result.OK = float64(result.DiffPixels) <= tol.MaxDiffPixels*float64(result.Pixels)
+	return result
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// blend puts a colour with alpha over white
+func blend(c color.RGBA) (float64, float64, float64) {
+	a := float64(c.A) / 255
+	return 255 + (float64(c.R)-255)*a, 255 + (float64(c.G)-255)*a, 255 + (float64(c.B)-255)*a
+}
+
+func yiq(c color.RGBA) (float64, float64, float64) {
+	r, g, b := blend(c)
+	return r*0.29889531 + g*0.58662247 + b*0.11448223,
+		r*0.59597799 - g*0.27417610 - b*0.32180189,
+		r*0.21147017 - g*0.52261711 + b*0.31114694
+}
+
+// colourDelta is the perceived difference between two colours from 0 (same) to 1 (black and white)
+func colourDelta(a, b color.RGBA) float64 {
+	y1, i1, q1 := yiq(a)
+	y2, i2, q2 := yiq(b)
+	dy, di, dq := y1-y2, i1-i2, q1-q2
+	return (0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq) / 35215
+}
+
+func luma(c color.RGBA) uint8 {
+	y, _, _ := yiq(c)
+	return uint8(min(max(y, 0), 255))
+}
@@ -0,0 +1,154 @@
+// Package golden renders scenes in tests and compares them with reference images.
+//
+// References are PNGs in testdata/golden. Run the tests with -update to write them again
+// after an intended change. When an image doesn't match, the actual image and a diff
+// highlighting the changed pixels are written to the system temp directory.
+package golden
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	g "github.com/timskillman/go-sdl/goengine"
+)
+
+var update = flag.Bool("update", false, "write golden reference images instead of comparing")
+
+// Path selects how scenes are rendered
+type Path int
+
+const (
+	// Software renders on the CPU with the SoftwareBackend, which gives the same result on every machine
+	Software Path = iota
+	// OpenGL renders offscreen in a hidden window, through Mesa's llvmpipe unless
+	// LIBGL_ALWAYS_SOFTWARE is already set. Tests are skipped if no context can be created.
+	OpenGL
+)
+
+// Options sets the image size, render path and how different an image may be from its reference
+type Options struct {
+	Width  int32
+	Height int32
+	Path   Path
+	Dir    string //references, testdata/golden if empty
+	Tolerance
+}
+
+// DefaultOptions renders 128x128 in software and allows small differences
+func DefaultOptions() Options {
+	return Options{Width: 128, Height: 128, Path: Software, Tolerance: DefaultTolerance}
+}
+
+// Render creates a scene, populates it with build and renders it to an image
+func Render(t testing.TB, opts Options, build func(scene *g.Scene)) *image.RGBA {
+	t.Helper()
+	scene := &g.Scene{}
+	defer g.SetBackend(nil)
+
+	switch opts.Path {
+	case Software:
+		scene.SetupSoftware(opts.Width, opts.Height)
+	case OpenGL:
+		if _, ok := os.LookupEnv("LIBGL_ALWAYS_SOFTWARE"); !ok {
+			os.Setenv("LIBGL_ALWAYS_SOFTWARE", "1")
+		}
+		sceneOpts := g.DefaultSceneOptions(opts.Width, opts.Height)
+		sceneOpts.Hidden = true
+		sceneOpts.VSync = g.VSyncOff
+		if err := scene.SetupWithOptions("golden", sceneOpts); err != nil {
+			t.Skip("no OpenGL context:", err)
+		}
+		defer scene.Quit()
+	default:
+		t.Fatalf("unknown render path %d", opts.Path)
+	}
+
+	build(scene)
+	img, err := scene.RenderToImage(opts.Width, opts.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// Scene renders a scene and checks it against the reference image called name
+func Scene(t testing.TB, name string, opts Options, build func(scene *g.Scene)) {
+	t.Helper()
+	Check(t, name, Render(t, opts, build), opts)
+}
+
+// Check compares img with the reference image called name, or writes the reference with -update.
+// OpenGL references are stored separately from software ones as the results differ slightly.
+func Check(t testing.TB, name string, img *image.RGBA, opts Options) {
+	t.Helper()
+	dir := opts.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "golden")
+	}
+	if opts.Path == OpenGL {
+		name += "_gl"
+	}
+	file := filepath.Join(dir, name+".png")
+
+	if *update {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.SaveImage(img, file); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %s", file)
+		return
+	}
+
+	want, err := loadPNG(file)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	result := Compare(want, img, opts.Tolerance)
+	if result.OK {
+		return
+	}
+
+	out := filepath.Join(os.TempDir(), "golden")
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	actual, diff := filepath.Join(out, name+"_actual.png"), filepath.Join(out, name+"_diff.png")
+	if err := g.SaveImage(img, actual); err != nil {
+		t.Error(err)
+	}
+	if result.Diff != nil {
+		if err := g.SaveImage(result.Diff, diff); err != nil {
+			t.Error(err)
+		}
+	}
+	t.Errorf("%s doesn't match %s: %s\nactual: %s\ndiff: %s", name, file, result, actual, diff)
+}
+
+func loadPNG(file string) (*image.RGBA, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, nil
+	}
+	rgba := image.NewRGBA(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba, nil
+}
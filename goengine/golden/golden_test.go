@@ -0,0 +1,96 @@
+package golden
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	g "github.com/timskillman/go-sdl/goengine"
+)
+
+const imageDir = "../../Resources/images/"
+
+func TestCompare(t *testing.T) {
+	want := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for i := range want.Pix {
+		want.Pix[i] = 128
+	}
+	got := image.NewRGBA(want.Rect)
+	copy(got.Pix, want.Pix)
+
+	if r := Compare(want, got, DefaultTolerance); !r.OK || r.DiffPixels != 0 {
+		t.Errorf("identical images: %s", r)
+	}
+
+	got.SetRGBA(3, 3, color.RGBA{132, 128, 124, 128}) //within the channel tolerance
+	if r := Compare(want, got, DefaultTolerance); !r.OK || r.DiffPixels != 0 {
+		t.Errorf("slightly different pixel: %s", r)
+	}
+
+	for x := 0; x < 4; x++ {
+		got.SetRGBA(x, 10, color.RGBA{255, 0, 0, 255})
+	}
+	r := Compare(want, got, DefaultTolerance)
+	if r.OK || r.DiffPixels != 4 {
+		t.Errorf("changed pixels: %s", r)
+	}
+	if r.Diff.RGBAAt(2, 10) != (color.RGBA{255, 0, 0, 255}) || r.Diff.RGBAAt(2, 11).R != r.Diff.RGBAAt(2, 11).G {
+		t.Error("diff image should show changed pixels in red over grey")
+	}
+	if r := Compare(want, got, Tolerance{Channel: 8, Perceptual: 0.01, MaxDiffPixels: 0.01}); !r.OK {
+		t.Errorf("1%% of pixels may differ: %s", r)
+	}
+
+	if r := Compare(want, image.NewRGBA(image.Rect(0, 0, 10, 20)), DefaultTolerance); r.OK {
+		t.Error("different sizes should not match")
+	}
+}
+
+var shapes = []struct {
+	name                 string
+	shapeType            g.ShapeType
+	width, depth, height float32
+	edges                uint32
+}{
+	{"cuboid", g.ShapeCuboid, 2, 2, 2, 6},
+	{"sphere", g.ShapeSphere, 3, 0, 1, 24},
+	{"cylinder", g.ShapeCylinder, 2, 3, 8, 16},
+	{"cone", g.ShapeCone, 2, 3, 0, 16},
+	{"tcone", g.ShapeTCone, 1, 3, 2, 16},
+	{"tube", g.ShapeTube, 1, 2, 3, 16},
+	{"torus", g.ShapeTorus, 2.5, 1, 20, 16},
+	{"spring", g.ShapeSpring, 1.5, 0.3, 3, 90},
+}
+
+// TestShapes catches visual regressions in the shape generators and the lathe
+func TestShapes(t *testing.T) {
+	testShapes(t, DefaultOptions())
+}
+
+// TestShapesOpenGL draws the same shapes through the GL backend and shaders, against
+// their own _gl references. It is skipped without an OpenGL context.
+func TestShapesOpenGL(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Path = OpenGL
+	testShapes(t, opts)
+}
+
+func testShapes(t *testing.T, opts Options) {
+	for _, sh := range shapes {
+		t.Run(sh.name, func(t *testing.T) {
+			Scene(t, sh.name, opts, func(scene *g.Scene) {
+				scene.SetClearColour(0xff402010)
+				scene.AddShape(sh.name, sh.shapeType, sh.width, sh.depth, sh.height, g.V3(0, 0, -8), g.V3(25, 30, 0), sh.edges, 0xff20c0ff, "")
+			})
+		})
+	}
+}
+
+func TestTexturedShape(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Width = 160
+	Scene(t, "textured_cube", opts, func(scene *g.Scene) {
+		scene.SetClearColour(0xff402010)
+		scene.AddShape("cube", g.ShapeCuboid, 2, 2, 2, g.V3(0, 0, -6), g.V3(30, 40, 0), 6, 0xffffffff, imageDir+"redsky.png")
+	})
+}
@@ -0,0 +1,216 @@
+package goengine
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+type fakeInstancedDraw struct {
+	buffer, instanceBuffer uint32
+	count, instances       int
+	attribs                []VertexAttrib
+}
+
+// fakeInstancer is a fakeBackend with instanced arrays
+type fakeInstancer struct {
+	*fakeBackend
+	instanceBuffer  uint32
+	instanceAttribs []VertexAttrib
+	instanced       []fakeInstancedDraw
+}
+
+func (f *fakeInstancer) Instancing() bool { return true }
+
+func (f *fakeInstancer) BindInstanceBuffer(buf uint32, stride int, attribs []VertexAttrib) {
+	f.instanceBuffer, f.instanceAttribs = buf, attribs
+}
+
+func (f *fakeInstancer) UnbindInstanceBuffer(attribs []VertexAttrib) {
+	f.instanceBuffer, f.instanceAttribs = 0, nil
+}
+
+func (f *fakeInstancer) DrawArraysInstanced(mode DrawMode, first, count, instances int) {
+	f.instanced = append(f.instanced, fakeInstancedDraw{f.bound, f.instanceBuffer, count, instances, f.instanceAttribs})
+}
+
+func (f *fakeInstancer) DrawElementsInstanced(mode DrawMode, buf uint32, typ IndexType, first, count, instances int) {
+	f.DrawArraysInstanced(mode, first, count, instances)
+}
+
+func newForest(n int) *Instances {
+	cone := NewShape("tree", ShapeCone, 1, 2, 1, Vec3{}, Vec3{}, 8, 0xff00ff00, "")
+	forest := NewShapeInstances(&cone)
+	for i := 0; i < n; i++ {
+		inst := Instance{Position: Vec3{float32(i), 0, -10}, UVOffset: Vec2{0.5, 0.25}}
+		if i%2 == 1 {
+			inst.Colour = 0xff808080
+		}
+		forest.Add(inst)
+	}
+	return forest
+}
+
+func TestInstancesInstanced(t *testing.T) {
+	fake := &fakeInstancer{fakeBackend: newFakeBackend()}
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	forest := newForest(1000)
+	scene.AddInstances(forest)
+	scene.Draw()
+
+	if len(fake.draws) != 0 || len(fake.instanced) != 1 {
+		t.Fatalf("%d draws and %d instanced draws, want 1 instanced draw", len(fake.draws), len(fake.instanced))
+	}
+	draw := fake.instanced[0]
+	if draw.instances != 1000 || draw.count != forest.Mesh.VertCount() {
+		t.Errorf("drew %d instances of %d vertices, want 1000 of %d", draw.instances, draw.count, forest.Mesh.VertCount())
+	}
+	if len(draw.attribs) != 6 {
+		t.Errorf("%d per-instance attributes bound, want 6", len(draw.attribs))
+	}
+	data := fake.buffers[draw.instanceBuffer]
+	if len(data) != 1000*INSTANCESIZE {
+		t.Fatalf("instance buffer has %d floats, want %d", len(data), 1000*INSTANCESIZE)
+	}
+	item := data[7*INSTANCESIZE : 8*INSTANCESIZE]
+	if item[12] != 7 || item[14] != -10 || item[15] != 1 {
+		t.Errorf("instance 7 matrix %v, want a translation to 7,0,-10", item[:16])
+	}
+	if item[16] < 0.5 || item[16] > 0.51 || item[19] != 1 || item[20] != 0.5 || item[21] != 0.25 {
+		t.Errorf("instance 7 colour and uv offset %v", item[16:])
+	}
+
+	//Unchanged instances aren't uploaded again, and changes update the buffer in place
+	uploads := fake.uploads
+	scene.Draw()
+	if fake.uploads != uploads {
+		t.Errorf("%d uploads redrawing unchanged instances", fake.uploads-uploads)
+	}
+	forest.Items[7].Position.Y = 3
+	forest.MarkDirty()
+	scene.Draw()
+	if fake.uploads != uploads+1 || fake.instanced[2].instanceBuffer != draw.instanceBuffer {
+		t.Errorf("%d uploads after moving an instance, want 1 update of the same buffer", fake.uploads-uploads)
+	}
+	if got := fake.buffers[draw.instanceBuffer][7*INSTANCESIZE+13]; got != 3 {
+		t.Errorf("moved instance y = %v, want 3", got)
+	}
+
+	scene.RemoveInstances("tree")
+	if _, ok := fake.buffers[draw.instanceBuffer]; ok {
+		t.Error("instance buffer not freed")
+	}
+}
+
+func TestInstancesBatchFallback(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	forest := newForest(1000)
+	scene.AddInstances(forest)
+	scene.Draw()
+
+	if len(fake.draws) != 2 {
+		t.Fatalf("%d draws, want a batch for each of the 2 instance colours", len(fake.draws))
+	}
+	verts := forest.Mesh.VertCount()
+	for _, draw := range fake.draws {
+		if draw.count != 500*verts {
+			t.Errorf("batch of %d vertices, want %d", draw.count, 500*verts)
+		}
+	}
+	light, dark := fake.draws[0], fake.draws[1]
+	if light.uniforms[int32(diffuseRef)][1] < dark.uniforms[int32(diffuseRef)][1] {
+		light, dark = dark, light
+	}
+	if g := dark.uniforms[int32(diffuseRef)][1]; g < 0.5 || g > 0.51 {
+		t.Errorf("dark batch green %v, want the shape colour times the instance colour", g)
+	}
+
+	//The first instance is moved to 0,0,-10 and its texture offset
+	batched := fake.buffers[light.buffer][light.first*VERTSIZE:]
+	local := forest.Mesh.Verts
+	if batched[2] != local[2]-10 || batched[6] != local[6]+0.5 || batched[7] != local[7]-0.25 {
+		t.Errorf("first batched vertex %v, want %v moved", batched[:VERTSIZE], local[:VERTSIZE])
+	}
+
+	uploads := fake.uploads
+	scene.Draw()
+	if fake.uploads != uploads {
+		t.Errorf("%d uploads redrawing unchanged instances", fake.uploads-uploads)
+	}
+}
+
+func TestInstancesSoftware(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	cube := NewShape("crate", ShapeCuboid, 1, 1, 1, Vec3{}, Vec3{}, 6, 0xffffffff, "")
+	crates := NewShapeInstances(&cube)
+	crates.Add(Instance{Position: Vec3{-3, 0, -10}, Colour: 0xff0000ff})
+	crates.Add(Instance{Position: Vec3{3, 0, -10}, Colour: 0xff00ff00})
+	scene.AddInstances(crates)
+	scene.Draw()
+
+	//without shaders each copy is drawn in immediate mode
+	img := b.Image()
+	if got := img.RGBAAt(22, 32); got.R < 150 || got.G != 0 {
+		t.Errorf("left = %v, want the red copy", got)
+	}
+	if got := img.RGBAAt(42, 32); got.G < 150 || got.R != 0 {
+		t.Errorf("right = %v, want the green copy", got)
+	}
+	if got := img.RGBAAt(32, 32); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("centre = %v, want the clear colour", got)
+	}
+}
+
+func TestInstancedShaderAttributes(t *testing.T) {
+	attributes, err := GetAttributes(instancedVertexShader)
+	if err != "" {
+		t.Fatal(err)
+	}
+	perVertex := PackedLayout.Bind(attributes)
+	perInstance := InstanceLayout.Bind(attributes)
+	if len(perVertex) != 3 || len(perInstance) != 6 {
+		t.Fatalf("%d vertex and %d instance attributes in %v", len(perVertex), len(perInstance), attributes)
+	}
+	if perInstance[0].Location != 3 || perInstance[5].Offset != 20 || perInstance[5].Size != 2 {
+		t.Errorf("instance attributes %v", perInstance)
+	}
+}
+
+func TestShaderVariants(t *testing.T) {
+	variants := map[string]string{"instanced": instancedVertexShader, "morph": morphVertexShader, "skinned": skinnedVertexShader}
+	for name, src := range variants {
+		if strings.Contains(src, "//#variant") {
+			t.Errorf("%s shader still has a variant marker", name)
+		}
+		//variants keep vs.txt's lights and texture transform
+		if !strings.Contains(src, "u_lights[") || !strings.Contains(src, "u_uvTransform") {
+			t.Errorf("%s shader is missing vs.txt's lighting or texture transform", name)
+		}
+	}
+	attributes, err := GetAttributes(morphVertexShader)
+	if err != "" {
+		t.Fatal(err)
+	}
+	if len(attributes) != 3+2*MaxGPUMorphs || attributes[3] != "a_MorphPos0" {
+		t.Errorf("morph attributes = %v", attributes)
+	}
+}
+
+func TestInstanceLayout(t *testing.T) {
+	if InstanceLayout.Stride != INSTANCESIZE {
+		t.Errorf("instance layout stride %d, want INSTANCESIZE %d", InstanceLayout.Stride, INSTANCESIZE)
+	}
+}
+
+func TestMulColours(t *testing.T) {
+	if got := mulColours(0xff00ff80, 0x80ffffff); got != 0x8000ff80 {
+		t.Errorf("mulColours = %08x, want 8000ff80", got)
+	}
+}
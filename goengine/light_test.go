@@ -0,0 +1,157 @@
+package goengine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func lightUniform(draw fakeDraw, i, field int) []float32 {
+	return draw.uniforms[fakeLightRef(i, field)]
+}
+
+func TestLightUniforms(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("ground", ShapePlane, 20, 20, 0, Vec3{0, -2, -10}, Vec3{-90, 0, 0}, 0, 0xffffffff, "")
+	scene.AddShape("lamppost", ShapeCuboid, 0.2, 0.2, 4, Vec3{5, 0, -10}, Vec3{}, 0, 0xff404040, "")
+	lamp := NewPointLight("lamp", Vec3{0, 2, 0}, 0xff80c0ff, 10)
+	lamp.Node = scene.Shapes["lamppost"]
+	scene.AddLight(NewDirectionalLight("moon", Vec3{0, -1, 0}, 0xff402020))
+	scene.AddLight(lamp)
+	scene.AddLight(NewSpotLight("torch", Vec3{0, 5, -10}, Vec3{0, -1, 0}, 0xffffffff, 20, 20, 30))
+	scene.Draw()
+
+	draw := fake.draws[0]
+	if n := draw.uniforms[int32(lightCountRef)]; n[0] != 3 {
+		t.Fatalf("%v lights, want 3", n)
+	}
+	if pos := lightUniform(draw, 0, lightPositionField); pos[1] != 1 || pos[3] != 0 {
+		t.Errorf("moon position %v, want the direction towards it with w 0", pos)
+	}
+	if pos := lightUniform(draw, 1, lightPositionField); pos[0] != 5 || pos[1] != 2 || pos[2] != -10 || pos[3] != 1 {
+		t.Errorf("lamp at %v, want on top of the lamppost", pos)
+	}
+	if col := lightUniform(draw, 1, lightColourField); col[0] != 1 || !almostEqual(col[2], 0x80/255.0) {
+		t.Errorf("lamp colour %v", col)
+	}
+	dir, falloff := lightUniform(draw, 2, lightDirectionField), lightUniform(draw, 2, lightFalloffField)
+	if dir[1] != -1 || !almostEqual(dir[3], math32.Cos(DegToRad(30))) || !almostEqual(falloff[3], math32.Cos(DegToRad(20))) {
+		t.Errorf("torch direction %v and cones %v, %v", dir, dir[3], falloff[3])
+	}
+	if falloff[0] != 1 || !almostEqual(falloff[1], 4.5/20) || !almostEqual(falloff[2], 75.0/400) {
+		t.Errorf("torch attenuation %v", falloff)
+	}
+
+	//lights move with their node every frame
+	scene.Shapes["lamppost"].Position = Vec3{-5, 0, -20}
+	scene.Draw()
+	if pos := lightUniform(fake.draws[len(fake.draws)-1], 1, lightPositionField); pos[0] != -5 || pos[1] != 2 || pos[2] != -20 {
+		t.Errorf("lamp at %v after moving the lamppost", pos)
+	}
+}
+
+func TestLightLimit(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	if r, err := NewLitRenderer(LightingOptions{PerPixel: true, MaxLights: 3}); err != nil || r.MaxLights() != 3 {
+		t.Fatalf("per-pixel renderer with 3 lights: %v", err)
+	}
+	r, err := NewLitRenderer(LightingOptions{MaxLights: 2})
+	if err != nil || r.MaxLights() != 2 {
+		t.Fatalf("renderer with 2 lights: %v", err)
+	}
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100), Renderer: r}
+	scene.AddShape("cube", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xffffffff, "")
+	for i, z := range []float32{-50, -5, -10, -2} {
+		scene.AddLight(NewPointLight(fmt.Sprint("lamp", i), Vec3{0, 0, z}, 0xffffffff, 10))
+	}
+	scene.Lights[3].Enabled = false
+	scene.Draw()
+
+	draw := fake.draws[0]
+	if n := draw.uniforms[int32(lightCountRef)]; n[0] != 2 {
+		t.Fatalf("%v lights, want 2", n)
+	}
+	near, next := lightUniform(draw, 0, lightPositionField), lightUniform(draw, 1, lightPositionField)
+	if near[2] != -5 || next[2] != -10 {
+		t.Errorf("lights at z %v and %v, want the nearest enabled ones", near[2], next[2])
+	}
+}
+
+func TestLightSingleLightShader(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	r, err := NewShaderRenderer(depthVertexShader, defaultFragmentShader) //no u_lights array
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MaxLights() != 1 {
+		t.Errorf("%d lights without a u_lights array, want 1", r.MaxLights())
+	}
+	r.SetLights([]*Light{NewPointLight("far", Vec3{0, 0, -40}, 0xffffffff, 0), NewPointLight("near", Vec3{1, 2, 3}, 0xff0000ff, 0)})
+	r.Begin(NewCamera(90, 1, 1, 100))
+	if pos := fake.uniforms[int32(lightPosRef)]; pos[0] != 1 || pos[1] != 2 || pos[2] != 3 {
+		t.Errorf("single light at %v, want the nearest light", pos)
+	}
+	if col := fake.uniforms[int32(lightColRef)]; col[0] != 1 || col[1] != 0 {
+		t.Errorf("single light colour %v, want red", col)
+	}
+	r.End()
+}
+
+func TestLightPlace(t *testing.T) {
+	w := NewWorld(nil)
+	car := w.Create("car")
+	AddComponent(w, car, NewTransform(Vec3{10, 0, 0}, Vec3{0, 90, 0}))
+	headlight := NewSpotLight("headlight", Vec3{0, 1, -2}, Vec3{0, 0, -1}, 0xffffffff, 30, 10, 20)
+	AddComponent(w, car, LightComponent{Light: headlight, Enabled: true})
+	w.Update(0)
+	headlight.update()
+
+	pos, dir := headlight.WorldPosition(), headlight.WorldDirection()
+	if !almostEqual(pos.Y, 1) || !almostEqual(math32.Abs(pos.X-10), 2) || !almostEqual(pos.Z, 0) {
+		t.Errorf("headlight at %v, want 2 in front of the turned car", pos)
+	}
+	if !almostEqual(math32.Abs(dir.X), 1) || !almostEqual(dir.Z, 0) {
+		t.Errorf("headlight shining along %v, want turned 90 degrees", dir)
+	}
+	if (pos.X-10)*dir.X < 0 {
+		t.Errorf("headlight at %v shines back along %v", pos, dir)
+	}
+}
+
+func TestSoftwareLights(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	//separate panels as the software backend lights each vertex
+	scene.AddShape("near", ShapePlane, 1, 1, 0, Vec3{-5, 0, -10}, Vec3{}, 6, 0xffffffff, "")
+	scene.AddShape("far", ShapePlane, 1, 1, 0, Vec3{5, 0, -10}, Vec3{}, 6, 0xffffffff, "")
+	scene.AddLight(NewPointLight("lamp", Vec3{-5, 0, -8}, 0xffffffff, 40))
+	scene.Draw()
+
+	img := b.Image()
+	near, far := img.RGBAAt(16, 32), img.RGBAAt(48, 32)
+	if near.R < 220 || far.R > 145 {
+		t.Errorf("panel next to the lamp %v and across from it %v, want bright then nearly the ambient grey", near, far)
+	}
+
+	//outside the cone of a spot light only the ambient light is left
+	scene.Lights = nil
+	scene.AddShape("far", ShapePlane, 1, 1, 0, Vec3{6, 0, -10}, Vec3{}, 6, 0xffffffff, "")
+	scene.AddShape("centre", ShapePlane, 1, 1, 0, Vec3{0, 0, -10}, Vec3{}, 6, 0xffffffff, "")
+	scene.AddLight(NewSpotLight("spot", Vec3{0, 0, -5}, Vec3{0, 0, -1}, 0xffffffff, 0, 20, 30))
+	scene.Draw()
+	img = b.Image()
+	centre, side := img.RGBAAt(32, 32), img.RGBAAt(51, 32)
+	if centre.R < 250 || side.R > 136 {
+		t.Errorf("spot centre %v and side %v, want lit then the ambient grey", centre, side)
+	}
+}
@@ -0,0 +1,142 @@
+package goengine
+
+import (
+	"testing"
+)
+
+func TestMaterialUniforms(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	mat := NewMaterial("brick", 0xff2040ff)
+	mat.Specular, mat.Emissive, mat.Shininess, mat.Illumination = 0xffffffff, 0xff000080, 10, 1
+	mat.UVScale, mat.UVRotation, mat.UVOffset = Vec2{2, 3}, 90, Vec2{0.5, 0.25}
+	mat.Textures[NormalMap] = NewColourTexture(0xffff8080)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("a", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xffffffff, "")
+	scene.AddShape("b", ShapeCuboid, 1, 1, 1, Vec3{3, 0, -10}, Vec3{}, 0, 0xffffffff, "")
+	scene.Shapes["a"].Material = mat
+	scene.Shapes["b"].Material = mat
+	scene.Draw()
+
+	if len(fake.draws) != 2 {
+		t.Fatalf("%d draws, want 2", len(fake.draws))
+	}
+	for _, draw := range fake.draws {
+		u := draw.uniforms
+		if u[int32(diffuseRef)][1] != 0x40/255.0 || u[int32(specularRef)][0] != 1 || u[int32(emissiveRef)][0] != 0x80/255.0 {
+			t.Errorf("colours diffuse %v specular %v emissive %v", u[int32(diffuseRef)], u[int32(specularRef)], u[int32(emissiveRef)])
+		}
+		if u[int32(shininessRef)][0] != 10 || u[int32(illuminationModelRef)][0] != 1 {
+			t.Errorf("shininess %v and illumination %v, want 10 and 1", u[int32(shininessRef)], u[int32(illuminationModelRef)])
+		}
+		uv := u[int32(uvTransformRef)]
+		if !almostEqual(uv[0], 0) || !almostEqual(uv[1], 2) || !almostEqual(uv[2], -3) || !almostEqual(uv[3], 0) {
+			t.Errorf("uv transform %v, want scaled by 2,3 and turned 90 degrees", uv)
+		}
+		if off := u[int32(texAnimRef)]; off[0] != 0.5 || off[1] != -0.25 {
+			t.Errorf("uv offset %v", off)
+		}
+		if draw.textures[int(NormalMap)] != mat.Textures[NormalMap].id {
+			t.Errorf("normal map unit has texture %d, want %d", draw.textures[int(NormalMap)], mat.Textures[NormalMap].id)
+		}
+	}
+
+	//Both shapes share the material, so changing it changes them both
+	mat.Diffuse = 0xff00ff00
+	scene.Draw()
+	for _, draw := range fake.draws[2:] {
+		if g := draw.uniforms[int32(diffuseRef)][1]; g != 1 {
+			t.Errorf("diffuse green %v after changing the shared material, want 1", g)
+		}
+	}
+}
+
+func TestMaterialTransparentLast(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	glass := NewMaterial("glass", 0x80ffffff)
+	glass.Blend, glass.Cull, glass.Depth = BlendAlpha, CullNone, DepthRead
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	for i, name := range []string{"near", "far", "wall"} {
+		scene.AddShape(name, ShapePlane, 1, 1, 0, Vec3{0, 0, -5 - 5*float32(i)}, Vec3{}, 0, 0xff808080, "")
+	}
+	scene.Shapes["near"].Material = glass
+	scene.Shapes["far"].Material = glass
+	scene.Draw()
+
+	if len(fake.draws) != 3 {
+		t.Fatalf("%d draws, want 3", len(fake.draws))
+	}
+	wall, far, near := fake.draws[0], fake.draws[1], fake.draws[2]
+	if wall.state != (RenderState{}) || wall.uniforms[int32(diffuseRef)][3] != 1 {
+		t.Errorf("first draw %+v, want the opaque wall", wall.state)
+	}
+	if far.uniforms[int32(modelMatrixRef)][14] != -10 || near.uniforms[int32(modelMatrixRef)][14] != -5 {
+		t.Error("transparent shapes not drawn from the furthest to the nearest")
+	}
+	if near.state != glass.RenderState {
+		t.Errorf("glass drawn with %+v, want %+v", near.state, glass.RenderState)
+	}
+	if fake.state != (RenderState{}) {
+		t.Errorf("render state %+v after drawing, want the default", fake.state)
+	}
+}
+
+func TestMaterialTransparentBatchesSorted(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	glass := NewMaterial("glass", 0x80ffffff)
+	glass.Blend, glass.Cull, glass.Depth = BlendAlpha, CullNone, DepthRead
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	for i, name := range []string{"near", "pane", "far"} {
+		scene.AddShape(name, ShapePlane, 1, 1, 0, Vec3{0, 0, -5 - 5*float32(i)}, Vec3{}, 0, 0xff808080, "")
+		scene.Shapes[name].Material = glass
+	}
+	scene.Shapes["pane"].Static = true
+	scene.Draw()
+
+	if len(fake.draws) != 3 {
+		t.Fatalf("%d draws, want 3", len(fake.draws))
+	}
+	//the batch is in world space so is drawn with the identity model matrix
+	for i, z := range []float32{-15, 0, -5} {
+		if got := fake.draws[i].uniforms[int32(modelMatrixRef)][14]; got != z {
+			t.Errorf("draw %d model z = %v, want %v for far, the static pane then near", i, got, z)
+		}
+	}
+}
+
+func TestMaterialBatches(t *testing.T) {
+	scene, fake := newBatchScene(t)
+	mat := NewMaterial("red", 0xff0000ff)
+	scene.Shapes["cube000"].Material = mat
+	scene.Shapes["cube002"].Material = mat
+	scene.Draw()
+
+	batches := scene.Renderer.Batches()
+	if len(batches) != 3 || len(fake.draws) != 4 {
+		t.Fatalf("%d batches in %d draws, want the material's own batch", len(batches), len(fake.draws))
+	}
+	if batches[0].Material != mat || len(batches[0].Shapes) != 2 {
+		t.Errorf("first batch material %v with %d shapes", batches[0].Material, len(batches[0].Shapes))
+	}
+}
+
+func TestParseTextureMap(t *testing.T) {
+	file, scale, offset := parseTextureMap("-blendu on -s 2 4 1 -o 0.5 0.25 my bricks.png")
+	if file != "my bricks.png" || scale != (Vec2{2, 4}) || offset != (Vec2{0.5, 0.25}) {
+		t.Errorf("parsed %q scale %v offset %v", file, scale, offset)
+	}
+	if file, scale, _ := parseTextureMap("wood.png"); file != "wood.png" || scale != (Vec2{1, 1}) {
+		t.Errorf("parsed %q scale %v", file, scale)
+	}
+}
@@ -0,0 +1,136 @@
+package goengine
+
+import "testing"
+
+func TestBuildMesh(t *testing.T) {
+	tests := []struct {
+		shape Shape
+		verts int
+	}{
+		{NewShape("cube", ShapeCuboid, 1, 1, 1, Vec3{}, Vec3{}, 0, 0xffffffff, ""), 6 * 6},
+		{NewShape("plane", ShapePlane, 1, 1, 0, Vec3{}, Vec3{}, 0, 0xffffffff, ""), 6},
+		{NewShape("lathe", ShapeLathe, 1, 1, 1, Vec3{}, Vec3{}, 0, 0xffffffff, ""), 0},
+	}
+	for _, test := range tests {
+		mesh := test.shape.BuildMesh()
+		if n := len(mesh.Verts) / VERTSIZE; n != test.verts {
+			t.Errorf("%s: %d vertices, want %d", test.shape.Name, n, test.verts)
+		}
+	}
+
+	torus := NewShape("torus", ShapeTorus, 5, 2, 8, Vec3{}, Vec3{}, 10, 0xffffffff, "")
+	if n := len(torus.BuildMesh().Verts) / VERTSIZE; n == 0 || n%(10*6) != 0 {
+		t.Errorf("torus: %d vertices, want whole rows of 10 quads", n)
+	}
+
+	//The first cube vertex is the front face's bottom left corner
+	cube := NewShape("cube", ShapeCuboid, 1, 2, 3, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+	v := cube.BuildMesh().Verts
+	if v[0] != -1 || v[1] != -2 || v[2] != 3 || v[5] != 1 {
+		t.Errorf("first vertex = %v normal = %v, want (-1,-2,3) and (0,0,1)", v[0:3], v[3:6])
+	}
+}
+
+// quadShape is a ShapeTriangles square of 4 shared vertices facing +Z
+func quadShape(name string, size float32) Shape {
+	shape := NewShape(name, ShapeTriangles, 0, 0, 0, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+	for _, p := range []Vec2{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		shape.Verts = append(shape.Verts, storeVNTC2(0xffffff, Vec3{p.X * size, p.Y * size, 0}, Vec3{0, 0, 1}, Vec2{(p.X + 1) / 2, (p.Y + 1) / 2})...)
+	}
+	shape.Indexes = []int{0, 1, 2, 0, 2, 3}
+	return shape
+}
+
+func TestBuildMeshIndexed(t *testing.T) {
+	quad := quadShape("quad", 1)
+	mesh := quad.BuildMesh()
+	if mesh.VertCount() != 4 || len(mesh.Indexes) != 6 || mesh.Indexes[5] != 3 {
+		t.Fatalf("%d vertices and indices %v, want 4 shared vertices", mesh.VertCount(), mesh.Indexes)
+	}
+	if v := mesh.Verts[2*VERTSIZE:]; v[0] != 1 || v[1] != 1 || v[5] != 1 {
+		t.Errorf("vertex 2 = %v, want position (1,1,0) normal (0,0,1)", v[:6])
+	}
+	if mesh.IndexType() != Index16 {
+		t.Error("small mesh doesn't use 16-bit indices")
+	}
+	big := &Mesh{Stride: VERTSIZE, Verts: make([]float32, (1<<16+1)*VERTSIZE)}
+	if big.IndexType() != Index32 {
+		t.Error("mesh of more than 65536 vertices doesn't use 32-bit indices")
+	}
+}
+
+func TestMeshStrips(t *testing.T) {
+	mesh := &Mesh{}
+	mesh.Init()
+	mesh.Verts = make([]float32, 8*VERTSIZE)
+	mesh.AddStrip([]uint32{0, 1, 2})    //one triangle, leaving an odd length
+	mesh.AddStrip([]uint32{3, 4, 5, 6}) //two triangles
+	if DrawMode(mesh.Mode) != DrawTriangleStrip {
+		t.Fatalf("mode %d, want triangle strip", mesh.Mode)
+	}
+
+	var tris [][3]int
+	mesh.Triangles(func(a, b, c int) { tris = append(tris, [3]int{a, b, c}) })
+	want := [][3]int{{0, 1, 2}, {3, 4, 5}, {5, 4, 6}}
+	if len(tris) != len(want) {
+		t.Fatalf("triangles %v, want %v", tris, want)
+	}
+	for i := range want {
+		if tris[i] != want[i] {
+			t.Errorf("triangle %d = %v, want %v", i, tris[i], want[i])
+		}
+	}
+}
+
+func TestMeshAppend(t *testing.T) {
+	quad := quadShape("quad", 1)
+	cube := NewShape("cube", ShapeCuboid, 1, 1, 1, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+
+	merged := &Mesh{}
+	merged.Init()
+	merged.Append(cube.BuildMesh())
+	if merged.Indexed() {
+		t.Error("appending a triangle list made an indexed mesh")
+	}
+	merged.Append(quad.BuildMesh())
+	if merged.VertCount() != 36+4 || len(merged.Indexes) != 36+6 {
+		t.Fatalf("%d vertices and %d indices, want 40 and 42", merged.VertCount(), len(merged.Indexes))
+	}
+	if merged.Indexes[35] != 35 || merged.Indexes[36] != 36 || merged.Indexes[41] != 39 {
+		t.Errorf("indices %v don't follow on from the cube", merged.Indexes[34:])
+	}
+}
+
+func TestRendererIndexed(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("cube", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xffffffff, "")
+	quad := quadShape("quad", 1)
+	scene.Shapes["quad"] = &quad
+	scene.Draw()
+
+	var draw *fakeDraw
+	for i := range fake.draws {
+		if fake.draws[i].indexBuffer != 0 {
+			draw = &fake.draws[i]
+		}
+	}
+	if draw == nil || len(fake.draws) != 2 {
+		t.Fatalf("draws %v, want the quad drawn with indices", fake.draws)
+	}
+	if draw.count != 6 || draw.indexType != Index16 || len(fake.indexes[draw.indexBuffer]) != 6 {
+		t.Errorf("drew %d indices of type %x, want 6 16-bit indices", draw.count, draw.indexType)
+	}
+	mesh, _ := scene.Renderer.Mesh(&quad)
+	if draw.offset != mesh.VertOffset || draw.buffer != mesh.vbo {
+		t.Errorf("vertex buffer bound at %d, want the quad's first vertex at %d", draw.offset, mesh.VertOffset)
+	}
+
+	scene.Renderer.Invalidate(&quad)
+	if _, ok := fake.indexes[draw.indexBuffer]; ok {
+		t.Error("index buffer not freed with the mesh")
+	}
+}
@@ -0,0 +1,162 @@
+package goengine
+
+import "testing"
+
+func TestApplyMorphs(t *testing.T) {
+	mesh := &Mesh{}
+	mesh.AddPackedVert(Vec3{1, 1, 1}, Vec3{0, 1, 0}, Vec2{}, 0)
+	mesh.AddPackedVert(Vec3{2, 0, 0}, Vec3{1, 0, 0}, Vec2{}, 0)
+
+	if err := mesh.AddMorphTarget("raise", []Vec3{{0, 2, 0}, {0, 2, 0}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.AddSpherifyTarget("sphere", Vec3{}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.AddMorphTarget("bad", []Vec3{{}}, nil); err == nil {
+		t.Error("expected error for a target with the wrong vertex count")
+	}
+
+	mesh.SetMorphWeight("raise", 0.5)
+	mesh.ApplyMorphs()
+	if v := mesh.Verts; !almostEqual(v[1], 2) || !almostEqual(v[VERTSIZE+1], 1) {
+		t.Errorf("raise 0.5: y = %v, %v, want 2, 1", v[1], v[VERTSIZE+1])
+	}
+
+	mesh.SetMorphWeight("raise", 0)
+	mesh.SetMorphWeight("sphere", 1)
+	mesh.ApplyMorphs()
+	v := mesh.Verts
+	if l := (Vec3{v[0], v[1], v[2]}).Length(); !almostEqual(l, 1) {
+		t.Errorf("spherified vertex is %v from the centre, want 1", l)
+	}
+	if !almostEqual(v[VERTSIZE], 1) || !almostEqual(v[VERTSIZE+3], 1) {
+		t.Errorf("spherified vertex = %v normal = %v, want (1,0,0)", v[VERTSIZE:VERTSIZE+3], v[VERTSIZE+3:VERTSIZE+6])
+	}
+	if !almostEqual(mesh.BaseVerts[0], 1) {
+		t.Error("base vertices were modified")
+	}
+
+	clip := NewAnimationClip("smile", NewFloatTrack("face", "raise", []float32{0, 1}, []float32{0, 1}, InterpLinear))
+	animator := NewAnimator()
+	if err := animator.BindMorphTargets("face", mesh, clip); err != nil {
+		t.Fatal(err)
+	}
+	animator.Play(clip, LoopOnce)
+	animator.Update(0.25)
+	if w := mesh.MorphWeights[0]; !almostEqual(w, 0.25) {
+		t.Errorf("animated weight = %v, want 0.25", w)
+	}
+}
+
+func TestApplyMorphsLayout(t *testing.T) {
+	//no normals and the position after the uv
+	layout := NewVertexLayout(
+		VertexAttribute{Name: AttribUV, Components: 2, Type: AttribFloat},
+		VertexAttribute{Name: AttribPosition, Components: 3, Type: AttribFloat},
+	)
+	mesh := &Mesh{Layout: layout, Stride: layout.Stride}
+	mesh.Verts = layout.Append(mesh.Verts, Vertex{Position: Vec3{2, 0, 0}, UV: Vec2{0.5, 0.5}})
+	mesh.Verts = layout.Append(mesh.Verts, Vertex{Position: Vec3{0, 0, 3}, UV: Vec2{0.5, 0.5}})
+
+	if err := mesh.AddSpherifyTarget("sphere", Vec3{}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if mesh.MorphTargets[0].Normals != nil {
+		t.Error("target has normals for a layout without them")
+	}
+	mesh.SetMorphWeight("sphere", 1)
+	mesh.ApplyMorphs()
+	for i := 0; i < 2; i++ {
+		v := mesh.Vertex(i)
+		if !almostEqual(v.Position.Length(), 1) || v.UV != (Vec2{0.5, 0.5}) {
+			t.Errorf("vertex %d = %v uv %v, want 1 from the centre with its uv unchanged", i, v.Position, v.UV)
+		}
+	}
+}
+
+func TestMorphMeshDraw(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	mesh := &Mesh{Mode: int(DrawTriangles)}
+	for i := 0; i < 3; i++ {
+		mesh.AddPackedVert(Vec3{float32(i), 0, 0}, Vec3{0, 0, 1}, Vec2{}, 0)
+	}
+	for i := 0; i <= MaxGPUMorphs; i++ {
+		if err := mesh.AddMorphTarget(string(rune('a'+i)), []Vec3{{0, 1, 0}, {0, 1, 0}, {0, 1, 0}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	morph := NewMorphMesh(mesh)
+	if err := morph.EnableGPU(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.buffers[morph.deltas]) != (MaxGPUMorphs+1)*3*6 {
+		t.Errorf("%d floats of deltas, want 6 for each vertex of each target", len(fake.buffers[morph.deltas]))
+	}
+	cam := NewCamera(90, 1, 1, 100)
+	model := Identity4()
+
+	mesh.SetMorphWeight("c", 0.5)
+	if err := morph.Draw(cam, model); err != nil {
+		t.Fatal(err)
+	}
+	draw := fake.draws[0]
+	if w := draw.uniforms[fakeMorphWeightsRef]; draw.program != morph.program.Program || len(w) != 4 || w[0] != 0.5 || w[1] != 0 {
+		t.Errorf("GPU blend with weights %v, want the active target's weight then zeros", w)
+	}
+	if draw.mode != DrawTriangles || draw.count != 3 {
+		t.Errorf("GPU blend drew %d vertices in mode %v, want 3 triangles", draw.count, draw.mode)
+	}
+
+	//more targets than the shader blends fall back to the CPU
+	for i := 0; i <= MaxGPUMorphs; i++ {
+		mesh.SetMorphWeight(string(rune('a'+i)), 0.2)
+	}
+	if err := morph.Draw(cam, model); err != nil {
+		t.Fatal(err)
+	}
+	draw = fake.draws[1]
+	if draw.program == morph.program.Program || !almostEqual(fake.buffers[draw.buffer][1], 1) {
+		t.Errorf("%d blended targets not drawn on the CPU", MaxGPUMorphs+1)
+	}
+
+	morph.Delete()
+	if len(fake.buffers) != 0 {
+		t.Errorf("%d buffers left after Delete", len(fake.buffers))
+	}
+}
+
+func TestMorphMeshDrawIndexed(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	mesh := &Mesh{}
+	for i := 0; i < 4; i++ {
+		mesh.AddPackedVert(Vec3{float32(i % 2), float32(i / 2), 0}, Vec3{0, 0, 1}, Vec2{}, 0)
+	}
+	mesh.AddStrip([]uint32{0, 1, 2, 3})
+	if err := mesh.AddMorphTarget("raise", []Vec3{{0, 1, 0}, {0, 1, 0}, {0, 1, 0}, {0, 1, 0}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	morph := NewMorphMesh(mesh)
+	if err := morph.EnableGPU(); err != nil {
+		t.Fatal(err)
+	}
+	mesh.SetMorphWeight("raise", 1)
+	if err := morph.Draw(NewCamera(90, 1, 1, 100), Identity4()); err != nil {
+		t.Fatal(err)
+	}
+	draw := fake.draws[0]
+	if draw.mode != DrawTriangleStrip || draw.count != 4 || len(fake.indexes[draw.indexBuffer]) != 4 {
+		t.Errorf("GPU blend drew %d indices in mode %v, want the strip's 4", draw.count, draw.mode)
+	}
+
+	morph.Delete()
+	if len(fake.buffers)+len(fake.indexes) != 0 {
+		t.Errorf("%d buffers left after Delete", len(fake.buffers)+len(fake.indexes))
+	}
+}
@@ -0,0 +1,103 @@
+package goengine
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestOBJ(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "textures"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	//the mtllib is relative to the OBJ file and textures are relative to the mtllib
+	mtl := filepath.Join(dir, "test.mtl")
+	obj := filepath.Join(dir, "test.obj")
+	files := map[string]string{
+		mtl: "newmtl red\nKd 1 0 0\n" +
+			"newmtl glass\nKd 0 0 1\nKa 0.5 0.5 0.5\nKs 1 1 1\nKe 0 0.5 0\nNs 20\nd 0.5\nillum 2\n" +
+			"map_Kd -s 2 2 textures/glass.png\n",
+		obj: "mtllib test.mtl\n" +
+			"v -1 -1 0\nv 1 -1 0\nv 1 1 0\nv -1 1 0\nv 0 0 1\n" +
+			"vn 0 0 1\n" +
+			"g square\nusemtl red\nf 1//1 2//1 3//1\nf 1//1 3//1 4//1\n" +
+			"g spike\nf 1//1 2//1 5//1\n" +
+			"g pane\nusemtl glass\nf 1//1 2//1 3//1\n" +
+			"g pane2\nusemtl glass\nf 1//1 3//1 4//1\n",
+	}
+	for name, text := range files {
+		if err := os.WriteFile(name, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Create(filepath.Join(dir, "textures", "glass.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestReadOBJ(t *testing.T) {
+	SetBackend(newFakeBackend())
+	defer SetBackend(nil)
+
+	shapes := ReadOBJ(writeTestOBJ(t), Scene{})
+	if len(shapes) != 4 {
+		t.Fatalf("%d shapes, want one for each group", len(shapes))
+	}
+	square, spike := shapes[0], shapes[1]
+	if square.Name != "square" || square.ShapeType != ShapeTriangles {
+		t.Errorf("first shape %q of type %d, want the square group as triangles", square.Name, square.ShapeType)
+	}
+	if len(square.Verts) != 4*VERTSIZE || len(square.Indexes) != 6 {
+		t.Errorf("square has %d vertices and %d indices, want 4 shared by 6", len(square.Verts)/VERTSIZE, len(square.Indexes))
+	}
+	if square.Colour != 0xff0000ff {
+		t.Errorf("square colour %08x, want the material's red", square.Colour)
+	}
+
+	//Each group only has the vertices it uses, numbered from 0
+	if len(spike.Verts) != 3*VERTSIZE || spike.Indexes[2] != 2 {
+		t.Errorf("spike has %d vertices and indices %v", len(spike.Verts)/VERTSIZE, spike.Indexes)
+	}
+	mesh := spike.BuildMesh()
+	if v := mesh.Verts[2*VERTSIZE:]; v[0] != 0 || v[1] != 0 || v[2] != 1 {
+		t.Errorf("spike tip at %v, want (0,0,1)", v[:3])
+	}
+}
+
+func TestReadOBJMaterials(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	shapes := ReadOBJ(writeTestOBJ(t), Scene{})
+	if len(shapes) != 4 {
+		t.Fatalf("%d shapes, want 4", len(shapes))
+	}
+	square, spike, pane, pane2 := shapes[0], shapes[1], shapes[2], shapes[3]
+	if square.Material == nil || spike.Material != square.Material {
+		t.Error("spike doesn't keep using the square's material")
+	}
+	glass := pane.Material
+	if glass == nil || pane2.Material != glass {
+		t.Fatal("panes don't share the glass material")
+	}
+	if glass.Diffuse != 0x80ff0000 || glass.Ambient != 0xff808080 || glass.Specular != 0xffffffff || glass.Emissive != 0xff008000 {
+		t.Errorf("glass colours %08x %08x %08x %08x", glass.Diffuse, glass.Ambient, glass.Specular, glass.Emissive)
+	}
+	if glass.Shininess != 20 || glass.Illumination != 2 || glass.Blend != BlendAlpha || glass.UVScale != (Vec2{2, 2}) {
+		t.Errorf("glass %+v", glass)
+	}
+	if tex := glass.Textures[DiffuseMap]; tex.id == 0 || fake.textures[tex.id] == nil || pane.Texture != tex {
+		t.Error("glass texture not loaded from beside the mtllib")
+	}
+}
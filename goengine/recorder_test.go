@@ -0,0 +1,107 @@
+package goengine
+
+import (
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordPNG(t *testing.T) {
+	scene, _ := newSoftwareScene(t)
+	scene.AddShape("cube", ShapeCuboid, 2, 2, 2, Vec3{0, 0, -10}, Vec3{}, 6, 0xff0000ff, "")
+	dir := filepath.Join(t.TempDir(), "frames")
+	scene.Recorder = NewRecorder(RecordPNG, dir)
+	scene.Recorder.Every = 2
+
+	scene.Draw()
+	scene.Swap() //not recording yet
+	if err := scene.Recorder.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		scene.Draw()
+		scene.Swap()
+	}
+	if err := scene.Recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	scene.Swap()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(files) != 3 || scene.Recorder.Frames() != 3 {
+		t.Fatalf("recorded %v, want frames 0, 2 and 4", files)
+	}
+	if filepath.Base(files[2]) != "frame00002.png" {
+		t.Errorf("last frame is %s", files[2])
+	}
+}
+
+func TestRecordGIF(t *testing.T) {
+	scene, _ := newSoftwareScene(t)
+	scene.AddShape("cube", ShapeCuboid, 2, 2, 2, Vec3{0, 0, -10}, Vec3{}, 6, 0xff0000ff, "")
+	file := filepath.Join(t.TempDir(), "clip.gif")
+	r := NewRecorder(RecordGIF, file)
+	r.FrameRate = 25
+	r.FixedTimestep = true
+	scene.Recorder = r
+
+	if r.FrameTime() != 0 {
+		t.Error("fixed timestep should only apply while recording")
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if r.FrameTime() != 1.0/25 {
+		t.Errorf("frame time = %v, want 1/25", r.FrameTime())
+	}
+	for i := 0; i < 4; i++ {
+		scene.Shapes["cube"].Rotation.Y += 10
+		scene.Draw()
+		scene.Swap()
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 4 || anim.Delay[0] != 4 {
+		t.Errorf("gif has %d frames with delay %d, want 4 frames of 4/100s", len(anim.Image), anim.Delay[0])
+	}
+	if anim.Image[0].Bounds().Dx() != 64 {
+		t.Errorf("frame size %v", anim.Image[0].Bounds())
+	}
+
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Stop(); err == nil {
+		t.Error("expected an error stopping a GIF with no frames")
+	}
+	if err := r.Capture(image.NewRGBA(image.Rect(0, 0, 1, 1))); err == nil {
+		t.Error("expected an error capturing when not recording")
+	}
+
+	//a frame rate of 0 is 60fps
+	r.FrameRate = 0
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	scene.Draw()
+	scene.Swap()
+	if delay := r.anim.Delay[0]; delay != 2 {
+		t.Errorf("delay at frame rate 0 is %d, want 2/100s", delay)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
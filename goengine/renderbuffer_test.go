@@ -0,0 +1,146 @@
+package goengine
+
+import (
+	"errors"
+	"testing"
+)
+
+func testMesh(verts int, value float32) *Mesh {
+	mesh := &Mesh{}
+	mesh.Init()
+	mesh.Verts = make([]float32, verts*VERTSIZE)
+	for i := range mesh.Verts {
+		mesh.Verts[i] = value
+	}
+	return mesh
+}
+
+// checkUploaded compares the fake GL buffer with the mesh at its offset
+func checkUploaded(t *testing.T, fake *fakeBackend, mesh *Mesh) {
+	t.Helper()
+	buf := fake.buffers[mesh.vbo]
+	if mesh.VertOffset%mesh.Stride != 0 {
+		t.Fatalf("offset %d isn't a whole number of vertices", mesh.VertOffset)
+	}
+	for i, v := range mesh.Verts {
+		if buf[mesh.VertOffset+i] != v {
+			t.Fatalf("buffer %d at %d = %v, want %v", mesh.vbo, mesh.VertOffset+i, buf[mesh.VertOffset+i], v)
+		}
+	}
+}
+
+func TestRenderBufferAllocation(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	b := RenderBuffer{MaxBufSize: 100 * VERTSIZE}
+	a, m, c := testMesh(30, 1), testMesh(30, 2), testMesh(30, 3)
+	for _, mesh := range []*Mesh{a, m, c} {
+		if err := b.AddMesh(mesh); err != nil {
+			t.Fatal(err)
+		}
+		checkUploaded(t, fake, mesh)
+	}
+	if b.Buffers() != 1 || m.VertOffset != 30*VERTSIZE || c.VertSize != 30 {
+		t.Fatalf("meshes not packed into one buffer: %d buffers, offset %d", b.Buffers(), m.VertOffset)
+	}
+	if err := b.AddMesh(a); !errors.Is(err, ErrMeshInBuffer) {
+		t.Errorf("adding twice: %v", err)
+	}
+
+	//the gap left by m is reused first fit
+	if err := b.RemoveMesh(m); err != nil {
+		t.Fatal(err)
+	}
+	small := testMesh(10, 4)
+	if err := b.AddMesh(small); err != nil {
+		t.Fatal(err)
+	}
+	if small.VertOffset != 30*VERTSIZE {
+		t.Errorf("small mesh at %d, want the freed offset %d", small.VertOffset, 30*VERTSIZE)
+	}
+	checkUploaded(t, fake, small)
+
+	//a mesh too big for any gap goes in a new buffer, and one bigger than a buffer gets its own
+	big := testMesh(25, 5)
+	if err := b.AddMesh(big); err != nil {
+		t.Fatal(err)
+	}
+	huge := testMesh(150, 6)
+	if err := b.AddMesh(huge); err != nil {
+		t.Fatal(err)
+	}
+	if b.Buffers() != 3 || big.vbo == a.vbo || huge.vbo == big.vbo || len(fake.buffers[huge.vbo]) != len(huge.Verts) {
+		t.Errorf("%d buffers, want 3", b.Buffers())
+	}
+
+	if total, largest := b.Free(); total != (20+10+75)*VERTSIZE || largest != 75*VERTSIZE {
+		t.Errorf("free = %d, largest %d", total, largest)
+	}
+
+	if err := b.RemoveMesh(m); !errors.Is(err, ErrMeshNotInBuffer) {
+		t.Errorf("removing twice: %v", err)
+	}
+	if err := b.AddMesh(&Mesh{}); !errors.Is(err, ErrEmptyMesh) {
+		t.Errorf("empty mesh: %v", err)
+	}
+}
+
+func TestRenderBufferRemoveMerges(t *testing.T) {
+	SetBackend(newFakeBackend())
+	defer SetBackend(nil)
+
+	b := RenderBuffer{MaxBufSize: 40 * VERTSIZE}
+	meshes := []*Mesh{testMesh(10, 1), testMesh(10, 2), testMesh(10, 3), testMesh(10, 4)}
+	for _, mesh := range meshes {
+		b.AddMesh(mesh)
+	}
+	b.RemoveMesh(meshes[0])
+	b.RemoveMesh(meshes[2])
+	b.RemoveMesh(meshes[1])
+	if total, largest := b.Free(); total != 30*VERTSIZE || largest != 30*VERTSIZE {
+		t.Errorf("free = %d, largest %d, want one span of 30 vertices", total, largest)
+	}
+	if err := b.AddMesh(testMesh(30, 5)); err != nil || b.Buffers() != 1 {
+		t.Errorf("merged space not reused: %v, %d buffers", err, b.Buffers())
+	}
+}
+
+func TestRenderBufferDefragment(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	b := RenderBuffer{MaxBufSize: 100 * VERTSIZE}
+	meshes := []*Mesh{testMesh(10, 1), testMesh(20, 2), testMesh(10, 3), testMesh(20, 4), testMesh(10, 5)}
+	skinned := testMesh(0, 0)
+	skinned.Stride = SKINNEDVERTSIZE
+	skinned.Verts = make([]float32, 3*SKINNEDVERTSIZE)
+	meshes = append(meshes, skinned)
+	for _, mesh := range meshes {
+		if err := b.AddMesh(mesh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.RemoveMesh(meshes[1])
+	b.RemoveMesh(meshes[3])
+	if _, largest := b.Free(); largest >= 40*VERTSIZE {
+		t.Fatalf("largest free span %d before defragmenting", largest)
+	}
+
+	b.Defragment()
+	if meshes[4].VertOffset != 20*VERTSIZE {
+		t.Errorf("last mesh moved to %d, want %d", meshes[4].VertOffset, 20*VERTSIZE)
+	}
+	for _, mesh := range []*Mesh{meshes[0], meshes[2], meshes[4], skinned} {
+		checkUploaded(t, fake, mesh)
+	}
+	used := 30*VERTSIZE + 3*SKINNEDVERTSIZE
+	if total, _ := b.Free(); total != 100*VERTSIZE-used {
+		t.Errorf("free = %d after defragmenting, want %d", total, 100*VERTSIZE-used)
+	}
+	if err := b.AddMesh(testMesh(40, 6)); err != nil || b.Buffers() != 1 {
+		t.Errorf("defragmented space not reused: %v, %d buffers", err, b.Buffers())
+	}
+}
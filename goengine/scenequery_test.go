@@ -0,0 +1,62 @@
+package goengine
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSceneQueries(t *testing.T) {
+	scene := Scene{Shapes: map[string]*Shape{
+		"cube1":   {Name: "cube1", ShapeType: ShapeCuboid, Layers: LayerDefault},
+		"cube2":   {Name: "cube2", ShapeType: ShapeCuboid, Layers: LayerUI},
+		"sphere1": {Name: "sphere1", ShapeType: ShapeSphere, Layers: LayerDefault | LayerMinimap},
+		"cone1":   {Name: "cone1", ShapeType: ShapeCone}, //no layers is LayerDefault
+	}}
+	scene.Shapes["cube2"].AddTag("enemy", "enemy", "boss")
+	scene.Shapes["sphere1"].AddTag("enemy")
+
+	names := func(shapes []*Shape) []string {
+		n := []string{}
+		for _, s := range shapes {
+			n = append(n, s.Name)
+		}
+		return n
+	}
+	tests := []struct {
+		name string
+		got  []*Shape
+		want []string
+	}{
+		{"tag", scene.ShapesWithTag("enemy"), []string{"cube2", "sphere1"}},
+		{"layer", scene.ShapesInLayers(LayerMinimap | LayerUI), []string{"cube2", "sphere1"}},
+		{"default layer", scene.ShapesInLayers(LayerDefault), []string{"cone1", "cube1", "sphere1"}},
+		{"type", scene.ShapesOfType(ShapeCuboid), []string{"cube1", "cube2"}},
+		{"predicate", scene.FindShapes(func(s *Shape) bool { return len(s.Tags) > 1 }), []string{"cube2"}},
+	}
+	for _, test := range tests {
+		if got := names(test.got); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s query = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if drawn := sortShapes(scene.Shapes, LayerDefault, Vec3{}); len(drawn) != 3 {
+		t.Errorf("%d shapes drawn in the default layer, want 3 including the one with no layers", len(drawn))
+	}
+
+	scene.Shapes["cube2"].RemoveTag("boss")
+	if len(scene.Shapes["cube2"].Tags) != 1 {
+		t.Errorf("tags after remove = %v", scene.Shapes["cube2"].Tags)
+	}
+	if _, ok := scene.Shape("cube3"); ok {
+		t.Error("Shape found a shape that doesn't exist")
+	}
+
+	cam := &Camera{}
+	if !cam.Sees(LayerUI) {
+		t.Error("camera with no layer mask should see every layer")
+	}
+	cam.LayerMask = LayerMinimap
+	if cam.Sees(LayerUI) || cam.visibleLayers(LayerAll) != LayerMinimap {
+		t.Error("camera layer mask not applied")
+	}
+}
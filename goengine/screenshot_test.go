@@ -0,0 +1,73 @@
+package goengine
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderToImage(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	//a cube above the centre must come out at the top of the image
+	scene.AddShape("cube", ShapeCuboid, 1, 1, 1, Vec3{0, 2, -10}, Vec3{}, 6, 0xff0000ff, "")
+
+	img, err := scene.RenderToImage(96, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Rect != image.Rect(0, 0, 96, 32) {
+		t.Fatalf("image is %v", img.Rect)
+	}
+	if got := img.RGBAAt(48, 11); got.R < 200 {
+		t.Errorf("above centre = %v, want red", got)
+	}
+	if got := img.RGBAAt(48, 20); got.R != 0 {
+		t.Errorf("below centre = %v, want the clear colour", got)
+	}
+	if scene.Width != 64 || scene.Camera.Aspect != 1 || b.viewport != [4]int32{0, 0, 64, 64} {
+		t.Error("window size, aspect or viewport not restored")
+	}
+
+	scene.Draw()
+	shot, err := scene.Screenshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range shot.Pix {
+		if shot.Pix[i] != b.Image().Pix[i] {
+			t.Fatal("screenshot doesn't match the window")
+		}
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Pix[0], img.Pix[3] = 255, 255
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "shot.png")
+	if err := SaveImage(img, file); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	loaded, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, a := loaded.At(0, 0).RGBA(); r != 0xffff || a != 0xffff {
+		t.Errorf("loaded pixel = %v", loaded.At(0, 0))
+	}
+
+	if err := SaveImage(img, filepath.Join(dir, "shot.jpg")); err != nil {
+		t.Error(err)
+	}
+	if err := SaveImage(img, filepath.Join(dir, "shot.bmp")); err == nil {
+		t.Error("expected an error for .bmp")
+	}
+}
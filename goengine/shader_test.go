@@ -20,12 +20,17 @@ func TestGetAttrib(t *testing.T) {
 	if err != "" {
 		t.Error(err)
 	}
+	if fmt.Sprint(attributes) != "[a_Position a_Normal a_UV]" {
+		t.Errorf("attributes = %v, want [a_Position a_Normal a_UV]", attributes)
+	}
 }
 
 func TestShape(t *testing.T) {
 
 	scene := Scene{}
-	scene.Setup("SDL 3D Shapes", 800, 600)
+	if err := scene.Setup("SDL 3D Shapes", 800, 600); err != nil {
+		t.Fatal(err)
+	}
 
 	scene.AddShape("cube1", ShapeCuboid, 3, 3, 3, Vec3{0, 0, -20}, Vec3{0, 0, 0}, 6, 0xff00ffff, imageDir+"redsky.png")
 
@@ -35,7 +40,10 @@ func TestShape(t *testing.T) {
 
 	scene.Draw()
 
-	var shape = scene.Shape("cube1")
+	shape, ok := scene.Shape("cube1")
+	if !ok {
+		t.Fatal("cube1 not found")
+	}
 	shape.Rotation = Vec3{shape.Rotation.X + 3, shape.Rotation.Y, shape.Rotation.Z + 1}
 
 	scene.Window.GLSwap()
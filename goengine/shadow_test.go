@@ -0,0 +1,164 @@
+package goengine
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+// shadowCoord returns where p is in a shadow matrix's clip space
+func shadowCoord(m *Mat4s, p Vec3) Vec3 {
+	clip := V4FromV3(p, 1).MulMat4(m)
+	return Vec3{clip.X / clip.W, clip.Y / clip.W, clip.Z / clip.W}
+}
+
+func inShadowMap(c Vec3) bool {
+	return math32.Abs(c.X) <= 1 && math32.Abs(c.Y) <= 1 && math32.Abs(c.Z) <= 1
+}
+
+func TestShadowDepthPass(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(90, 1, 1, 100)}
+	scene.AddShape("ground", ShapePlane, 20, 20, 0, Vec3{0, -2, -10}, Vec3{-90, 0, 0}, 0, 0xffffffff, "")
+	scene.AddShape("crate", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -10}, Vec3{}, 0, 0xff4080c0, "")
+	scene.AddShape("ghost", ShapeCuboid, 1, 1, 1, Vec3{3, 0, -10}, Vec3{}, 0, 0xffffffff, "")
+	scene.AddShape("wall", ShapeCuboid, 1, 1, 1, Vec3{-3, 0, -10}, Vec3{}, 0, 0xff808080, "")
+	scene.AddShape("glass", ShapePlane, 1, 1, 0, Vec3{0, 0, -5}, Vec3{}, 0, 0xffffffff, "")
+	scene.Shapes["ground"].CastShadows = false
+	scene.Shapes["crate"].ReceiveShadows = false
+	scene.Shapes["ghost"].CastShadows = false
+	scene.Shapes["wall"].Static = true
+	scene.Shapes["glass"].Material = NewMaterial("glass", 0x80ffffff)
+	scene.Shapes["glass"].Material.Blend = BlendAlpha
+	sun := NewDirectionalLight("sun", Vec3{1, -2, -1}, 0xffffffff)
+	scene.AddLight(sun)
+	scene.Shadows = NewCascadedShadowMap(sun, 3, 50)
+	scene.Draw()
+
+	sm := scene.Shadows
+	var depth, lit []fakeDraw
+	for _, draw := range fake.draws {
+		if draw.target == sm.target {
+			depth = append(depth, draw)
+		} else {
+			lit = append(lit, draw)
+		}
+	}
+	if sm.target == 0 || fake.targets[sm.target] != [2]int32{3 * 1024, 1024} {
+		t.Fatalf("shadow map target %d sized %v, want 3 cascades side by side", sm.target, fake.targets[sm.target])
+	}
+	//the crate and the batched wall in each cascade
+	if len(depth) != 6 || len(lit) != 5 {
+		t.Fatalf("%d depth draws and %d lit draws, want 6 and 5", len(depth), len(lit))
+	}
+	for i, draw := range depth {
+		if draw.program != scene.Renderer.depth.program {
+			t.Fatal("casters not drawn with the depth program")
+		}
+		want := sm.matrices[i/2].ToGLArray()
+		if got := draw.uniforms[scene.Renderer.depth.viewProjRef]; got[0] != want[0] || got[14] != want[14] {
+			t.Errorf("depth draw %d with the wrong cascade's matrix", i)
+		}
+	}
+
+	for _, draw := range lit {
+		u := draw.uniforms
+		if u[int32(shadowCascadesRef)][0] != 3 || u[int32(shadowLightRef)][0] != 0 {
+			t.Errorf("%v cascades of light %v, want 3 of the sun", u[int32(shadowCascadesRef)], u[int32(shadowLightRef)])
+		}
+		if draw.textures[shadowUnit] != sm.texture {
+			t.Errorf("texture %d on the shadow unit, want the shadow map", draw.textures[shadowUnit])
+		}
+		if got, want := u[fakeShadowMatrixRef(2)], sm.matrices[2].ToGLArray(); got[0] != want[0] || got[13] != want[13] {
+			t.Error("furthest cascade's matrix not set")
+		}
+		receive := u[int32(receiveShadowsRef)][0] == 1
+		model := u[int32(modelMatrixRef)]
+		if crate := model[12] == 0 && model[13] == 0 && model[14] == -10; receive == crate {
+			t.Errorf("receive shadows %v, want all but the crate", receive)
+		}
+	}
+	if fake.target != 0 || fake.program != 0 {
+		t.Errorf("target %d and program %d bound after drawing", fake.target, fake.program)
+	}
+
+	//without a shadow map nothing is shadowed
+	scene.Shadows = nil
+	fake.draws = nil
+	scene.Draw()
+	for _, draw := range fake.draws {
+		if draw.target != 0 || draw.uniforms[int32(shadowCascadesRef)][0] != 0 {
+			t.Fatal("shadows drawn without a shadow map")
+		}
+	}
+}
+
+func TestShadowCascades(t *testing.T) {
+	sun := NewDirectionalLight("sun", Vec3{0, -1, -1}, 0xffffffff)
+	sun.update()
+	cam := NewCamera(60, 1.5, 0.5, 1000)
+	cam.Position, cam.Target = Vec3{10, 5, 20}, Vec3{30, 0, -20}
+	sm := NewCascadedShadowMap(sun, 4, 200)
+	sm.fit(cam)
+	if sm.count != 4 {
+		t.Fatalf("%d cascades, want 4", sm.count)
+	}
+
+	//every point the camera sees within the shadow distance is in a cascade,
+	//and nearer cascades have more texels per metre
+	forward, right := cam.Forward(), cam.Right()
+	for _, d := range []float32{1, 5, 20, 60, 120, 199} {
+		p := cam.Position.Add(forward.MulScalar(d)).Add(right.MulScalar(d * 0.4))
+		found := false
+		for c := 0; c < sm.count && !found; c++ {
+			found = inShadowMap(shadowCoord(&sm.matrices[c], p))
+		}
+		if !found {
+			t.Errorf("point %v away isn't in any cascade", d)
+		}
+	}
+	for c := 1; c < sm.count; c++ {
+		if sm.matrices[c].m0 >= sm.matrices[c-1].m0 {
+			t.Errorf("cascade %d covers no more than cascade %d", c, c-1)
+		}
+	}
+
+	//the maps move in whole texels
+	origin := Vec3{}.MulMat4(&sm.matrices[0])
+	if texels := origin.X * 512; math32.Abs(texels-math32.Round(texels)) > 0.01 {
+		t.Errorf("world origin at %v texels, want a whole number", texels)
+	}
+
+	if near, far := cascadeSplit(1, 100, 0, 0.5), cascadeSplit(1, 100, 1, 0.5); near != 50.5 || !almostEqual(far, 10) {
+		t.Errorf("half way splits %v evenly and %v logarithmically, want 50.5 and 10", near, far)
+	}
+}
+
+func TestShadowSpotLight(t *testing.T) {
+	torch := NewSpotLight("torch", Vec3{0, 10, 0}, Vec3{0, -1, 0}, 0xffffffff, 20, 20, 30)
+	torch.update()
+	sm := NewShadowMap(torch)
+	sm.Cascades = 3
+	sm.fit(NewCamera(90, 1, 1, 100))
+	if sm.count != 1 {
+		t.Fatalf("%d cascades for a spot light, want 1", sm.count)
+	}
+	if c := shadowCoord(&sm.matrices[0], Vec3{0, 0, 0}); !almostEqual(c.X, 0) || !almostEqual(c.Y, 0) || !inShadowMap(c) {
+		t.Errorf("point under the torch at %v, want the middle of the map", c)
+	}
+	if c := shadowCoord(&sm.matrices[0], Vec3{10, 0, 0}); inShadowMap(c) {
+		t.Errorf("point outside the cone at %v, want off the map", c)
+	}
+	if reach := lightReach(torch.falloff()); math32.Abs(reach-20) > 0.1 {
+		t.Errorf("reach %v, want 20", reach)
+	}
+
+	sm.Light = NewPointLight("lamp", Vec3{}, 0xffffffff, 10)
+	sm.fit(NewCamera(90, 1, 1, 100))
+	if sm.count != 0 {
+		t.Errorf("%d cascades for a point light, want none", sm.count)
+	}
+}
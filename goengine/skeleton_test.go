@@ -0,0 +1,141 @@
+package goengine
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func TestSkinCPU(t *testing.T) {
+	sk := NewSkeleton()
+	root := sk.AddJoint("root", -1, Vec3{0, 0, 0}, NewQuat(0, 0, 0, 1), Vec3{1, 1, 1})
+	arm := sk.AddJoint("arm", root, Vec3{1, 0, 0}, NewQuat(0, 0, 0, 1), Vec3{1, 1, 1})
+	sk.SetBindPose()
+
+	mesh := &Mesh{}
+	mesh.AddSkinnedVert(Vec3{2, 0, 0}, Vec3{1, 0, 0}, Vec2{}, 0, [4]uint8{uint8(arm)}, [4]float32{1})
+	mesh.AddSkinnedVert(Vec3{2, 0, 0}, Vec3{1, 0, 0}, Vec2{}, 0, [4]uint8{uint8(root), uint8(arm)}, [4]float32{0.5, 0.5})
+	skinned := NewSkinnedMesh(mesh, sk)
+
+	//Rotate the arm 90 degrees about Z - a point 1 unit along the arm moves from (2,0,0) to (1,1,0)
+	sk.Joints[arm].Rotation = NewQuatAxisAngle(Vec3{0, 0, 1}, math32.Pi/2)
+	sk.Update()
+	skinned.SkinCPU()
+
+	v := mesh.Verts
+	if !almostEqual(v[0], 1) || !almostEqual(v[1], 1) || !almostEqual(v[3], 0) || !almostEqual(v[4], 1) {
+		t.Errorf("arm vertex = %v normal = %v, want (1,1,0) and (0,1,0)", v[0:3], v[3:6])
+	}
+	i := SKINNEDVERTSIZE
+	if !almostEqual(v[i], 1.5) || !almostEqual(v[i+1], 0.5) {
+		t.Errorf("blended vertex = %v, want (1.5,0.5,0)", v[i:i+3])
+	}
+	if !almostEqual(skinned.BindVerts[0], 2) {
+		t.Error("bind pose vertices were modified")
+	}
+}
+
+func TestSkinnedMeshDraw(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	sk := NewSkeleton()
+	root := sk.AddJoint("root", -1, Vec3{}, NewQuat(0, 0, 0, 1), Vec3{1, 1, 1})
+	sk.SetBindPose()
+	mesh := &Mesh{Mode: int(DrawTriangles)}
+	for i := 0; i < 3; i++ {
+		mesh.AddSkinnedVert(Vec3{float32(i), 0, 0}, Vec3{0, 0, 1}, Vec2{}, 0, [4]uint8{uint8(root)}, [4]float32{1})
+	}
+	skinned := NewSkinnedMesh(mesh, sk)
+	cam := NewCamera(90, 1, 1, 100)
+	place := NewTransform(Vec3{0, 0, -5}, Vec3{})
+	model := place.Matrix()
+	sk.Joints[root].Position = Vec3{0, 1, 0}
+
+	//without a fixed-function pipeline the CPU skinned vertices are drawn by a renderer
+	if err := skinned.Draw(cam, &model); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.draws) != 1 {
+		t.Fatalf("%d draws on the CPU, want 1", len(fake.draws))
+	}
+	draw := fake.draws[0]
+	if y := fake.buffers[draw.buffer][1]; y != 1 {
+		t.Errorf("CPU skinned vertex drawn at y = %v, want 1", y)
+	}
+	if z := draw.uniforms[int32(modelMatrixRef)][14]; z != -5 {
+		t.Errorf("model matrix z = %v, want -5", z)
+	}
+
+	if err := skinned.EnableGPU(); err != nil {
+		t.Fatal(err)
+	}
+	if err := skinned.Draw(cam, &model); err != nil {
+		t.Fatal(err)
+	}
+	draw = fake.draws[1]
+	if draw.buffer != skinned.source || draw.program != skinned.program.Program || draw.mode != DrawTriangles || draw.count != 3 {
+		t.Error("GPU skinning didn't draw the bind pose buffer with the skinned program")
+	}
+	if y := fake.buffers[skinned.source][1]; y != 0 {
+		t.Errorf("uploaded bind pose vertex at y = %v, want 0", y)
+	}
+	if joint := draw.uniforms[fakeJointRef(0)]; len(joint) != 16 || joint[13] != 1 {
+		t.Errorf("joint matrix %v, want a translation of 1 in y", joint)
+	}
+
+	//a skeleton without joints has no joint matrices to set
+	empty := NewSkinnedMesh(mesh, NewSkeleton())
+	if err := empty.EnableGPU(); err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Draw(cam, &model); err != nil {
+		t.Fatal(err)
+	}
+	empty.Delete()
+	skinned.Delete()
+	if len(fake.buffers) != 0 {
+		t.Errorf("%d buffers left after Delete", len(fake.buffers))
+	}
+}
+
+func TestSkinnedMeshDrawIndexed(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	sk := NewSkeleton()
+	root := sk.AddJoint("root", -1, Vec3{}, NewQuat(0, 0, 0, 1), Vec3{1, 1, 1})
+	sk.SetBindPose()
+	mesh := &Mesh{}
+	for i := 0; i < 4; i++ {
+		mesh.AddSkinnedVert(Vec3{float32(i % 2), float32(i / 2), 0}, Vec3{0, 0, 1}, Vec2{}, 0, [4]uint8{uint8(root)}, [4]float32{1})
+	}
+	mesh.AddStrip([]uint32{0, 1, 2, 3})
+	skinned := NewSkinnedMesh(mesh, sk)
+	cam := NewCamera(90, 1, 1, 100)
+
+	if err := skinned.Draw(cam, Identity4()); err != nil {
+		t.Fatal(err)
+	}
+	if err := skinned.EnableGPU(); err != nil {
+		t.Fatal(err)
+	}
+	if err := skinned.Draw(cam, Identity4()); err != nil {
+		t.Fatal(err)
+	}
+	for i, draw := range fake.draws {
+		if draw.mode != DrawTriangleStrip || draw.indexBuffer == 0 || draw.count != 4 {
+			t.Errorf("draw %d: mode %v with index buffer %d count %d, want the strip's 4 indices", i, draw.mode, draw.indexBuffer, draw.count)
+		}
+	}
+	if indexes := fake.indexes[fake.draws[1].indexBuffer]; len(indexes) != 4 || indexes[3] != 3 {
+		t.Errorf("uploaded indices %v, want the mesh's", indexes)
+	}
+
+	skinned.Delete()
+	if len(fake.buffers)+len(fake.indexes) != 0 {
+		t.Errorf("%d buffers left after Delete", len(fake.buffers)+len(fake.indexes))
+	}
+}
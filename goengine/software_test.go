@@ -0,0 +1,109 @@
+package goengine
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func newSoftwareScene(t *testing.T) (*Scene, *SoftwareBackend) {
+	t.Helper()
+	scene := &Scene{}
+	b := scene.SetupSoftware(64, 64)
+	t.Cleanup(func() { SetBackend(nil) })
+	scene.SetClearColour(0xff000000)
+	return scene, b
+}
+
+func TestSoftwareCube(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	scene.AddShape("cube", ShapeCuboid, 2, 2, 2, Vec3{0, 0, -10}, Vec3{}, 6, 0xff0000ff, "")
+	scene.Draw()
+
+	img := b.Image()
+	if got := img.RGBAAt(32, 32); got.R < 250 || got.G != 0 || got.B != 0 {
+		t.Errorf("centre = %v, want lit red", got)
+	}
+	if got := img.RGBAAt(2, 2); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("corner = %v, want the clear colour", got)
+	}
+}
+
+func TestSoftwareShading(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	//facing away from the light only gets the light's ambient half and GL's 0.2 model ambient
+	scene.AddShape("plane", ShapePlane, 5, 5, 0, Vec3{0, 0, -10}, Vec3{0, 70, 0}, 6, 0xffffffff, "")
+	scene.Draw()
+	if got := b.Image().RGBAAt(32, 32); got.R < 170 || got.R > 186 {
+		t.Errorf("centre = %v, want ambient grey", got)
+	}
+}
+
+func TestSoftwareDepthAndCulling(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	scene.AddShape("near", ShapeCuboid, 1, 1, 1, Vec3{0, 0, -8}, Vec3{}, 6, 0xff00ff00, "")
+	scene.AddShape("far", ShapeCuboid, 4, 4, 4, Vec3{0, 0, -20}, Vec3{}, 6, 0xff0000ff, "")
+	//the camera is inside this one so all of its faces are culled
+	scene.AddShape("room", ShapeCuboid, 50, 50, 50, Vec3{}, Vec3{}, 6, 0xffff0000, "")
+	for i := 0; i < 3; i++ {
+		scene.Draw()
+		img := b.Image()
+		if got := img.RGBAAt(32, 32); got.G < 200 || got.R != 0 {
+			t.Fatalf("centre = %v, want the near green cube", got)
+		}
+		if got := img.RGBAAt(25, 32); got.R < 200 || got.G != 0 {
+			t.Fatalf("left = %v, want the far red cube", got)
+		}
+		if got := img.RGBAAt(1, 1); got.B != 0 {
+			t.Fatalf("corner = %v, want the clear colour", got)
+		}
+	}
+}
+
+func TestSoftwareTexture(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	b.Lighting = false
+	tex := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	tex.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	tex.SetRGBA(1, 0, color.RGBA{0, 0, 255, 255})
+	id, _ := b.CreateTexture(tex, false)
+
+	//a floor from z=-2 to -42 with the texture changing colour half way. With perspective
+	//that's just below the horizon, but it would be half way down the floor if interpolated linearly.
+	scene.AddShape("floor", ShapePlane, 20, 4, 0, Vec3{0, -2, -22}, Vec3{-90, 0, -90}, 6, 0xffffffff, "")
+	scene.Shapes["floor"].Texture = Texture{id: id}
+	scene.Draw()
+	img := b.Image()
+	if got := img.RGBAAt(32, 34); got.R < 250 || got.B != 0 {
+		t.Errorf("far = %v, want red", got)
+	}
+	if got := img.RGBAAt(32, 40); got.B < 250 || got.R != 0 {
+		t.Errorf("near = %v, want blue", got)
+	}
+	if got := img.RGBAAt(32, 30); got.A != 255 || got.R != 0 || got.B != 0 {
+		t.Errorf("sky = %v, want the clear colour", got)
+	}
+}
+
+func TestSoftwareFog(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	b.SetFog(0xff00ff00, 0, 60)
+	scene.AddShape("cube", ShapeCuboid, 20, 20, 1, Vec3{0, 0, -50}, Vec3{}, 6, 0xff0000ff, "")
+	scene.Draw()
+	//vs.txt keeps (z+max)/(max-min) of the colour: 1/6 red, 5/6 green
+	if got := b.Image().RGBAAt(32, 32); got.R < 40 || got.R > 52 || got.G < 200 || got.G > 215 {
+		t.Errorf("centre = %v, want mostly fog", got)
+	}
+}
+
+func TestSoftwareIndexed(t *testing.T) {
+	scene, b := newSoftwareScene(t)
+	quad := quadShape("quad", 2)
+	quad.Position = Vec3{0, 0, -10}
+	quad.Colour = 0xff00ff00
+	scene.Shapes = map[string]*Shape{"quad": &quad}
+	scene.Draw()
+	if got := b.Image().RGBAAt(32, 32); got.G < 250 || got.R != 0 {
+		t.Errorf("centre = %v, want lit green", got)
+	}
+}
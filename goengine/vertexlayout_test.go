@@ -0,0 +1,92 @@
+package goengine
+
+import (
+	"testing"
+)
+
+func TestLayoutStrides(t *testing.T) {
+	if PackedLayout.Stride != VERTSIZE || SkinnedLayout.Stride != SKINNEDVERTSIZE {
+		t.Errorf("packed stride %d and skinned stride %d, want %d and %d", PackedLayout.Stride, SkinnedLayout.Stride, VERTSIZE, SKINNEDVERTSIZE)
+	}
+	if off := SkinnedLayout.Offset(AttribWeights); off != VERTSIZE+4 {
+		t.Errorf("skinned weights at %d, want %d", off, VERTSIZE+4)
+	}
+	if PackedLayout.Has(AttribTangent) || PackedLayout.Offset(AttribTangent) != -1 {
+		t.Error("packed layout has tangents")
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	layout := NewVertexLayout(
+		VertexAttribute{Name: AttribPosition, Components: 3, Type: AttribFloat},
+		VertexAttribute{Name: AttribColour, Components: 4, Type: AttribUByte, Normalized: true},
+		VertexAttribute{Name: AttribUV2, Components: 2, Type: AttribFloat},
+		VertexAttribute{Name: AttribJoints, Components: 4, Type: AttribUByte},
+		VertexAttribute{Name: AttribWeights, Components: 4, Type: AttribFloat},
+	)
+	if layout.Stride != 3+1+2+1+4 {
+		t.Fatalf("stride %d, want 11", layout.Stride)
+	}
+	want := Vertex{Position: Vec3{1, 2, 3}, Colour: 0x80ff4020, UV2: Vec2{0.5, 0.25}, Joints: [4]uint8{1, 2, 30, 255}, Weights: [4]float32{0.5, 0.5}}
+	verts := layout.Append(nil, Vertex{})
+	verts = layout.Append(verts, want)
+	if got := layout.Vertex(verts, 1); got != want {
+		t.Errorf("unpacked %+v, want %+v", got, want)
+	}
+}
+
+func TestLayoutBind(t *testing.T) {
+	attribs := PackedLayout.Bind([]string{AttribPosition, "a_Unknown", AttribColour})
+	if len(attribs) != 2 {
+		t.Fatalf("bound %v, want position and colour", attribs)
+	}
+	col := attribs[1]
+	if col.Location != 2 || col.Offset != 8 || col.Size != 4 || col.Type != AttribUByte || !col.Normalized {
+		t.Errorf("colour bound as %+v", col)
+	}
+}
+
+func TestMeshLayouts(t *testing.T) {
+	//Shape vertices, packed meshes and skinned meshes all share the packed vertex
+	verts := storeVNTC2(0xff0000ff, Vec3{1, 2, 3}, Vec3{0, 1, 0}, Vec2{0.5, 1})
+	mesh := &Mesh{}
+	mesh.Init()
+	mesh.AddPackedVert(Vec3{1, 2, 3}, Vec3{0, 1, 0}, Vec2{0.5, 1}, 0xff0000ff)
+	skinned := &Mesh{}
+	skinned.AddSkinnedVert(Vec3{1, 2, 3}, Vec3{0, 1, 0}, Vec2{0.5, 1}, 0xff0000ff, [4]uint8{3}, [4]float32{1})
+	for i := 0; i < VERTSIZE-1; i++ {
+		if verts[i] != mesh.Verts[i] || verts[i] != skinned.Verts[i] {
+			t.Fatalf("shape %v, mesh %v and skinned %v vertices differ", verts, mesh.Verts, skinned.Verts[:VERTSIZE])
+		}
+	}
+	if v := skinned.Vertex(0); v.Colour != 0xff0000ff || v.Joints[0] != 3 || v.Weights[0] != 1 {
+		t.Errorf("skinned vertex %+v", v)
+	}
+
+	mesh.SetLayout(TangentLayout)
+	if len(mesh.Verts) != TangentLayout.Stride || mesh.Vertex(0).Position != (Vec3{1, 2, 3}) || mesh.Vertex(0).Colour != 0xff0000ff {
+		t.Errorf("converted to %v", mesh.Verts)
+	}
+}
+
+func TestBuildMeshLayout(t *testing.T) {
+	shape := NewShape("plane", ShapePlane, 1, 1, 0, Vec3{}, Vec3{}, 0, 0xffffffff, "")
+	shape.Layout = TangentLayout
+	mesh := shape.BuildMesh()
+	if mesh.stride() != TangentLayout.Stride || mesh.VertCount() != 6 {
+		t.Fatalf("built %d vertices with stride %d", mesh.VertCount(), mesh.stride())
+	}
+	for i := 0; i < mesh.VertCount(); i++ {
+		v := mesh.Vertex(i)
+		if v.Tangent != (Vec4{1, 0, 0, 1}) {
+			t.Errorf("vertex %d tangent %v, want 1,0,0,1 along u", i, v.Tangent)
+		}
+		if v.Colour != 0xffffffff {
+			t.Errorf("vertex %d colour %08x, want white", i, v.Colour)
+		}
+	}
+
+	if err := (&Mesh{}).GenerateTangents(); err == nil {
+		t.Error("generated tangents for a packed mesh")
+	}
+}
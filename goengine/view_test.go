@@ -0,0 +1,114 @@
+package goengine
+
+import "testing"
+
+func TestViewportPixels(t *testing.T) {
+	tests := []struct {
+		viewport   Viewport
+		x, y, w, h int32
+	}{
+		{FullViewport, 0, 0, 200, 100},
+		{Viewport{0.5, 0, 0.5, 1}, 100, 0, 100, 100},
+		{Viewport{0.75, 0.75, 0.25, 0.25}, 150, 75, 50, 25},
+		{Viewport{0, 0, 0, 0}, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		x, y, w, h := test.viewport.Pixels(200, 100)
+		if x != test.x || y != test.y || w != test.w || h != test.h {
+			t.Errorf("%v = %d,%d,%d,%d, want %d,%d,%d,%d", test.viewport, x, y, w, h, test.x, test.y, test.w, test.h)
+		}
+	}
+}
+
+func TestViewLayers(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Width: 200, Height: 100}
+	scene.AddShape("world", ShapeCuboid, 1, 1, 1, Vec3{-1, 0, -10}, Vec3{}, 0, 0xff0000ff, "")
+	scene.AddShape("marker", ShapeCuboid, 1, 1, 1, Vec3{1, 0, -10}, Vec3{}, 0, 0xff00ff00, "")
+	scene.Shapes["marker"].Layers = LayerMinimap
+	mainView := scene.AddView("main", NewCamera(60, 1, 1, 100), FullViewport, 0, LayerDefault)
+	minimap := scene.AddView("minimap", NewCamera(60, 1, 1, 100), Viewport{0.75, 0.75, 0.25, 0.25}, 0, LayerMinimap)
+	scene.Draw()
+
+	if len(fake.draws) != 2 {
+		t.Fatalf("%d draws, want one shape in each view", len(fake.draws))
+	}
+	tests := []struct {
+		x        float32
+		viewport [4]int32
+	}{
+		{-1, [4]int32{0, 0, 200, 100}},
+		{1, [4]int32{150, 75, 50, 25}},
+	}
+	for i, test := range tests {
+		draw := fake.draws[i]
+		if x := draw.uniforms[int32(modelMatrixRef)][12]; x != test.x || draw.viewport != test.viewport {
+			t.Errorf("draw %d of the shape at x %v in %v, want x %v in %v", i, x, draw.viewport, test.x, test.viewport)
+		}
+	}
+	if !almostEqual(minimap.Camera.Aspect, 2) {
+		t.Errorf("minimap aspect %v, want its viewport's 2", minimap.Camera.Aspect)
+	}
+	if fake.viewport != [4]int32{0, 0, 200, 100} {
+		t.Errorf("viewport %v after drawing the views, want the whole window", fake.viewport)
+	}
+
+	//a disabled view isn't drawn and the camera's mask narrows the view's
+	minimap.Enabled = false
+	mainView.Camera.LayerMask = LayerMinimap
+	fake.draws = nil
+	scene.Draw()
+	if len(fake.draws) != 0 {
+		t.Errorf("%d draws, want none", len(fake.draws))
+	}
+}
+
+func TestViewLookup(t *testing.T) {
+	scene := Scene{}
+	scene.SetupSplitScreen(0, NewCamera(60, 1, 1, 100), NewCamera(60, 1, 1, 100), NewCamera(60, 1, 1, 100))
+	if len(scene.Views) != 3 || scene.View("split3").Viewport != (Viewport{2.0 / 3, 0, 1.0 / 3, 1}) {
+		t.Fatalf("split screen views %v", scene.Views)
+	}
+	scene.RemoveView("split2")
+	if scene.View("split2") != nil || len(scene.Views) != 2 || scene.Views[1].Name != "split3" {
+		t.Errorf("views %v after removing split2", scene.Views)
+	}
+	scene.ClearViews()
+	if len(scene.Views) != 0 || scene.View("split1") != nil {
+		t.Errorf("views %v after clearing", scene.Views)
+	}
+}
+
+func TestQuadView(t *testing.T) {
+	scene := Scene{}
+	target := Vec3{1, 2, 3}
+	scene.SetupQuadView(target, 10, 0)
+
+	tests := []struct {
+		name       string
+		projection ProjectionType
+		forward    Vec3
+		viewport   Viewport
+	}{
+		{"top", ProjectionOrthographic, Vec3{0, -1, 0}, Viewport{0, 0.5, 0.5, 0.5}},
+		{"front", ProjectionOrthographic, Vec3{0, 0, -1}, Viewport{0, 0, 0.5, 0.5}},
+		{"side", ProjectionOrthographic, Vec3{-1, 0, 0}, Viewport{0.5, 0, 0.5, 0.5}},
+		{"perspective", ProjectionPerspective, Vec3{-1, -1, -1}.Normal(), Viewport{0.5, 0.5, 0.5, 0.5}},
+	}
+	for _, test := range tests {
+		view := scene.View(test.name)
+		if view == nil {
+			t.Fatalf("no %s view", test.name)
+		}
+		cam := view.Camera
+		if cam.Projection != test.projection || !vec3AlmostEqual(cam.Forward(), test.forward) || cam.Target != target || view.Viewport != test.viewport {
+			t.Errorf("%s: projection %v looking %v at %v in %v", test.name, cam.Projection, cam.Forward(), cam.Target, view.Viewport)
+		}
+	}
+	if _, ok := scene.View("perspective").Camera.Controller.(*OrbitController); !ok {
+		t.Error("perspective view can't be orbited")
+	}
+}
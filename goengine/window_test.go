@@ -0,0 +1,83 @@
+package goengine
+
+import (
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestResize(t *testing.T) {
+	fake := newFakeBackend()
+	SetBackend(fake)
+	defer SetBackend(nil)
+
+	scene := Scene{Camera: NewCamera(60, 1, 1, 100)}
+	calls := 0
+	scene.OnResize(func(s *Scene, w, h int32) {
+		calls++
+		if w != s.Width || h != s.Height {
+			t.Errorf("callback size %dx%d, want the drawable %dx%d", w, h, s.Width, s.Height)
+		}
+	})
+
+	tests := []struct {
+		name                   string
+		windowW, windowH, w, h int32
+		calls                  int
+		viewport               [4]int32
+		aspect, scale          float32
+	}{
+		{"windowed", 800, 600, 800, 600, 1, [4]int32{0, 0, 800, 600}, 800.0 / 600, 1},
+		{"high-DPI", 800, 600, 1600, 1200, 2, [4]int32{0, 0, 1600, 1200}, 800.0 / 600, 2},
+		{"widescreen", 1920, 1080, 1920, 1080, 3, [4]int32{0, 0, 1920, 1080}, 1920.0 / 1080, 1},
+		{"minimised", 0, 0, 0, 0, 3, [4]int32{0, 0, 1920, 1080}, 1920.0 / 1080, 1}, //keeps the last viewport and aspect
+	}
+	for _, test := range tests {
+		scene.setSize(test.windowW, test.windowH, test.w, test.h)
+		if calls != test.calls || fake.viewport != test.viewport {
+			t.Errorf("%s: %d callbacks with viewport %v, want %d and %v", test.name, calls, fake.viewport, test.calls, test.viewport)
+		}
+		if !almostEqual(scene.Camera.Aspect, test.aspect) || scene.PixelScale() != test.scale {
+			t.Errorf("%s: aspect %v pixel scale %v, want %v and %v", test.name, scene.Camera.Aspect, scene.PixelScale(), test.aspect, test.scale)
+		}
+	}
+}
+
+func TestFullscreen(t *testing.T) {
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		t.Skip("no video device:", err)
+	}
+	defer sdl.Quit()
+	window, err := sdl.CreateWindow("fullscreen", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 320, 240, sdl.WINDOW_HIDDEN|sdl.WINDOW_RESIZABLE)
+	if err != nil {
+		t.Skip("no window:", err)
+	}
+	defer window.Destroy()
+
+	SetBackend(newFakeBackend())
+	defer SetBackend(nil)
+	scene := Scene{Window: window, Camera: NewCamera(60, 1, 1, 100)}
+	resized := 0
+	scene.OnResize(func(s *Scene, w, h int32) { resized++ })
+
+	if err := scene.ToggleFullscreen(); err != nil {
+		t.Fatal(err)
+	}
+	if scene.FullscreenMode != FullscreenBorderless || window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP != sdl.WINDOW_FULLSCREEN_DESKTOP {
+		t.Errorf("mode %v with window flags %x, want borderless fullscreen", scene.FullscreenMode, window.GetFlags())
+	}
+	if err := scene.ToggleFullscreen(); err != nil {
+		t.Fatal(err)
+	}
+	if scene.FullscreenMode != Windowed || window.GetFlags()&sdl.WINDOW_FULLSCREEN != 0 {
+		t.Errorf("mode %v with window flags %x, want windowed", scene.FullscreenMode, window.GetFlags())
+	}
+
+	scene.SetWindowSize(400, 300)
+	if w, h := window.GetSize(); w != 400 || h != 300 || scene.WindowWidth != 400 || scene.WindowHeight != 300 {
+		t.Errorf("window %dx%d, scene window size %dx%d, want 400x300", w, h, scene.WindowWidth, scene.WindowHeight)
+	}
+	if resized == 0 {
+		t.Error("no resize callbacks after changing the window")
+	}
+}